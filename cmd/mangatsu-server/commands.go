@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Mangatsu/server/internal/config"
+	"github.com/Mangatsu/server/pkg/cache"
+	"github.com/Mangatsu/server/pkg/db"
+	"github.com/Mangatsu/server/pkg/library"
+	"github.com/Mangatsu/server/pkg/metadata"
+	"github.com/Mangatsu/server/pkg/utils"
+)
+
+type command struct {
+	Name        string
+	Usage       string
+	Description string
+	Run         func(args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"serve", "serve", "Launch the API server (default)", serve},
+		{"scan", "scan", "Scan all libraries for new galleries", scan},
+		{"thumbnails", "thumbnails [-pages] [-force]", "Generate cover and page thumbnails", thumbnails},
+		{"meta", "meta [-x] [-ehdl] [-hath] [-fuzzy] [-title] [-providers anilist,json]", "Parse metadata from the given sources", meta},
+		{"user", "user <add|passwd|delete|list> ...", "Manage users", user},
+		{"migrate", "migrate <up|down|status>", "Manage database migrations", migrate},
+		{"cache", "cache prune", "Remove cached galleries not accessed within MTSU_CACHE_TTL", cacheCommand},
+		{"backup", "backup [path]", "Write a copy of the database to the given path", backup},
+		{"help", "help", "Show this help", help},
+	}
+}
+
+// runCommand runs the command with the given name.
+func runCommand(name string, args []string) error {
+	for _, c := range commands {
+		if c.Name == name {
+			return c.Run(args)
+		}
+	}
+
+	_ = help(nil)
+	return fmt.Errorf("unknown command: %s", name)
+}
+
+func help(_ []string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "Usage: mangatsu-server <command> [arguments]")
+	fmt.Fprintln(w, "")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %s\t%s\n", c.Usage, c.Description)
+	}
+	return w.Flush()
+}
+
+func scan(_ []string) error {
+	initStorage()
+	storeLibraries()
+	cache.InitProcessingStatusCache()
+
+	ctx, stop := signalContext()
+	defer stop()
+	library.ScanArchives(ctx)
+
+	status := cache.ProcessingStatusCache.Snapshot().Scan
+	fmt.Printf("found: %d, skipped: %d, errors: %d\n", status.FoundCount, status.SkippedCount, status.ErrorCount)
+	return nil
+}
+
+func thumbnails(args []string) error {
+	flags := flag.NewFlagSet("thumbnails", flag.ExitOnError)
+	pages := flags.Bool("pages", false, "also generate thumbnails for pages")
+	force := flags.Bool("force", false, "regenerate existing thumbnails")
+	_ = flags.Parse(args)
+
+	initStorage()
+	cache.InitProcessingStatusCache()
+
+	ctx, stop := signalContext()
+	defer stop()
+	library.GenerateThumbnails(ctx, *pages, *force)
+
+	status := cache.ProcessingStatusCache.Snapshot().Thumbnails
+	fmt.Printf("covers: %d, pages: %d, errors: %d\n", status.GeneratedCovers, status.GeneratedPages, status.ErrorCount)
+	return nil
+}
+
+func meta(args []string) error {
+	flags := flag.NewFlagSet("meta", flag.ExitOnError)
+	x := flags.Bool("x", false, "parse X (info.json) metadata")
+	ehdl := flags.Bool("ehdl", false, "parse E-Hentai-Downloader (info.txt) metadata")
+	hath := flags.Bool("hath", false, "parse H@H (galleryinfo.txt) metadata")
+	fuzzy := flags.Bool("fuzzy", false, "fuzzy match metadata files for galleries without an exact match")
+	title := flags.Bool("title", false, "parse metadata from titles and filenames")
+	providers := flags.String("providers", "", "comma-separated online metadata providers, e.g. anilist,json")
+	_ = flags.Parse(args)
+
+	if !*x && !*ehdl && !*hath && !*title && *providers == "" {
+		return errors.New("no sources specified")
+	}
+
+	initStorage()
+	cache.InitProcessingStatusCache()
+	metadata.InitProviders()
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	if *x || *ehdl || *hath {
+		metaTypes := make(map[metadata.MetaType]bool)
+		metaTypes[metadata.XMeta] = *x
+		metaTypes[metadata.EHDLMeta] = *ehdl
+		metaTypes[metadata.HathMeta] = *hath
+		metaTypes[metadata.FuzzyMatch] = *fuzzy
+		metadata.ParseMetadata(ctx, metaTypes)
+	}
+
+	if *title {
+		metadata.ParseTitles(ctx, true, false)
+	}
+
+	if *providers != "" {
+		names := strings.Split(*providers, ",")
+		for _, name := range names {
+			if _, ok := metadata.GetProvider(name); !ok {
+				return fmt.Errorf("metadata provider not enabled: %s", name)
+			}
+		}
+		metadata.ParseProviders(ctx, names)
+	}
+
+	status := cache.ProcessingStatusCache.Snapshot().Metadata
+	fmt.Printf("parsed: %d, errors: %d\n", status.Parsed, status.ErrorCount)
+	return nil
+}
+
+func user(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: user <add|passwd|delete|list>")
+	}
+
+	initStorage()
+
+	switch args[0] {
+	case "add":
+		return userAdd(args[1:])
+	case "passwd":
+		return userPasswd(args[1:])
+	case "delete":
+		return userDelete(args[1:])
+	case "list":
+		return userList()
+	default:
+		return fmt.Errorf("unknown user command: %s", args[0])
+	}
+}
+
+// userAdd registers a new user. The password is read from stdin if not given as an argument.
+func userAdd(args []string) error {
+	flags := flag.NewFlagSet("user add", flag.ExitOnError)
+	roleName := flags.String("role", "viewer", "role of the user: superadmin, admin, member, viewer or a number")
+	_ = flags.Parse(args)
+
+	if flags.NArg() < 1 {
+		return errors.New("usage: user add [-role viewer] <username> [password]")
+	}
+
+	role, ok := db.ParseRole(*roleName, db.SuperAdmin)
+	if !ok {
+		return fmt.Errorf("invalid role: %s", *roleName)
+	}
+
+	username := flags.Arg(0)
+	if !utils.IsValidUsername(username) {
+		return errors.New("username not valid")
+	}
+
+	password, err := passwordArg(flags.Args()[1:])
+	if err != nil {
+		return err
+	}
+
+	if err = db.Register(username, password, role); err != nil {
+		return err
+	}
+
+	fmt.Printf("user %s added\n", username)
+	return nil
+}
+
+// userPasswd changes the password of a user. The password is read from stdin if not given as an argument.
+func userPasswd(args []string) error {
+	if len(args) < 1 {
+		return errors.New("usage: user passwd <username> [password]")
+	}
+
+	users, err := db.GetUser(args[0])
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return fmt.Errorf("user %s not found", args[0])
+	}
+
+	password, err := passwordArg(args[1:])
+	if err != nil {
+		return err
+	}
+
+	if err = db.UpdateUser(users[0].UUID, &db.UserForm{Password: &password}); err != nil {
+		return err
+	}
+
+	fmt.Printf("password of %s changed\n", args[0])
+	return nil
+}
+
+func userDelete(args []string) error {
+	if len(args) < 1 {
+		return errors.New("usage: user delete <username>")
+	}
+
+	users, err := db.GetUser(args[0])
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return fmt.Errorf("user %s not found", args[0])
+	}
+
+	if db.Role(users[0].Role) > db.Admin {
+		return errors.New("super admin users cannot be deleted")
+	}
+
+	if err = db.DeleteUser(users[0].UUID); err != nil {
+		return err
+	}
+
+	fmt.Printf("user %s deleted\n", args[0])
+	return nil
+}
+
+func userList() error {
+	users, err := db.GetUsers()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "UUID\tUSERNAME\tROLE\tCREATED")
+	for _, u := range users {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", u.UUID, u.Username, u.Role, u.CreatedAt.Format(time.DateTime))
+	}
+	return w.Flush()
+}
+
+func migrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate <up|down|status>")
+	}
+
+	db.InitDB()
+
+	switch args[0] {
+	case "up":
+		return db.MigrateUp()
+	case "down":
+		return db.MigrateDown()
+	case "status":
+		return db.MigrationStatus()
+	default:
+		return fmt.Errorf("unknown migrate command: %s", args[0])
+	}
+}
+
+func cacheCommand(args []string) error {
+	if len(args) == 0 || args[0] != "prune" {
+		return errors.New("usage: cache prune")
+	}
+
+	cache.InitPhysicalCache()
+	removed := cache.PruneCacheFS()
+
+	fmt.Printf("removed %d cached galleries\n", removed)
+	return nil
+}
+
+// backup writes a copy of the database. Defaults to <MTSU_DATA_PATH>/backups/<db name>-<timestamp>.sqlite.
+func backup(args []string) error {
+	initStorage()
+
+	dst := ""
+	if len(args) > 0 {
+		dst = args[0]
+	} else {
+		backupDir := config.BuildDataPath("backups")
+		if err := os.MkdirAll(backupDir, os.ModePerm); err != nil {
+			return err
+		}
+		filename := config.Options.DB.Name + "-" + time.Now().Format("20060102-150405") + ".sqlite"
+		dst = config.BuildPath(backupDir, filename)
+	}
+
+	if utils.PathExists(dst) {
+		return fmt.Errorf("%s already exists", dst)
+	}
+
+	if err := db.Backup(dst); err != nil {
+		return err
+	}
+
+	fmt.Printf("database backed up to %s\n", dst)
+	return nil
+}
+
+// passwordArg returns the password from the arguments, or reads it from stdin so that it won't end up in the shell history.
+func passwordArg(args []string) (string, error) {
+	password := ""
+	if len(args) > 0 {
+		password = args[0]
+	} else {
+		fmt.Print("password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		password = strings.TrimSpace(line)
+	}
+
+	if !utils.IsValidPassword(password) {
+		return "", errors.New("password not valid")
+	}
+	return password, nil
+}
@@ -1,6 +1,11 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/Mangatsu/server/internal/config"
@@ -8,7 +13,9 @@ import (
 	"github.com/Mangatsu/server/pkg/cache"
 	"github.com/Mangatsu/server/pkg/db"
 	"github.com/Mangatsu/server/pkg/log"
+	"github.com/Mangatsu/server/pkg/metadata"
 	"github.com/Mangatsu/server/pkg/utils"
+	"github.com/Mangatsu/server/pkg/webhook"
 	"go.uber.org/zap"
 )
 
@@ -16,9 +23,67 @@ func main() {
 	config.LoadEnv()
 	log.InitializeLogger(config.AppEnvironment, config.LogLevel)
 	config.SetEnv()
+
+	// Without arguments, the server is launched as before.
+	name := "serve"
+	args := os.Args[1:]
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+
+	if err := runCommand(name, args); err != nil {
+		fmt.Fprintln(os.Stderr, "error: "+err.Error())
+		os.Exit(1)
+	}
+}
+
+// initStorage initializes the cache directories and the database, and applies the migrations.
+func initStorage() {
 	cache.InitPhysicalCache()
 	db.InitDB()
 	db.EnsureLatestVersion()
+}
+
+// storeLibraries seeds the libraries from the environmental to the db.
+func storeLibraries() {
+	libraries := config.ParseBasePaths()
+	if err := db.StorePaths(libraries); err != nil {
+		log.Z.Fatal("error saving library to db: ", zap.String("err", err.Error()))
+	}
+}
+
+// signalContext returns a context that is cancelled on SIGINT or SIGTERM, so that the running jobs can stop cleanly.
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// reloadOnHangup reloads the configuration on SIGHUP until the context is cancelled.
+func reloadOnHangup(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hangup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangup:
+				if err := config.Reload(); err != nil {
+					log.Z.Error("configuration not reloaded", zap.String("err", err.Error()))
+				}
+			}
+		}
+	}()
+}
+
+// serve launches the API server and the periodic tasks, and shuts them down gracefully on SIGINT or SIGTERM.
+// The configuration is reloaded on SIGHUP.
+func serve(_ []string) error {
+	ctx, stop := signalContext()
+	defer stop()
+
+	initStorage()
 
 	username, password := config.GetInitialAdmin()
 	users, err := db.GetUser(username)
@@ -32,18 +97,36 @@ func main() {
 		}
 	}
 
-	// Parse libraries from the environmental and insert/update to the db.
-	libraries := config.ParseBasePaths()
-	if err = db.StorePaths(libraries); err != nil {
-		log.Z.Fatal("error saving library to db: ", zap.String("err", err.Error()))
-	}
+	storeLibraries()
 
 	cache.InitGalleryCache()
 	cache.InitProcessingStatusCache()
+	metadata.InitProviders()
 
 	// Tasks
 	utils.PeriodicTask(time.Minute, cache.PruneCache)
 	utils.PeriodicTask(time.Minute, db.PruneExpiredSessions)
+	utils.PeriodicTask(time.Hour, db.PruneExpiredAPITokens)
+	utils.PeriodicTask(time.Hour, db.PruneLoginAttempts)
+	utils.PeriodicTask(time.Hour, db.PruneInvites)
+	utils.PeriodicTask(time.Hour, db.PruneWebhookDeliveries)
+	utils.RunJob(webhook.Run)
+
+	reloadOnHangup(ctx)
+	api.LaunchAPI(ctx)
+	shutdown()
+	return nil
+}
+
+// shutdown cancels the background jobs, waits for them to finish and closes the database.
+func shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), config.Options.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := utils.StopJobs(ctx); err != nil {
+		log.Z.Warn("background jobs did not finish before the shutdown timeout", zap.String("err", err.Error()))
+	}
 
-	api.LaunchAPI()
+	db.Close()
+	log.Z.Info("server stopped")
 }
@@ -3,8 +3,8 @@ package config
 import (
 	"fmt"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Mangatsu/server/pkg/log"
@@ -39,65 +39,120 @@ type CacheOptions struct {
 	WebServer bool
 	TTL       time.Duration
 	Size      uint64
+	// URLTTL is how long the signed URLs to the cached files are valid.
+	URLTTL time.Duration
 }
 
 type GalleryOptions struct {
 	ThumbnailFormat       ImageFormat
 	FuzzySearchSimilarity float64
+	FuzzyAutoAccept       float64
 	LTR                   bool
+	// TrashPath is where archives of deleted galleries are moved to. If empty, archives are left in place.
+	TrashPath string
+}
+
+// ProviderOptions stores the configuration of a single online metadata provider.
+type ProviderOptions struct {
+	URL       string
+	SearchURL string
+	FetchURL  string
+	Token     string
+}
+
+type MetadataOptions struct {
+	// Providers are the enabled online metadata providers by their name.
+	Providers map[string]ProviderOptions
 }
 
 type OptionsModel struct {
-	Environment    log.Environment
-	Domain         string
-	Hostname       string
-	Port           string
-	Secure         bool
-	SameSiteMode   http.SameSite
-	StrictACAO     bool
-	Registrations  bool
-	Visibility     Visibility
-	DB             DBOptions
-	Cache          CacheOptions
-	GalleryOptions GalleryOptions
+	Environment     log.Environment
+	Domain          string
+	Hostname        string
+	Port            string
+	Secure          bool
+	SameSiteMode    http.SameSite
+	StrictACAO      bool
+	Registrations   bool
+	RequireAdmin2FA bool
+	RequireApproval bool
+	TrustProxy      bool
+	Visibility      Visibility
+	DB              DBOptions
+	Cache           CacheOptions
+	GalleryOptions  GalleryOptions
+	Metadata        MetadataOptions
+	OIDC            OIDCOptions
+	Login           LoginOptions
+	Metrics         MetricsOptions
+	Server          ServerOptions
+	TLS             TLSOptions
 }
 
 type CredentialsModel struct {
-	JWTSecret  string
-	Passphrase string
+	JWTSecret        string
+	Passphrase       string
+	OIDCClientSecret string
+	MetricsToken     string
 }
 
 var AppEnvironment log.Environment
 var LogLevel zapcore.Level
 
-// Options stores the global configuration for the server
+// Options stores the global configuration for the server. Visibility, Registrations, the fuzzy search similarity and
+// the cache TTL may be changed by Reload, so they are read with Options.Reloadable().
 var Options *OptionsModel
 
 // Credentials stores the JWT secret, and optionally a passphrase and credentials for the db
 var Credentials *CredentialsModel // TODO: Encrypt in memory?
 
-// LoadEnv loads the environment variables.
+// dataPath is read once on start, as the data cannot be moved at runtime.
+var dataPath string
+
+// LoadEnv loads the environment variables and the optional config file set with MTSU_CONFIG_FILE.
+// Environment variables override the values in the config file.
 func LoadEnv() {
 	var err = godotenv.Load()
 	if err != nil {
 		fmt.Println("No .env file or environmentals found.")
 	}
 
+	loadConfigFile()
 	loadEnvironment()
 	loadLogLevel()
+	dataPath = getValue("MTSU_DATA_PATH")
 }
 
-// SetEnv sets the environment variables into Options and Credentials
+// SetEnv sets the environment variables into Options and Credentials. Exits if any value is invalid, after logging
+// every problem found.
 func SetEnv() {
-	Options = &OptionsModel{
-		Domain:        domain(),
-		Hostname:      hostname(),
-		Port:          port(),
-		Secure:        secure(),
-		SameSiteMode:  sameSiteMode(),
-		StrictACAO:    acao(),
-		Registrations: registrationsEnabled(),
-		Visibility:    currentVisibility(),
+	options, credentials := parseOptions()
+	if err := takeProblems(); err != nil {
+		for _, problem := range err.Problems {
+			log.Z.Error(problem)
+		}
+		log.Z.Fatal("invalid configuration", zap.Int("problems", len(err.Problems)))
+	}
+
+	Options = options
+	Credentials = credentials
+}
+
+// parseOptions parses the options and credentials. Problems are collected to problems.
+func parseOptions() (*OptionsModel, *CredentialsModel) {
+	secure := secure()
+	options := &OptionsModel{
+		Domain:          domain(),
+		Hostname:        hostname(),
+		Port:            port(),
+		Secure:          secure,
+		SameSiteMode:    sameSiteMode(secure),
+		StrictACAO:      acao(),
+		Registrations:   registrationsEnabled(),
+		RequireAdmin2FA: requireAdmin2FA(),
+		RequireApproval: requireApproval(),
+		TrustProxy:      trustProxy(),
+		Visibility:      currentVisibility(),
 		DB: DBOptions{
 			Name:       dbName(),
 			Migrations: dbMigrationsEnabled(),
@@ -106,23 +161,42 @@ func SetEnv() {
 			WebServer: cacheServerEnabled(),
 			TTL:       cacheTTL(),
 			Size:      cacheSize(),
+			URLTTL:    cacheURLTTL(),
 		},
 		GalleryOptions: GalleryOptions{
 			ThumbnailFormat:       thumbnailFormat(),
 			FuzzySearchSimilarity: fuzzySearchSimilarity(),
+			FuzzyAutoAccept:       fuzzyAutoAccept(),
 			LTR:                   defaultLTR(),
+			TrashPath:             trashPath(),
+		},
+		Metadata: MetadataOptions{
+			Providers: metadataProviders(),
 		},
+		OIDC:    oidcOptions(),
+		Login:   loginOptions(),
+		Metrics: metricsOptions(),
+		Server:  serverOptions(),
+		TLS:     tlsOptions(),
 	}
 
-	Credentials = &CredentialsModel{
-		JWTSecret:  jwtSecret(),
-		Passphrase: restrictedPassphrase(),
+	credentials := &CredentialsModel{
+		JWTSecret:        jwtSecret(),
+		Passphrase:       restrictedPassphrase(),
+		OIDCClientSecret: oidcClientSecret(),
+		MetricsToken:     metricsToken(),
 	}
+
+	if options.Visibility == Restricted && credentials.Passphrase == "" {
+		invalid("MTSU_RESTRICTED_PASSPHRASE is required when MTSU_VISIBILITY is restricted")
+	}
+
+	return options, credentials
 }
 
 func GetInitialAdmin() (string, string) {
-	username := os.Getenv("MTSU_INITIAL_ADMIN_NAME")
-	password := os.Getenv("MTSU_INITIAL_ADMIN_PW")
+	username := getValue("MTSU_INITIAL_ADMIN_NAME")
+	password := getValue("MTSU_INITIAL_ADMIN_PW")
 	if username == "" {
 		username = "admin"
 	}
@@ -133,16 +207,20 @@ func GetInitialAdmin() (string, string) {
 }
 
 func loadEnvironment() {
-	value := os.Getenv("MTSU_ENV")
-	if value == "production" {
+	value := getValue("MTSU_ENV")
+	switch value {
+	case "production":
 		AppEnvironment = log.Production
-		return
+	case "", "development":
+		AppEnvironment = log.Development
+	default:
+		invalid(value + " is not a valid environment for MTSU_ENV. Valid: production, development")
+		AppEnvironment = log.Development
 	}
-	AppEnvironment = log.Development
 }
 
 func loadLogLevel() {
-	value := os.Getenv("MTSU_LOG_LEVEL")
+	value := getValue("MTSU_LOG_LEVEL")
 	switch value {
 	case "debug":
 		LogLevel = zap.DebugLevel
@@ -150,17 +228,20 @@ func loadLogLevel() {
 		LogLevel = zap.WarnLevel
 	case "error":
 		LogLevel = zap.ErrorLevel
+	case "", "info":
+		LogLevel = zap.InfoLevel
 	default:
+		invalid(value + " is not a valid level for MTSU_LOG_LEVEL. Valid: debug, info, warn, error")
 		LogLevel = zap.InfoLevel
 	}
 }
 
 func domain() string {
-	return os.Getenv("MTSU_DOMAIN")
+	return getValue("MTSU_DOMAIN")
 }
 
 func hostname() string {
-	value := os.Getenv("MTSU_HOSTNAME")
+	value := getValue("MTSU_HOSTNAME")
 	if value == "" {
 		return "localhost"
 	}
@@ -168,125 +249,105 @@ func hostname() string {
 }
 
 func port() string {
-	value := os.Getenv("MTSU_PORT")
+	value := getValue("MTSU_PORT")
 	if value == "" {
 		return "5050"
 	}
+
+	if parsed, err := strconv.Atoi(value); err != nil || parsed < 1 || parsed > 65535 {
+		invalid(value + " is not a valid port for MTSU_PORT")
+	}
 	return value
 }
 
+// secure defaults to true with the built-in TLS.
 func secure() bool {
-	return os.Getenv("MTSU_SECURE") == "true"
+	return boolValue("MTSU_SECURE", tlsOptions().Enabled())
 }
 
-func sameSiteMode() http.SameSite {
-	if secure() {
+func sameSiteMode(secure bool) http.SameSite {
+	if secure {
 		return http.SameSiteNoneMode
 	}
 	return http.SameSiteLaxMode
 }
 
 func acao() bool {
-	return os.Getenv("MTSU_STRICT_ACAO") == "true"
+	return boolValue("MTSU_STRICT_ACAO", false)
 }
 
 func cacheServerEnabled() bool {
-	value := os.Getenv("MTSU_DISABLE_CACHE_SERVER")
-	if value == "true" {
-		return false
-	}
-	return true
+	return !boolValue("MTSU_DISABLE_CACHE_SERVER", false)
 }
 
 func registrationsEnabled() bool {
-	value := os.Getenv("MTSU_REGISTRATIONS")
-	if value == "true" {
-		return true
-	}
-	return false
+	return boolValue("MTSU_REGISTRATIONS", false)
+}
+
+func requireApproval() bool {
+	return boolValue("MTSU_REQUIRE_APPROVAL", false)
+}
+
+func requireAdmin2FA() bool {
+	return boolValue("MTSU_REQUIRE_ADMIN_2FA", false)
 }
 
 func currentVisibility() Visibility {
-	value := os.Getenv("MTSU_VISIBILITY")
+	value := getValue("MTSU_VISIBILITY")
 	switch value {
 	case "public":
 		return Public
 	case "restricted":
 		return Restricted
+	case "", "private":
+		return Private
 	default:
+		invalid(value + " is not a valid visibility for MTSU_VISIBILITY. Valid: private, restricted, public")
 		return Private
 	}
 }
 
 func restrictedPassphrase() string {
-	value := os.Getenv("MTSU_RESTRICTED_PASSPHRASE")
-	if value == "" {
-		if currentVisibility() == Restricted {
-			log.Z.Warn("MTSU_RESTRICTED_PASSPHRASE is not set. Defaulting to 's3cr3t'.")
-		}
-		return "s3cr3t"
-	}
-	return value
+	return getValue("MTSU_RESTRICTED_PASSPHRASE")
 }
 
 func jwtSecret() string {
-	value := os.Getenv("MTSU_JWT_SECRET")
+	value := getValue("MTSU_JWT_SECRET")
 	if value == "" {
-		log.Z.Error("MTSU_JWT_SECRET is not set. An unsecure secret will be used instead. DO NOT USE IN PRODUCTION.")
-		return "9Wag7sMvKl3aF6K5lwIg6TI42ia2f6BstZAVrdJIq8Mp38lnl7UzQMC1qjKyZCBzHFGbbqsA0gKcHqDuyXQAhWoJ0lcx4K5q"
+		invalid("MTSU_JWT_SECRET is not set")
 	}
 	return value
 }
 
 func cacheTTL() time.Duration {
-	defaultDuration := time.Hour * 336
-	minDuration := time.Minute * 15
-
-	value := os.Getenv("MTSU_CACHE_TTL")
-	if value == "" {
-		return defaultDuration
-	}
-
-	duration, err := time.ParseDuration(value)
-	if err != nil {
-		log.Z.Error(value + " is not a valid TTL for MTSU_CACHE_TTL. Defaulting to 336h (14 days).")
-		return defaultDuration
-	}
-
-	if duration < minDuration {
-		log.Z.Info("Minimum TTL is 15 minutes. Defaulting to 15 minutes.")
-		return minDuration
-	}
-
-	return duration
+	return boundedDuration("MTSU_CACHE_TTL", time.Hour*336, time.Minute*15)
 }
 
 func cacheSize() uint64 {
 	defaultSize := uint64(20000)
 	minSize := uint64(100)
 
-	value := os.Getenv("MTSU_CACHE_SIZE")
+	value := getValue("MTSU_CACHE_SIZE")
 	if value == "" {
 		return defaultSize
 	}
 
 	size, err := strconv.ParseUint(value, 10, 64)
-	if err != nil {
-		log.Z.Error(value + " is not a valid TTL for MTSU_CACHE_SIZE. Defaulting to 20 000 MiB.")
+	if err != nil || size < minSize {
+		invalid(value + " is not a valid size for MTSU_CACHE_SIZE. Minimum is 100 MiB")
 		return defaultSize
 	}
 
-	if size < minSize {
-		log.Z.Warn("Minimum TTL is 100 MiB. Defaulting to 100 MiB.")
-		return minSize
-	}
-
 	return size
 }
 
+func cacheURLTTL() time.Duration {
+	return boundedDuration("MTSU_CACHE_URL_TTL", time.Hour*24, time.Minute*5)
+}
+
 func thumbnailFormat() ImageFormat {
 	// TODO: Add support for AVIF
-	//value := os.Getenv("MTSU_THUMBNAIL_FORMAT")
+	//value := getValue("MTSU_THUMBNAIL_FORMAT")
 	//if value == "avif" {
 	//	return AVIF
 	//}
@@ -294,29 +355,91 @@ func thumbnailFormat() ImageFormat {
 }
 
 func fuzzySearchSimilarity() float64 {
-	value := os.Getenv("MTSU_FUZZY_SEARCH_SIMILARITY")
+	return similarityValue("MTSU_FUZZY_SEARCH_SIMILARITY", 0.7)
+}
+
+func fuzzyAutoAccept() float64 {
+	return similarityValue("MTSU_FUZZY_AUTO_ACCEPT", 0.9)
+}
+
+func defaultLTR() bool {
+	return boolValue("MTSU_LTR", true)
+}
+
+func trashPath() string {
+	return getValue("MTSU_TRASH_PATH")
+}
+
+// metadataProviders parses the enabled metadata providers and their options.
+// Options are read from MTSU_PROVIDER_<NAME>_URL, _SEARCH_URL, _FETCH_URL and _TOKEN.
+func metadataProviders() map[string]ProviderOptions {
+	providers := make(map[string]ProviderOptions)
+
+	value := getValue("MTSU_METADATA_PROVIDERS")
 	if value == "" {
-		return 0.7
+		return providers
 	}
 
-	similarity, err := strconv.ParseFloat(value, 64)
-	if err != nil {
-		log.Z.Error(value + " is not a valid similarity value for MTSU_FUZZY_SEARCH_SIMILARITY. Defaulting to 0.7.")
-		return 0.7
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "MTSU_PROVIDER_" + strings.ToUpper(name)
+		providers[name] = ProviderOptions{
+			URL:       getValue(prefix + "_URL"),
+			SearchURL: getValue(prefix + "_SEARCH_URL"),
+			FetchURL:  getValue(prefix + "_FETCH_URL"),
+			Token:     getValue(prefix + "_TOKEN"),
+		}
 	}
-	if similarity < 0.1 {
-		return 0.1
+
+	return providers
+}
+
+func boolValue(key string, defaultValue bool) bool {
+	switch value := getValue(key); value {
+	case "":
+		return defaultValue
+	case "true":
+		return true
+	case "false":
+		return false
+	default:
+		invalid(value + " is not a valid value for " + key + ". Valid: true, false")
+		return defaultValue
 	}
-	if similarity > 1 {
-		return 1
+}
+
+// boundedDuration parses a duration of at least minDuration.
+func boundedDuration(key string, defaultDuration time.Duration, minDuration time.Duration) time.Duration {
+	value := getValue(key)
+	if value == "" {
+		return defaultDuration
 	}
-	return similarity
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < minDuration {
+		invalid(value + " is not a valid duration for " + key + ". Minimum is " + minDuration.String())
+		return defaultDuration
+	}
+
+	return duration
 }
 
-func defaultLTR() bool {
-	value := os.Getenv("MTSU_LTR")
-	if value == "false" {
-		return false
+// similarityValue parses a similarity between 0.1 and 1.
+func similarityValue(key string, defaultValue float64) float64 {
+	value := getValue(key)
+	if value == "" {
+		return defaultValue
 	}
-	return true
+
+	similarity, err := strconv.ParseFloat(value, 64)
+	if err != nil || similarity < 0.1 || similarity > 1 {
+		invalid(value + " is not a valid similarity for " + key + ". Valid: 0.1 - 1")
+		return defaultValue
+	}
+
+	return similarity
 }
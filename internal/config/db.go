@@ -1,9 +1,5 @@
 package config
 
-import (
-	"os"
-)
-
 type DBOptions struct {
 	Name       string
 	Migrations bool
@@ -11,11 +7,11 @@ type DBOptions struct {
 
 func dbMigrationsEnabled() bool {
 	// Disabled only when explicitly set to false.
-	return os.Getenv("MTSU_DB_MIGRATIONS") != "false"
+	return getValue("MTSU_DB_MIGRATIONS") != "false"
 }
 
 func dbName() string {
-	value := os.Getenv("MTSU_DB_NAME")
+	value := getValue("MTSU_DB_NAME")
 	if value == "" {
 		return "mangatsu"
 	}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileValues stores the values of the config file by their environmental, such as MTSU_VISIBILITY.
+var fileValues atomic.Pointer[map[string]string]
+
+// problems collects the invalid configuration values, so that all of them can be reported at once.
+var problems []string
+
+var providerKeyR = regexp.MustCompile(`^MTSU_PROVIDER_[A-Z0-9]+_(URL|SEARCH_URL|FETCH_URL|TOKEN)$`)
+
+// knownKeys are the environmentals that can be set in the config file. Provider options are matched by providerKeyR.
+var knownKeys = []string{
+	"MTSU_ENV", "MTSU_LOG_LEVEL", "MTSU_INITIAL_ADMIN_NAME", "MTSU_INITIAL_ADMIN_PW", "MTSU_DOMAIN",
+	"MTSU_STRICT_ACAO", "MTSU_HOSTNAME", "MTSU_PORT", "MTSU_SECURE", "MTSU_READ_TIMEOUT", "MTSU_WRITE_TIMEOUT",
+	"MTSU_IDLE_TIMEOUT", "MTSU_SHUTDOWN_TIMEOUT", "MTSU_TLS_CERT", "MTSU_TLS_KEY", "MTSU_TLS_REDIRECT_ADDRESS",
+	"MTSU_BASE_PATHS", "MTSU_DATA_PATH", "MTSU_DISABLE_CACHE_SERVER", "MTSU_CACHE_TTL", "MTSU_CACHE_URL_TTL",
+	"MTSU_CACHE_SIZE", "MTSU_DB_NAME", "MTSU_DB_MIGRATIONS", "MTSU_VISIBILITY", "MTSU_RESTRICTED_PASSPHRASE",
+	"MTSU_REGISTRATIONS", "MTSU_REQUIRE_APPROVAL", "MTSU_REQUIRE_ADMIN_2FA", "MTSU_LOGIN_FREE_ATTEMPTS",
+	"MTSU_LOGIN_MAX_BACKOFF", "MTSU_LOGIN_LOCKOUT_THRESHOLD", "MTSU_LOGIN_LOCKOUT_DURATION", "MTSU_TRUST_PROXY",
+	"MTSU_JWT_SECRET", "MTSU_THUMBNAIL_FORMAT", "MTSU_FUZZY_SEARCH_SIMILARITY", "MTSU_FUZZY_AUTO_ACCEPT", "MTSU_LTR",
+	"MTSU_TRASH_PATH", "MTSU_METADATA_PROVIDERS", "MTSU_OIDC_ISSUER", "MTSU_OIDC_CLIENT_ID",
+	"MTSU_OIDC_CLIENT_SECRET", "MTSU_OIDC_REDIRECT_URL", "MTSU_OIDC_SCOPES", "MTSU_OIDC_GROUPS_CLAIM",
+	"MTSU_OIDC_ROLE_MAPPING", "MTSU_OIDC_DEFAULT_ROLE", "MTSU_OIDC_LOGIN_REDIRECT", "MTSU_METRICS_TOKEN",
+	"MTSU_METRICS_ADDRESS",
+}
+
+// ConfigFile returns the path of the config file set with MTSU_CONFIG_FILE. Empty if not set.
+func ConfigFile() string {
+	return os.Getenv("MTSU_CONFIG_FILE")
+}
+
+// loadConfigFile reads the config file into fileValues. Problems with the file are collected to problems.
+func loadConfigFile() {
+	values := readConfigFile(ConfigFile())
+	fileValues.Store(&values)
+}
+
+// readConfigFile reads a YAML config file of keys and scalar values. Keys are the environmentals in lower case without
+// the MTSU_ prefix, such as visibility for MTSU_VISIBILITY. Returns an empty map if name is empty.
+func readConfigFile(name string) map[string]string {
+	values := make(map[string]string)
+	if name == "" {
+		return values
+	}
+
+	content, err := os.ReadFile(name)
+	if err != nil {
+		invalid("could not read the config file: " + err.Error())
+		return values
+	}
+
+	var document yaml.Node
+	if err = yaml.Unmarshal(content, &document); err != nil {
+		invalid("could not parse the config file: " + err.Error())
+		return values
+	}
+	if len(document.Content) == 0 {
+		return values
+	}
+
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		invalid("the config file must be a mapping of keys and values")
+		return values
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		keyNode, valueNode := root.Content[i], root.Content[i+1]
+		key := "MTSU_" + strings.ToUpper(keyNode.Value)
+
+		if !knownKey(key) {
+			invalid(fmt.Sprintf("unknown key %s in the config file (line %d)", keyNode.Value, keyNode.Line))
+			continue
+		}
+		if valueNode.Kind != yaml.ScalarNode {
+			invalid(fmt.Sprintf("%s in the config file must be a single value (line %d)", keyNode.Value, keyNode.Line))
+			continue
+		}
+		if valueNode.Tag == "!!null" {
+			continue
+		}
+
+		values[key] = valueNode.Value
+	}
+
+	return values
+}
+
+func knownKey(key string) bool {
+	return slices.Contains(knownKeys, key) || providerKeyR.MatchString(key)
+}
+
+// lookupValue returns the value of the environmental. If it is not set, the value in the config file is returned.
+func lookupValue(key string) (string, bool) {
+	if value, found := os.LookupEnv(key); found {
+		return value, true
+	}
+
+	if values := fileValues.Load(); values != nil {
+		value, found := (*values)[key]
+		return value, found
+	}
+
+	return "", false
+}
+
+// getValue returns the value of the environmental or the config file. Empty if set in neither.
+func getValue(key string) string {
+	value, _ := lookupValue(key)
+	return value
+}
+
+// invalid records a problem in the configuration. Duplicates are ignored, as some values are parsed more than once.
+func invalid(problem string) {
+	if !slices.Contains(problems, problem) {
+		problems = append(problems, problem)
+	}
+}
+
+// ValidationError lists every problem found in the configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// takeProblems returns the collected problems as a ValidationError, or nil if there are none, and clears them.
+func takeProblems() *ValidationError {
+	if len(problems) == 0 {
+		return nil
+	}
+
+	err := &ValidationError{Problems: problems}
+	problems = nil
+	return err
+}
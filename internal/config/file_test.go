@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+
+	name := filepath.Join(t.TempDir(), "mangatsu.yml")
+	if err := os.WriteFile(name, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return name
+}
+
+func TestConfigFileUnderEnvironment(t *testing.T) {
+	t.Setenv("MTSU_CONFIG_FILE", writeConfigFile(t, "visibility: public\ncache_ttl: 1h\njwt_secret: secret\n"))
+	t.Setenv("MTSU_CACHE_TTL", "2h")
+	t.Cleanup(func() { problems = nil })
+
+	loadConfigFile()
+	options, credentials := parseOptions()
+	if err := takeProblems(); err != nil {
+		t.Fatal(err)
+	}
+
+	if options.Visibility != Public {
+		t.Errorf("Expected the visibility from the file, got %s", options.Visibility)
+	}
+	if options.Cache.TTL != time.Hour*2 {
+		t.Errorf("Expected the environmental to override the file, got %s", options.Cache.TTL)
+	}
+	if credentials.JWTSecret != "secret" {
+		t.Errorf("Expected the JWT secret from the file, got %s", credentials.JWTSecret)
+	}
+}
+
+func TestValidationReportsEveryProblem(t *testing.T) {
+	t.Setenv("MTSU_CONFIG_FILE", writeConfigFile(t, "visibility: secret\nunknown_option: true\nregistrations: yes\n"))
+	t.Setenv("MTSU_JWT_SECRET", "")
+	t.Setenv("MTSU_FUZZY_SEARCH_SIMILARITY", "2")
+	t.Cleanup(func() { problems = nil })
+
+	loadConfigFile()
+	parseOptions()
+	err := takeProblems()
+	if err == nil {
+		t.Fatal("Expected problems with the configuration")
+	}
+
+	expected := []string{
+		"unknown key unknown_option in the config file (line 2)",
+		"secret is not a valid visibility for MTSU_VISIBILITY. Valid: private, restricted, public",
+		"yes is not a valid value for MTSU_REGISTRATIONS. Valid: true, false",
+		"MTSU_JWT_SECRET is not set",
+		"2 is not a valid similarity for MTSU_FUZZY_SEARCH_SIMILARITY. Valid: 0.1 - 1",
+	}
+	for _, problem := range expected {
+		if !slices.Contains(err.Problems, problem) {
+			t.Errorf("Expected problem %q in %q", problem, err.Problems)
+		}
+	}
+}
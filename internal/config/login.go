@@ -0,0 +1,61 @@
+package config
+
+import (
+	"strconv"
+	"time"
+)
+
+// LoginOptions stores the limits for failed logins.
+type LoginOptions struct {
+	// FreeAttempts is how many failed attempts an IP or username gets before the backoff starts.
+	FreeAttempts int
+	// MaxBackoff caps the exponentially growing wait between failed attempts.
+	MaxBackoff time.Duration
+	// LockoutThreshold is how many consecutive failed logins lock the account. 0 disables the lockout.
+	LockoutThreshold int
+	// LockoutDuration is how long the account stays locked.
+	LockoutDuration time.Duration
+}
+
+func loginOptions() LoginOptions {
+	return LoginOptions{
+		FreeAttempts:     intEnv("MTSU_LOGIN_FREE_ATTEMPTS", 5),
+		MaxBackoff:       durationEnv("MTSU_LOGIN_MAX_BACKOFF", time.Minute*15),
+		LockoutThreshold: intEnv("MTSU_LOGIN_LOCKOUT_THRESHOLD", 10),
+		LockoutDuration:  durationEnv("MTSU_LOGIN_LOCKOUT_DURATION", time.Minute*15),
+	}
+}
+
+func trustProxy() bool {
+	return boolValue("MTSU_TRUST_PROXY", false)
+}
+
+func intEnv(key string, defaultValue int) int {
+	value := getValue(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		invalid(value + " is not a valid value for " + key + ". Must be a non-negative integer")
+		return defaultValue
+	}
+
+	return parsed
+}
+
+func durationEnv(key string, defaultDuration time.Duration) time.Duration {
+	value := getValue(key)
+	if value == "" {
+		return defaultDuration
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < time.Second {
+		invalid(value + " is not a valid duration for " + key + ". Minimum is 1s")
+		return defaultDuration
+	}
+
+	return duration
+}
@@ -0,0 +1,23 @@
+package config
+
+// MetricsOptions stores the configuration of the Prometheus metrics. The token is in Credentials.
+type MetricsOptions struct {
+	// Address is a separate listen address for the metrics, such as 127.0.0.1:9090.
+	// If empty, the metrics are served by the API and require the token.
+	Address string
+	// Enabled is true if the metrics are protected by a token or served on a separate address.
+	Enabled bool
+}
+
+func metricsOptions() MetricsOptions {
+	address := getValue("MTSU_METRICS_ADDRESS")
+
+	return MetricsOptions{
+		Address: address,
+		Enabled: address != "" || metricsToken() != "",
+	}
+}
+
+func metricsToken() string {
+	return getValue("MTSU_METRICS_TOKEN")
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"strings"
+)
+
+// OIDCOptions stores the configuration of the OpenID Connect login. The client secret is in Credentials.
+type OIDCOptions struct {
+	Issuer      string
+	ClientID    string
+	RedirectURL string
+	Scopes      []string
+	// GroupsClaim is the claim of the ID token listing the groups of the user.
+	GroupsClaim string
+	// RoleMapping maps the groups to role names. The highest matching role is given to the user.
+	RoleMapping map[string]string
+	// DefaultRole is given to users without a mapped group. If empty, they cannot log in.
+	DefaultRole string
+	// LoginRedirect is where the browser is sent after a successful login.
+	LoginRedirect string
+}
+
+// Enabled returns true if the OpenID Connect login is configured.
+func (o OIDCOptions) Enabled() bool {
+	return o.Issuer != "" && o.ClientID != ""
+}
+
+func oidcOptions() OIDCOptions {
+	options := OIDCOptions{
+		Issuer:        strings.TrimSuffix(getValue("MTSU_OIDC_ISSUER"), "/"),
+		ClientID:      getValue("MTSU_OIDC_CLIENT_ID"),
+		RedirectURL:   getValue("MTSU_OIDC_REDIRECT_URL"),
+		Scopes:        []string{"openid", "profile", "email", "groups"},
+		GroupsClaim:   "groups",
+		RoleMapping:   map[string]string{},
+		DefaultRole:   "viewer",
+		LoginRedirect: "/",
+	}
+
+	if value := getValue("MTSU_OIDC_SCOPES"); value != "" {
+		options.Scopes = strings.Fields(strings.ReplaceAll(value, ",", " "))
+	}
+
+	if value := getValue("MTSU_OIDC_GROUPS_CLAIM"); value != "" {
+		options.GroupsClaim = value
+	}
+
+	// Format: group=role,other group=role
+	for _, pair := range strings.Split(getValue("MTSU_OIDC_ROLE_MAPPING"), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		group, role, found := strings.Cut(pair, "=")
+		if !found || strings.TrimSpace(group) == "" {
+			invalid(pair + " is not a valid group=role pair in MTSU_OIDC_ROLE_MAPPING")
+			continue
+		}
+		options.RoleMapping[strings.TrimSpace(group)] = strings.ToLower(strings.TrimSpace(role))
+	}
+
+	if value, found := lookupValue("MTSU_OIDC_DEFAULT_ROLE"); found {
+		options.DefaultRole = strings.ToLower(strings.TrimSpace(value))
+		if options.DefaultRole == "none" {
+			options.DefaultRole = ""
+		}
+	}
+
+	if value := getValue("MTSU_OIDC_LOGIN_REDIRECT"); value != "" {
+		options.LoginRedirect = value
+	}
+
+	if options.Enabled() && options.RedirectURL == "" {
+		invalid("MTSU_OIDC_REDIRECT_URL is required for the OpenID Connect login")
+	}
+
+	return options
+}
+
+func oidcClientSecret() string {
+	return getValue("MTSU_OIDC_CLIENT_SECRET")
+}
@@ -21,10 +21,13 @@ type Library struct {
 
 var libraryOptionsR = regexp.MustCompile(`^(freeform|structured)(\d+)$`)
 
+// ParseBasePaths parses the libraries in MTSU_BASE_PATHS. They are only used to seed the database,
+// so the variable is optional and missing paths are skipped.
 func ParseBasePaths() []Library {
-	basePaths := os.Getenv("MTSU_BASE_PATHS")
+	basePaths := getValue("MTSU_BASE_PATHS")
 	if basePaths == "" {
-		log.Z.Fatal("MTSU_BASE_PATHS is not set")
+		log.Z.Info("MTSU_BASE_PATHS is not set. Libraries can be added through the API.")
+		return nil
 	}
 
 	basePathsSlice := strings.Split(basePaths, ";;")
@@ -44,7 +47,8 @@ func ParseBasePaths() []Library {
 			log.Z.Fatal("Paths in MTSU_BASE_PATHS cannot be empty")
 		}
 		if _, err := os.Stat(layoutAndPath[1]); errors.Is(err, fs.ErrNotExist) {
-			log.Z.Fatal("Path in MTSU_BASE_PATHS not found: " + layoutAndPath[1])
+			log.Z.Warn("Path in MTSU_BASE_PATHS not found. Skipping: " + layoutAndPath[1])
+			continue
 		}
 
 		libraryOptions := libraryOptionsR.FindStringSubmatch(layoutAndPath[0])
@@ -78,9 +82,9 @@ func BuildLibraryPath(libraryPath string, pathParts ...string) string {
 }
 
 func BuildDataPath(pathParts ...string) string {
-	return BuildPath(os.Getenv("MTSU_DATA_PATH"), pathParts...)
+	return BuildPath(dataPath, pathParts...)
 }
 
 func BuildCachePath(pathParts ...string) string {
-	return BuildPath(os.Getenv("MTSU_DATA_PATH"), append([]string{"cache"}, pathParts...)...)
+	return BuildPath(dataPath, append([]string{"cache"}, pathParts...)...)
 }
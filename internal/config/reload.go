@@ -0,0 +1,175 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/log"
+	"go.uber.org/zap"
+)
+
+// ReloadableOptions are the options that can be changed at runtime with Reload.
+type ReloadableOptions struct {
+	Visibility            Visibility
+	Registrations         bool
+	FuzzySearchSimilarity float64
+	CacheTTL              time.Duration
+}
+
+// reloadMu serializes the reloads, and optionsMu guards the reloadable fields of Options.
+var reloadMu sync.Mutex
+var optionsMu sync.RWMutex
+
+// Reloadable returns the current values of the options that can be changed at runtime.
+func (o *OptionsModel) Reloadable() ReloadableOptions {
+	optionsMu.RLock()
+	defer optionsMu.RUnlock()
+
+	return o.reloadable()
+}
+
+func (o *OptionsModel) reloadable() ReloadableOptions {
+	return ReloadableOptions{
+		Visibility:            o.Visibility,
+		Registrations:         o.Registrations,
+		FuzzySearchSimilarity: o.GalleryOptions.FuzzySearchSimilarity,
+		CacheTTL:              o.Cache.TTL,
+	}
+}
+
+func (o *OptionsModel) setReloadable(options ReloadableOptions) {
+	o.Visibility = options.Visibility
+	o.Registrations = options.Registrations
+	o.GalleryOptions.FuzzySearchSimilarity = options.FuzzySearchSimilarity
+	o.Cache.TTL = options.CacheTTL
+}
+
+// Reload reads the config file and the environmentals again, and applies the reloadable options. If any value is
+// invalid, nothing is changed and a ValidationError listing the problems is returned. Changes to other options are
+// logged, as they require a restart.
+func Reload() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	previousValues := fileValues.Load()
+	loadConfigFile()
+
+	options, credentials := parseOptions()
+	if options.Visibility == Restricted && Credentials.Passphrase == "" {
+		invalid("MTSU_RESTRICTED_PASSPHRASE must be set on start to use the restricted visibility")
+	}
+	if err := takeProblems(); err != nil {
+		fileValues.Store(previousValues)
+		return err
+	}
+
+	reloadable := options.reloadable()
+
+	// Only the reloadable options are compared as equal, so that the rest needing a restart can be detected.
+	unchanged := *Options
+	unchanged.setReloadable(reloadable)
+	if !reflect.DeepEqual(unchanged, *options) || !reflect.DeepEqual(*Credentials, *credentials) {
+		log.Z.Warn("the configuration has changes that are applied only after a restart")
+	}
+
+	optionsMu.Lock()
+	Options.setReloadable(reloadable)
+	optionsMu.Unlock()
+
+	log.Z.Info("configuration reloaded",
+		zap.String("visibility", string(reloadable.Visibility)),
+		zap.Bool("registrations", reloadable.Registrations),
+		zap.Float64("fuzzySearchSimilarity", reloadable.FuzzySearchSimilarity),
+		zap.Duration("cacheTTL", reloadable.CacheTTL))
+
+	return nil
+}
+
+// Effective returns the configuration in use by the keys of the config file. Secrets are redacted.
+func Effective() map[string]string {
+	optionsMu.RLock()
+	o := *Options
+	optionsMu.RUnlock()
+
+	username, _ := GetInitialAdmin()
+	effective := map[string]string{
+		"env":                     string(AppEnvironment),
+		"log_level":               LogLevel.String(),
+		"initial_admin_name":      username,
+		"initial_admin_pw":        redact(getValue("MTSU_INITIAL_ADMIN_PW")),
+		"domain":                  o.Domain,
+		"strict_acao":             strconv.FormatBool(o.StrictACAO),
+		"hostname":                o.Hostname,
+		"port":                    o.Port,
+		"secure":                  strconv.FormatBool(o.Secure),
+		"read_timeout":            o.Server.ReadTimeout.String(),
+		"write_timeout":           o.Server.WriteTimeout.String(),
+		"idle_timeout":            o.Server.IdleTimeout.String(),
+		"shutdown_timeout":        o.Server.ShutdownTimeout.String(),
+		"tls_cert":                o.TLS.CertFile,
+		"tls_key":                 o.TLS.KeyFile,
+		"tls_redirect_address":    o.TLS.RedirectAddress,
+		"base_paths":              getValue("MTSU_BASE_PATHS"),
+		"data_path":               dataPath,
+		"disable_cache_server":    strconv.FormatBool(!o.Cache.WebServer),
+		"cache_ttl":               o.Cache.TTL.String(),
+		"cache_url_ttl":           o.Cache.URLTTL.String(),
+		"cache_size":              strconv.FormatUint(o.Cache.Size, 10),
+		"db_name":                 o.DB.Name,
+		"db_migrations":           strconv.FormatBool(o.DB.Migrations),
+		"visibility":              string(o.Visibility),
+		"restricted_passphrase":   redact(Credentials.Passphrase),
+		"registrations":           strconv.FormatBool(o.Registrations),
+		"require_approval":        strconv.FormatBool(o.RequireApproval),
+		"require_admin_2fa":       strconv.FormatBool(o.RequireAdmin2FA),
+		"login_free_attempts":     strconv.Itoa(o.Login.FreeAttempts),
+		"login_max_backoff":       o.Login.MaxBackoff.String(),
+		"login_lockout_threshold": strconv.Itoa(o.Login.LockoutThreshold),
+		"login_lockout_duration":  o.Login.LockoutDuration.String(),
+		"trust_proxy":             strconv.FormatBool(o.TrustProxy),
+		"jwt_secret":              redact(Credentials.JWTSecret),
+		"thumbnail_format":        string(o.GalleryOptions.ThumbnailFormat),
+		"fuzzy_search_similarity": strconv.FormatFloat(o.GalleryOptions.FuzzySearchSimilarity, 'f', -1, 64),
+		"fuzzy_auto_accept":       strconv.FormatFloat(o.GalleryOptions.FuzzyAutoAccept, 'f', -1, 64),
+		"ltr":                     strconv.FormatBool(o.GalleryOptions.LTR),
+		"trash_path":              o.GalleryOptions.TrashPath,
+		"oidc_issuer":             o.OIDC.Issuer,
+		"oidc_client_id":          o.OIDC.ClientID,
+		"oidc_client_secret":      redact(Credentials.OIDCClientSecret),
+		"oidc_redirect_url":       o.OIDC.RedirectURL,
+		"oidc_scopes":             strings.Join(o.OIDC.Scopes, " "),
+		"oidc_groups_claim":       o.OIDC.GroupsClaim,
+		"oidc_role_mapping":       getValue("MTSU_OIDC_ROLE_MAPPING"),
+		"oidc_default_role":       o.OIDC.DefaultRole,
+		"oidc_login_redirect":     o.OIDC.LoginRedirect,
+		"metrics_token":           redact(Credentials.MetricsToken),
+		"metrics_address":         o.Metrics.Address,
+	}
+
+	names := make([]string, 0, len(o.Metadata.Providers))
+	for name, provider := range o.Metadata.Providers {
+		names = append(names, name)
+
+		prefix := "provider_" + name
+		effective[prefix+"_url"] = provider.URL
+		effective[prefix+"_search_url"] = provider.SearchURL
+		effective[prefix+"_fetch_url"] = provider.FetchURL
+		effective[prefix+"_token"] = redact(provider.Token)
+	}
+	sort.Strings(names)
+	effective["metadata_providers"] = strings.Join(names, ",")
+
+	return effective
+}
+
+// redact hides a secret, but shows whether it is set.
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "[redacted]"
+}
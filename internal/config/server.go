@@ -0,0 +1,27 @@
+package config
+
+import (
+	"time"
+)
+
+// ServerOptions stores the timeouts of the HTTP server.
+type ServerOptions struct {
+	// ReadTimeout is the maximum duration for reading a request, including the body.
+	ReadTimeout time.Duration
+	// WriteTimeout is the maximum duration for writing a response. Cached pages, thumbnails and the event stream
+	// are exempt, as they may be streamed to slow clients for longer.
+	WriteTimeout time.Duration
+	// IdleTimeout is how long keep-alive connections are kept open between requests.
+	IdleTimeout time.Duration
+	// ShutdownTimeout is how long to wait for requests and background jobs to finish on shutdown.
+	ShutdownTimeout time.Duration
+}
+
+func serverOptions() ServerOptions {
+	return ServerOptions{
+		ReadTimeout:     durationEnv("MTSU_READ_TIMEOUT", time.Second*15),
+		WriteTimeout:    durationEnv("MTSU_WRITE_TIMEOUT", time.Second*15),
+		IdleTimeout:     durationEnv("MTSU_IDLE_TIMEOUT", time.Second*60),
+		ShutdownTimeout: durationEnv("MTSU_SHUTDOWN_TIMEOUT", time.Second*30),
+	}
+}
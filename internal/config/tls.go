@@ -0,0 +1,28 @@
+package config
+
+// TLSOptions stores the configuration of the built-in TLS.
+type TLSOptions struct {
+	CertFile string
+	KeyFile  string
+	// RedirectAddress is an optional plain HTTP listen address, such as :80, redirecting to HTTPS.
+	RedirectAddress string
+}
+
+// Enabled returns true if the server should serve HTTPS.
+func (t TLSOptions) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+func tlsOptions() TLSOptions {
+	options := TLSOptions{
+		CertFile:        getValue("MTSU_TLS_CERT"),
+		KeyFile:         getValue("MTSU_TLS_KEY"),
+		RedirectAddress: getValue("MTSU_TLS_REDIRECT_ADDRESS"),
+	}
+
+	if (options.CertFile == "") != (options.KeyFile == "") {
+		invalid("MTSU_TLS_CERT and MTSU_TLS_KEY must be set together")
+	}
+
+	return options
+}
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Mangatsu/server/pkg/db"
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	"github.com/gorilla/mux"
+)
+
+type LibraryAccessForm struct {
+	Type  db.PrincipalType `schema:"required,enum=user|group"`
+	ID    string           `schema:"required,min=1"` // UUID of the user or ID of the group
+	Allow bool
+}
+
+type GroupForm struct {
+	Name string `schema:"required,min=1"`
+}
+
+// returnGroups returns all groups and their members.
+func returnGroups(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	groups, err := db.GetGroups()
+	if handleResult(w, groups, err, true, r.URL.Path) {
+		return
+	}
+
+	resultToJSON(w, struct {
+		Data  []db.Group
+		Count int
+	}{
+		Data:  groups,
+		Count: len(groups),
+	}, r.URL.Path)
+}
+
+// newGroup creates a group.
+func newGroup(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	formData := &GroupForm{}
+	if err := json.NewDecoder(r.Body).Decode(formData); err != nil {
+		errorHandler(w, http.StatusBadRequest, err.Error(), r.URL.Path)
+		return
+	}
+
+	name := strings.TrimSpace(formData.Name)
+	if name == "" {
+		errorHandler(w, http.StatusBadRequest, "name is required", r.URL.Path)
+		return
+	}
+
+	group, err := db.NewGroup(name)
+	if handleResult(w, group, err, false, r.URL.Path) {
+		return
+	}
+
+	resultToJSON(w, group, r.URL.Path)
+}
+
+// deleteGroup removes a group and its access rules.
+func deleteGroup(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	groupID, ok := groupIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := db.DeleteGroup(groupID)
+	if handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+
+	messageToJSON(w, "group removed", r.URL.Path)
+}
+
+// addGroupMember adds a user to a group.
+func addGroupMember(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	groupID, ok := groupIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := db.AddGroupMember(groupID, mux.Vars(r)["uuid"])
+	if handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+
+	messageToJSON(w, "user added to group", r.URL.Path)
+}
+
+// removeGroupMember removes a user from a group.
+func removeGroupMember(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	groupID, ok := groupIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := db.RemoveGroupMember(groupID, mux.Vars(r)["uuid"])
+	if handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+
+	messageToJSON(w, "user removed from group", r.URL.Path)
+}
+
+// returnLibraryAccess returns the access rules of a library.
+func returnLibraryAccess(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	libraryID, ok := libraryIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	rules, err := db.GetLibraryAccess(libraryID)
+	if handleResult(w, rules, err, true, r.URL.Path) {
+		return
+	}
+
+	resultToJSON(w, struct {
+		Data  []model.LibraryAccess
+		Count int
+	}{
+		Data:  rules,
+		Count: len(rules),
+	}, r.URL.Path)
+}
+
+// setLibraryAccess allows or denies a user or a group access to a library.
+// Once a library has an allowing rule, only the allowed users and admins can view it.
+func setLibraryAccess(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	libraryID, ok := libraryIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	formData := &LibraryAccessForm{}
+	if err := json.NewDecoder(r.Body).Decode(formData); err != nil {
+		errorHandler(w, http.StatusBadRequest, err.Error(), r.URL.Path)
+		return
+	}
+	if !validPrincipal(formData.Type, formData.ID) {
+		errorHandler(w, http.StatusBadRequest, "type must be user or group with a valid ID", r.URL.Path)
+		return
+	}
+
+	if _, err := db.GetLibrary(libraryID); handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+
+	err := db.SetLibraryAccess(libraryID, formData.Type, formData.ID, formData.Allow)
+	if handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+
+	messageToJSON(w, "library access updated", r.URL.Path)
+}
+
+// removeLibraryAccess removes an access rule from a library.
+func removeLibraryAccess(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	libraryID, ok := libraryIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	params := mux.Vars(r)
+	principalType := db.PrincipalType(params["type"])
+	if !validPrincipal(principalType, params["principal"]) {
+		errorHandler(w, http.StatusBadRequest, "type must be user or group with a valid ID", r.URL.Path)
+		return
+	}
+
+	err := db.RemoveLibraryAccess(libraryID, principalType, params["principal"])
+	if handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+
+	messageToJSON(w, "library access rule removed", r.URL.Path)
+}
+
+func groupIDFromRequest(w http.ResponseWriter, r *http.Request) (int32, bool) {
+	groupID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		errorHandler(w, http.StatusBadRequest, "invalid group id", r.URL.Path)
+		return 0, false
+	}
+
+	return int32(groupID), true
+}
+
+// validPrincipal returns true if the ID is valid for the principal type.
+func validPrincipal(principalType db.PrincipalType, principalID string) bool {
+	switch principalType {
+	case db.PrincipalUser:
+		return principalID != ""
+	case db.PrincipalGroup:
+		_, err := strconv.ParseInt(principalID, 10, 32)
+		return err == nil
+	default:
+		return false
+	}
+}
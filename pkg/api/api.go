@@ -1,13 +1,14 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/Mangatsu/server/internal/config"
 	"github.com/Mangatsu/server/pkg/db"
 	"github.com/Mangatsu/server/pkg/log"
+	"github.com/Mangatsu/server/pkg/metadata"
 	"github.com/go-jet/jet/v2/qrm"
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
@@ -56,17 +57,26 @@ func resultToJSON(w http.ResponseWriter, result interface{}, endpoint string) {
 	}
 }
 
+const serverVersion = "0.8.1"
+
 func returnInfo(w http.ResponseWriter, r *http.Request) {
+	reloadable := config.Options.Reloadable()
 	resultToJSON(w, struct {
-		APIVersion    int
-		ServerVersion string
-		Visibility    config.Visibility
-		Registrations bool
+		APIVersion        int
+		ServerVersion     string
+		Visibility        config.Visibility
+		Registrations     bool
+		RequireApproval   bool
+		MetadataProviders []string
+		OIDC              bool
 	}{
-		APIVersion:    1,
-		ServerVersion: "0.8.1",
-		Visibility:    config.Options.Visibility,
-		Registrations: config.Options.Registrations,
+		APIVersion:        1,
+		ServerVersion:     serverVersion,
+		Visibility:        reloadable.Visibility,
+		Registrations:     reloadable.Registrations,
+		RequireApproval:   config.Options.RequireApproval,
+		MetadataProviders: metadata.ProviderNames(),
+		OIDC:              config.Options.OIDC.Enabled(),
 	}, r.URL.Path)
 }
 
@@ -76,99 +86,149 @@ func returnStatistics(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fmt.Fprintf(w, `{ "Message": "statistics not implemented" }`)
+	messageToJSON(w, "statistics not implemented", r.URL.Path)
 }
 
 // Returns the root path as JSON.
-func returnRoot(w http.ResponseWriter, _ *http.Request) {
-	fmt.Fprintf(w, `{ "Message": "Mangatsu API available at /api" }`)
-}
-
-// Handles errors. Argument msg is only used with 400 and 500.
-func errorHandler(w http.ResponseWriter, status int, msg string, endpoint string) {
-	switch status {
-	case http.StatusNotFound:
-		w.WriteHeader(status)
-		fmt.Fprintf(w, `{ "Code": %d, "Message": "not found" }`, status)
-	case http.StatusBadRequest:
-		w.WriteHeader(status)
-		fmt.Fprintf(w, `{ "Code": %d, "Message": "%s" }`, status, msg)
-	case http.StatusForbidden:
-		w.WriteHeader(status)
-		fmt.Fprintf(w, `{ "Code": %d, "Message": "forbidden" }`, status)
-	case http.StatusUnauthorized:
-		w.WriteHeader(status)
-		fmt.Fprintf(w, `{ "Code": %d, "Message": "unauthorized" }`, status)
-	case http.StatusGone:
-		w.WriteHeader(status)
-		fmt.Fprintf(w, `{ "Code": %d, "Message": "gone" }`, status)
-	case http.StatusConflict:
-		w.WriteHeader(status)
-		fmt.Fprintf(w, `{ "Code": %d, "Message": "%s" }`, status, msg)
-	default:
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, `{ "status": %d, "Message": "internal server error" }`, http.StatusInternalServerError)
-		log.Z.Error(msg, zap.Int("status", status))
-		return
-	}
-
-	log.Z.Debug(
-		"api request",
-		zap.String("endpoint", endpoint),
-		zap.Int("status", status),
-		zap.String("msg", msg),
-	)
+func returnRoot(w http.ResponseWriter, r *http.Request) {
+	messageToJSON(w, "Mangatsu API available at /api", r.URL.Path)
 }
 
-// Handles HTTP(S) requests.
-func handleRequests() {
+// newRouter returns the router of the API. Every route has to be described in operations.
+func newRouter() *mux.Router {
 	baseURL := "/api/v1"
 	uuidRegex := "[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}"
 	r := mux.NewRouter().StrictSlash(true)
 
 	r.HandleFunc("/", returnRoot).Methods("GET")
 	r.HandleFunc("/api", returnInfo).Methods("GET")
+	r.HandleFunc(baseURL+"/openapi.json", returnOpenAPI).Methods("GET")
 	r.HandleFunc(baseURL+"/statistics", returnStatistics).Methods("GET")
 
 	r.HandleFunc(baseURL+"/register", register).Methods("POST")
 	r.HandleFunc(baseURL+"/login", login).Methods("POST")
+	r.HandleFunc(baseURL+"/login/2fa", loginTwoFactor).Methods("POST")
 	r.HandleFunc(baseURL+"/logout", logout).Methods("POST")
+	r.HandleFunc(baseURL+"/oidc/login", oidcLogin).Methods("GET")
+	r.HandleFunc(baseURL+"/oidc/callback", oidcCallback).Methods("GET")
 
 	r.HandleFunc(baseURL+"/users", returnUsers).Methods("GET")
 	r.HandleFunc(baseURL+"/users/{uuid:"+uuidRegex+"}", updateUser).Methods("PUT")
 	r.HandleFunc(baseURL+"/users/{uuid:"+uuidRegex+"}", deleteUser).Methods("DELETE")
 	r.HandleFunc(baseURL+"/users/me/favorites", returnFavoriteGroups).Methods("GET")
-	r.HandleFunc(baseURL+"/users/me/sessions", returnSessions).Methods("GET")
+	r.HandleFunc(baseURL+"/users/me/sessions", withScope(noScope, returnSessions)).Methods("GET")
 	r.HandleFunc(baseURL+"/users/me/sessions", deleteSession).Methods("DELETE")
+	r.HandleFunc(baseURL+"/users/me/tokens", withScope(noScope, returnAPITokens)).Methods("GET")
+	r.HandleFunc(baseURL+"/users/me/tokens", newAPIToken).Methods("POST")
+	r.HandleFunc(baseURL+"/users/me/tokens/{id:[0-9a-f]+}", deleteAPIToken).Methods("DELETE")
+	r.HandleFunc(baseURL+"/users/me/2fa", startTOTPEnrollment).Methods("POST")
+	r.HandleFunc(baseURL+"/users/me/2fa", disableTOTP).Methods("DELETE")
+	r.HandleFunc(baseURL+"/users/me/2fa/confirm", confirmTOTPEnrollment).Methods("POST")
+	r.HandleFunc(baseURL+"/users/me/2fa/recovery-codes", regenerateRecoveryCodes).Methods("POST")
+	r.HandleFunc(baseURL+"/users/{uuid:"+uuidRegex+"}/2fa", resetTOTP).Methods("DELETE")
+	r.HandleFunc(baseURL+"/users/{uuid:"+uuidRegex+"}/lock", unlockUser).Methods("DELETE")
+	r.HandleFunc(baseURL+"/users/{uuid:"+uuidRegex+"}/approve", approveUser).Methods("POST")
+	r.HandleFunc(baseURL+"/users/{uuid:"+uuidRegex+"}/reject", rejectUser).Methods("POST")
+	r.HandleFunc(baseURL+"/login-attempts", returnLoginAttempts).Methods("GET")
+	r.HandleFunc(baseURL+"/invites", returnInvites).Methods("GET")
+	r.HandleFunc(baseURL+"/invites", newInvite).Methods("POST")
+	r.HandleFunc(baseURL+"/invites/{id:[0-9a-f]+}", deleteInvite).Methods("DELETE")
+
+	r.HandleFunc(baseURL+"/groups", returnGroups).Methods("GET")
+	r.HandleFunc(baseURL+"/groups", newGroup).Methods("POST")
+	r.HandleFunc(baseURL+"/groups/{id:[0-9]+}", deleteGroup).Methods("DELETE")
+	r.HandleFunc(baseURL+"/groups/{id:[0-9]+}/members/{uuid:"+uuidRegex+"}", addGroupMember).Methods("PUT")
+	r.HandleFunc(baseURL+"/groups/{id:[0-9]+}/members/{uuid:"+uuidRegex+"}", removeGroupMember).Methods("DELETE")
 
+	r.HandleFunc(baseURL+"/config", returnConfig).Methods("GET")
+	r.HandleFunc(baseURL+"/config/reload", reloadConfig).Methods("POST")
 	r.HandleFunc(baseURL+"/status", returnProcessingStatus).Methods("GET")
+	r.HandleFunc(baseURL+"/status", resetProcessingStatus).Methods("DELETE")
+	r.HandleFunc(baseURL+"/events", streamEvents).Methods("GET")
 	r.HandleFunc(baseURL+"/scan", scanLibraries).Methods("GET")
 	r.HandleFunc(baseURL+"/thumbnails", generateThumbnails).Methods("GET")
 	r.HandleFunc(baseURL+"/meta", findMetadata).Methods("GET")
+	r.HandleFunc(baseURL+"/meta/review", returnMetaCandidates).Methods("GET")
+	r.HandleFunc(baseURL+"/meta/review/{id:[0-9]+}", returnMetaCandidate).Methods("GET")
+	r.HandleFunc(baseURL+"/meta/review/{id:[0-9]+}/accept", acceptMetaCandidate).Methods("POST")
+	r.HandleFunc(baseURL+"/meta/review/{id:[0-9]+}/reject", rejectMetaCandidate).Methods("POST")
 
 	r.HandleFunc(baseURL+"/categories", returnCategories).Methods("GET")
 	r.HandleFunc(baseURL+"/series", returnSeries).Methods("GET")
+	r.HandleFunc(baseURL+"/series/{id:[0-9]+}", returnSeriesGalleries).Methods("GET")
+	r.HandleFunc(baseURL+"/series/{id:[0-9]+}", updateSeries).Methods("PUT")
+	r.HandleFunc(baseURL+"/series/{id:[0-9]+}", deleteSeries).Methods("DELETE")
+	r.HandleFunc(baseURL+"/series/{id:[0-9]+}/next", returnNextUnread).Methods("GET")
+	r.HandleFunc(baseURL+"/series/{id:[0-9]+}/merge", mergeSeries).Methods("POST")
+	r.HandleFunc(baseURL+"/series/{id:[0-9]+}/order", setSeriesOrder).Methods("PUT")
 	r.HandleFunc(baseURL+"/tags", returnTags).Methods("GET")
 
+	r.HandleFunc(baseURL+"/libraries", returnLibraries).Methods("GET")
+	r.HandleFunc(baseURL+"/libraries", newLibrary).Methods("POST")
+	r.HandleFunc(baseURL+"/libraries/{id:[0-9]+}", returnLibrary).Methods("GET")
+	r.HandleFunc(baseURL+"/libraries/{id:[0-9]+}", updateLibrary).Methods("PUT")
+	r.HandleFunc(baseURL+"/libraries/{id:[0-9]+}", deleteLibrary).Methods("DELETE")
+	r.HandleFunc(baseURL+"/libraries/{id:[0-9]+}/access", returnLibraryAccess).Methods("GET")
+	r.HandleFunc(baseURL+"/libraries/{id:[0-9]+}/access", setLibraryAccess).Methods("PUT")
+	r.HandleFunc(baseURL+"/libraries/{id:[0-9]+}/access/{type:user|group}/{principal}", removeLibraryAccess).Methods("DELETE")
+
+	r.HandleFunc(baseURL+"/webhooks", returnWebhooks).Methods("GET")
+	r.HandleFunc(baseURL+"/webhooks", newWebhook).Methods("POST")
+	r.HandleFunc(baseURL+"/webhooks/{id:[0-9]+}", returnWebhook).Methods("GET")
+	r.HandleFunc(baseURL+"/webhooks/{id:[0-9]+}", updateWebhook).Methods("PUT")
+	r.HandleFunc(baseURL+"/webhooks/{id:[0-9]+}", deleteWebhook).Methods("DELETE")
+	r.HandleFunc(baseURL+"/webhooks/{id:[0-9]+}/deliveries", returnWebhookDeliveries).Methods("GET")
+	r.HandleFunc(baseURL+"/webhooks/{id:[0-9]+}/deliveries/{delivery:[0-9]+}/retry", retryWebhookDelivery).Methods("POST")
+
+	r.HandleFunc(baseURL+"/trash", returnTrash).Methods("GET")
+	r.HandleFunc(baseURL+"/trash/{uuid:"+uuidRegex+"}/restore", restoreGallery).Methods("POST")
+	r.HandleFunc(baseURL+"/trash/{uuid:"+uuidRegex+"}", purgeGallery).Methods("DELETE")
+
 	r.HandleFunc(baseURL+"/galleries", returnGalleries).Methods("GET")
 	r.HandleFunc(baseURL+"/galleries/count", returnGalleryCount).Methods("GET")
 	r.HandleFunc(baseURL+"/galleries/random", returnRandomGallery).Methods("GET")
+	r.HandleFunc(baseURL+"/galleries/bulk", bulkEditGalleries).Methods("POST")
 	r.HandleFunc(baseURL+"/galleries/{uuid:"+uuidRegex+"}", updateGallery).Methods("PUT")
 	r.HandleFunc(baseURL+"/galleries/{uuid:"+uuidRegex+"}", returnGallery).Methods("GET")
-	r.HandleFunc(baseURL+"/galleries/{uuid:"+uuidRegex+"}/progress/{progress:[0-9]+}", updateProgress).Methods("PATCH")
-	r.HandleFunc(baseURL+"/galleries/{uuid:"+uuidRegex+"}/favorite/{name}", setFavorite).Methods("PATCH")
-	r.HandleFunc(baseURL+"/galleries/{uuid:"+uuidRegex+"}/favorite", setFavorite).Methods("PATCH")
+	r.HandleFunc(baseURL+"/galleries/{uuid:"+uuidRegex+"}", deleteGallery).Methods("DELETE")
+	r.HandleFunc(baseURL+"/galleries/{uuid:"+uuidRegex+"}/revisions", returnRevisions).Methods("GET")
+	r.HandleFunc(baseURL+"/galleries/{uuid:"+uuidRegex+"}/revisions/{id:[0-9]+}/revert", revertGallery).Methods("POST")
+	r.HandleFunc(baseURL+"/galleries/{uuid:"+uuidRegex+"}/locks", returnLocks).Methods("GET")
+	r.HandleFunc(baseURL+"/galleries/{uuid:"+uuidRegex+"}/locks", lockFields).Methods("PUT")
+	r.HandleFunc(baseURL+"/galleries/{uuid:"+uuidRegex+"}/locks", unlockFields).Methods("DELETE")
+	r.HandleFunc(baseURL+"/galleries/{uuid:"+uuidRegex+"}/progress/{progress:[0-9]+}", withScope(db.ScopeProgress, updateProgress)).Methods("PATCH")
+	r.HandleFunc(baseURL+"/galleries/{uuid:"+uuidRegex+"}/favorite/{name}", withScope(db.ScopeProgress, setFavorite)).Methods("PATCH")
+	r.HandleFunc(baseURL+"/galleries/{uuid:"+uuidRegex+"}/favorite", withScope(db.ScopeProgress, setFavorite)).Methods("PATCH")
 
 	if config.Options.Cache.WebServer {
-		r.PathPrefix("/cache/").Handler(http.StripPrefix("/cache/", http.FileServer(http.Dir(config.BuildCachePath()))))
+		r.PathPrefix("/cache/").Handler(
+			http.StripPrefix("/cache/", cacheAccessHandler(http.FileServer(http.Dir(config.BuildCachePath())))),
+		)
+	}
+
+	r.Use(validateRequest)
+
+	if config.Options.Metrics.Enabled {
+		r.Use(metricsMiddleware)
+		if config.Options.Metrics.Address == "" {
+			r.HandleFunc("/metrics", returnMetrics).Methods("GET")
+		}
 	}
 
 	// General 404
 	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		errorHandler(w, http.StatusNotFound, "", r.RequestURI)
 	})
+	r.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errorHandler(w, http.StatusMethodNotAllowed, "", r.RequestURI)
+	})
 
-	handler := cors.New(cors.Options{
+	return r
+}
+
+// handleRequests returns the handler of the HTTP(S) requests.
+func handleRequests() http.Handler {
+	return withRequestID(cors.New(cors.Options{
 		AllowOriginFunc: func(origin string) bool { return originAllowed(origin) },
 		AllowedMethods: []string{
 			http.MethodOptions, http.MethodGet, http.MethodPost, http.MethodDelete, http.MethodPut, http.MethodPatch,
@@ -176,25 +236,89 @@ func handleRequests() {
 		AllowedHeaders: []string{
 			"Accept", "Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization",
 			"Access-Control-Allow-Headers", "Origin", "X-Requested-With", "Access-Control-Request-Method",
-			"Access-Control-Request-Headers",
+			"Access-Control-Request-Headers", "Last-Event-ID", "X-Request-ID",
 		},
+		ExposedHeaders:      []string{"X-Request-ID"},
 		AllowCredentials:    true,
 		AllowPrivateNetwork: true,
-	}).Handler(r)
+	}).Handler(newRouter()))
+}
 
-	fullAddress := config.Options.Hostname + ":" + config.Options.Port
-	srv := &http.Server{
+// newServer returns a server with the configured timeouts.
+func newServer(address string, handler http.Handler) *http.Server {
+	return &http.Server{
 		Handler:      handler,
-		Addr:         fullAddress,
-		WriteTimeout: 15 * time.Second,
-		ReadTimeout:  15 * time.Second,
+		Addr:         address,
+		ReadTimeout:  config.Options.Server.ReadTimeout,
+		WriteTimeout: config.Options.Server.WriteTimeout,
+		IdleTimeout:  config.Options.Server.IdleTimeout,
 	}
+}
 
-	log.Z.Info("starting API on: " + fullAddress)
-	log.Z.Warn(srv.ListenAndServe().Error())
+// clearWriteDeadline exempts a response from the write timeout of the server, for files and streams that may take
+// longer to send to slow clients.
+func clearWriteDeadline(w http.ResponseWriter) error {
+	err := http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	if errors.Is(err, http.ErrNotSupported) {
+		return nil
+	}
+
+	return err
 }
 
-// LaunchAPI starts handling API requests.
-func LaunchAPI() {
-	handleRequests()
+// LaunchAPI handles API requests until the context is cancelled. The servers then stop accepting new connections
+// and wait for the ongoing requests to finish, up to the shutdown timeout.
+func LaunchAPI(ctx context.Context) {
+	apiServer := newServer(config.Options.Hostname+":"+config.Options.Port, handleRequests())
+	apiServer.RegisterOnShutdown(shutdownStreams)
+	servers := []*http.Server{apiServer}
+
+	if config.Options.TLS.Enabled() {
+		if err := configureTLS(apiServer); err != nil {
+			log.Z.Fatal("failed to load the TLS certificate", zap.String("err", err.Error()))
+		}
+
+		if config.Options.TLS.RedirectAddress != "" {
+			servers = append(servers, newServer(config.Options.TLS.RedirectAddress, redirectHandler()))
+		}
+	}
+
+	if config.Options.Metrics.Enabled && config.Options.Metrics.Address != "" {
+		servers = append(servers, newServer(config.Options.Metrics.Address, metricsHandler()))
+	}
+
+	failed := make(chan struct{}, len(servers))
+	for _, srv := range servers {
+		log.Z.Info("starting server on: " + srv.Addr)
+		go func() {
+			var err error
+			if srv.TLSConfig != nil {
+				err = srv.ListenAndServeTLS("", "")
+			} else {
+				err = srv.ListenAndServe()
+			}
+
+			if !errors.Is(err, http.ErrServerClosed) {
+				log.Z.Error("server failed", zap.String("address", srv.Addr), zap.String("err", err.Error()))
+				failed <- struct{}{}
+			}
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+		log.Z.Info("shutting down the server")
+	case <-failed:
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.Options.Server.ShutdownTimeout)
+	defer cancel()
+
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Z.Warn("requests did not finish before the shutdown timeout",
+				zap.String("address", srv.Addr),
+				zap.String("err", err.Error()))
+		}
+	}
 }
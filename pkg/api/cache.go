@@ -0,0 +1,137 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mangatsu/server/internal/config"
+	"github.com/Mangatsu/server/pkg/db"
+	"github.com/google/uuid"
+)
+
+// cacheAccessHandler serves the cached galleries and thumbnails only to users who may view the gallery.
+// Access is granted either by a signed query string (for <img> tags that cannot send headers) or by the same
+// credentials as the API. Hidden galleries are only served to admins or with a signed query string.
+// Requests without a gallery UUID and directories, such as listing the root dirs, are not served.
+func cacheAccessHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			errorHandler(w, http.StatusMethodNotAllowed, "", r.URL.Path)
+			return
+		}
+
+		galleryUUID := cachedGalleryUUID(r.URL.Path)
+		if galleryUUID == "" {
+			errorHandler(w, http.StatusNotFound, "", r.URL.Path)
+			return
+		}
+
+		info, err := os.Stat(config.BuildCachePath(path.Clean("/" + r.URL.Path)))
+		if err != nil || info.IsDir() {
+			errorHandler(w, http.StatusNotFound, "", r.URL.Path)
+			return
+		}
+
+		query := r.URL.Query()
+		expiresAt, signed := verifyCacheSignature(galleryUUID, query.Get("exp"), query.Get("sig"), time.Now())
+		if signed {
+			maxAge := int(time.Until(expiresAt).Seconds())
+			w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", maxAge))
+		} else {
+			access, userUUID := hasAccess(w, r, db.NoRole)
+			if !access {
+				return
+			}
+
+			if !db.CanAccessGallery(galleryUUID, userUUID) {
+				errorHandler(w, http.StatusNotFound, "", r.URL.Path)
+				return
+			}
+
+			// Access may be revoked, so browsers have to revalidate the files.
+			w.Header().Set("Cache-Control", "private, no-cache")
+		}
+
+		// http.FileServer handles If-None-Match and answers with 304 when the ETag matches.
+		w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+
+		// Large pages may take longer than the write timeout to download on slow connections.
+		if err := clearWriteDeadline(w); err != nil {
+			errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// cachedGalleryUUID returns the gallery UUID from a path relative to the cache dir:
+// <uuid>/<file> for galleries and thumbnails/<uuid>/<file> for thumbnails.
+func cachedGalleryUUID(cachePath string) string {
+	parts := strings.Split(strings.TrimPrefix(cachePath, "/"), "/")
+	if len(parts) > 1 && parts[0] == "thumbnails" {
+		parts = parts[1:]
+	}
+
+	if _, err := uuid.Parse(parts[0]); err != nil {
+		return ""
+	}
+
+	return parts[0]
+}
+
+// signCacheQuery returns a query string granting access to the cached files and thumbnails of the gallery.
+// The expiry is rounded up to the next hour so that the URLs, and thus the browser cache, stay the same for a while.
+func signCacheQuery(galleryUUID string, now time.Time) string {
+	expiresAt := now.Add(config.Options.Cache.URLTTL).Truncate(time.Hour).Add(time.Hour)
+	exp := strconv.FormatInt(expiresAt.Unix(), 10)
+
+	return url.Values{
+		"exp": {exp},
+		"sig": {cacheSignature(galleryUUID, exp)},
+	}.Encode()
+}
+
+// verifyCacheSignature returns the expiry time and true if the signature is valid and has not expired.
+func verifyCacheSignature(galleryUUID string, exp string, sig string, now time.Time) (time.Time, bool) {
+	if exp == "" || sig == "" {
+		return time.Time{}, false
+	}
+
+	expUnix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	expiresAt := time.Unix(expUnix, 0)
+	if !now.Before(expiresAt) {
+		return time.Time{}, false
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(cacheSignature(galleryUUID, exp))) {
+		return time.Time{}, false
+	}
+
+	return expiresAt, true
+}
+
+// cacheSignature returns the HMAC-SHA256 of the gallery UUID and the expiry time. The key is derived from
+// the JWT secret, so changing it invalidates all signed URLs.
+func cacheSignature(galleryUUID string, exp string) string {
+	keyMac := hmac.New(sha256.New, []byte(config.Credentials.JWTSecret))
+	keyMac.Write([]byte("cache"))
+
+	mac := hmac.New(sha256.New, keyMac.Sum(nil))
+	mac.Write([]byte(galleryUUID + "|" + exp))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Mangatsu/server/internal/config"
+	"github.com/Mangatsu/server/pkg/db"
+	"github.com/Mangatsu/server/pkg/log"
+	"github.com/Mangatsu/server/pkg/openapi"
+	"go.uber.org/zap"
+)
+
+type ConfigResult struct {
+	// File is the path of the config file. Empty if only environmentals are used.
+	File   string
+	Config map[string]string
+}
+
+// returnConfig returns the configuration in use with the secrets redacted. Only for admins.
+func returnConfig(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	resultToJSON(w, ConfigResult{File: config.ConfigFile(), Config: config.Effective()}, r.URL.Path)
+}
+
+// reloadConfig reloads the options that can be changed at runtime. Responds with the problems if the configuration
+// is invalid. Only for admins.
+func reloadConfig(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	if err := config.Reload(); err != nil {
+		var validationErr *config.ValidationError
+		if !errors.As(err, &validationErr) {
+			errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
+			return
+		}
+
+		log.Z.Warn("configuration not reloaded",
+			zap.Strings("problems", validationErr.Problems),
+			zap.String("request_id", requestIDOf(r)))
+
+		problems := make([]openapi.Problem, len(validationErr.Problems))
+		for i, problem := range validationErr.Problems {
+			problems[i] = openapi.Problem{Message: problem}
+		}
+		validationFailed(w, "invalid configuration", problems, r.URL.Path)
+		return
+	}
+
+	resultToJSON(w, ConfigResult{File: config.ConfigFile(), Config: config.Effective()}, r.URL.Path)
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/Mangatsu/server/pkg/log"
+	"github.com/Mangatsu/server/pkg/openapi"
+	"go.uber.org/zap"
+)
+
+// ErrorCode identifies the kind of error for clients. Unlike the messages, the codes do not change.
+type ErrorCode string
+
+const (
+	CodeBadRequest       ErrorCode = "bad_request"
+	CodeInvalidJSON      ErrorCode = "invalid_json"
+	CodeBodyRequired     ErrorCode = "body_required"
+	CodeValidationFailed ErrorCode = "validation_failed"
+	CodeUnauthorized     ErrorCode = "unauthorized"
+	CodeForbidden        ErrorCode = "forbidden"
+	CodeNotFound         ErrorCode = "not_found"
+	CodeMethodNotAllowed ErrorCode = "method_not_allowed"
+	CodeConflict         ErrorCode = "conflict"
+	CodeUsernameTaken    ErrorCode = "username_taken"
+	CodeGone             ErrorCode = "gone"
+	CodeBodyTooLarge     ErrorCode = "body_too_large"
+	CodeRateLimited      ErrorCode = "rate_limited"
+	CodeInternal         ErrorCode = "internal_error"
+)
+
+// ErrorResponse is the body of the error responses. Problems lists the invalid values of a request. RequestID is
+// also logged with the error, so that it can be looked up from the logs.
+type ErrorResponse struct {
+	Status    int
+	Code      ErrorCode
+	Message   string
+	RequestID string            `json:",omitempty"`
+	Problems  []openapi.Problem `json:",omitempty"`
+}
+
+// MessageResponse is the body of the successful responses without a result.
+type MessageResponse struct {
+	Message string
+}
+
+// codeOfStatus returns the general code of the status.
+func codeOfStatus(status int) ErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusGone:
+		return CodeGone
+	case http.StatusRequestEntityTooLarge:
+		return CodeBodyTooLarge
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	default:
+		return CodeInternal
+	}
+}
+
+// Handles errors with the general code of the status. Argument msg is used as the message with 400 and 409, and
+// logged otherwise.
+func errorHandler(w http.ResponseWriter, status int, msg string, endpoint string) {
+	errorWithCode(w, status, codeOfStatus(status), msg, endpoint)
+}
+
+// errorWithCode handles errors with a specific code. Internal server errors are logged with the error and answered
+// with a generic message and the request ID.
+func errorWithCode(w http.ResponseWriter, status int, code ErrorCode, msg string, endpoint string) {
+	requestID := w.Header().Get(requestIDHeader)
+	response := ErrorResponse{Status: status, Code: code, RequestID: requestID}
+
+	switch status {
+	case http.StatusBadRequest, http.StatusConflict:
+		response.Message = msg
+		if response.Message == "" {
+			response.Message = strings.ToLower(http.StatusText(status))
+		}
+	case http.StatusNotFound, http.StatusForbidden, http.StatusUnauthorized, http.StatusMethodNotAllowed,
+		http.StatusGone, http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		response.Message = strings.ToLower(http.StatusText(status))
+	default:
+		writeError(w, ErrorResponse{
+			Status:    http.StatusInternalServerError,
+			Code:      CodeInternal,
+			Message:   "internal server error",
+			RequestID: requestID,
+		})
+		log.Z.Error("api request failed",
+			zap.String("endpoint", endpoint),
+			zap.Int("status", status),
+			zap.String("request_id", requestID),
+			zap.String("err", msg))
+		return
+	}
+
+	writeError(w, response)
+
+	log.Z.Debug(
+		"api request",
+		zap.String("endpoint", endpoint),
+		zap.Int("status", status),
+		zap.String("code", string(code)),
+		zap.String("request_id", requestID),
+		zap.String("msg", msg),
+	)
+}
+
+// validationFailed responds with 400 listing the invalid values of the request.
+func validationFailed(w http.ResponseWriter, message string, problems []openapi.Problem, endpoint string) {
+	requestID := w.Header().Get(requestIDHeader)
+	writeError(w, ErrorResponse{
+		Status:    http.StatusBadRequest,
+		Code:      CodeValidationFailed,
+		Message:   message,
+		RequestID: requestID,
+		Problems:  problems,
+	})
+
+	log.Z.Debug(
+		"api request",
+		zap.String("endpoint", endpoint),
+		zap.Int("status", http.StatusBadRequest),
+		zap.String("code", string(CodeValidationFailed)),
+		zap.String("request_id", requestID),
+		zap.Int("problems", len(problems)),
+	)
+}
+
+func writeError(w http.ResponseWriter, response ErrorResponse) {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.WriteHeader(response.Status)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// messageToJSON responds with a message, such as "gallery updated".
+func messageToJSON(w http.ResponseWriter, message string, endpoint string) {
+	resultToJSON(w, MessageResponse{Message: message}, endpoint)
+}
+
+const requestIDHeader = "X-Request-ID"
+
+// requestIDR limits the request IDs given by clients or proxies to ones that are safe to log and echo.
+var requestIDR = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+type requestIDKey struct{}
+
+// withRequestID identifies every request with the ID given in X-Request-ID, or a random one. The ID is echoed in
+// the response header, so that errors can be matched with the logs.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if !requestIDR.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+
+		w.Header().Set(requestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, requestID)))
+	})
+}
+
+// requestIDOf returns the ID of the request, or an empty string outside withRequestID.
+func requestIDOf(r *http.Request) string {
+	requestID, _ := r.Context().Value(requestIDKey{}).(string)
+	return requestID
+}
+
+func newRequestID() string {
+	x := make([]byte, 16)
+	if _, err := rand.Read(x); err != nil {
+		log.Z.Error("failed to generate a request ID", zap.String("err", err.Error()))
+		return ""
+	}
+
+	return hex.EncodeToString(x)
+}
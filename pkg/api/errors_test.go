@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Mangatsu/server/pkg/log"
+	"go.uber.org/zap"
+)
+
+func TestErrorResponses(t *testing.T) {
+	log.Z = zap.NewNop()
+
+	tests := []struct {
+		status    int
+		msg       string
+		requestID string
+		expected  ErrorResponse
+	}{
+		{http.StatusBadRequest, `name "x" not valid`, "abc-123",
+			ErrorResponse{Status: 400, Code: CodeBadRequest, Message: `name "x" not valid`, RequestID: "abc-123"}},
+		{http.StatusNotFound, "no rows", "",
+			ErrorResponse{Status: 404, Code: CodeNotFound, Message: "not found"}},
+		{http.StatusInternalServerError, "database is locked", "",
+			ErrorResponse{Status: 500, Code: CodeInternal, Message: "internal server error"}},
+		{http.StatusBadGateway, "", "",
+			ErrorResponse{Status: 500, Code: CodeInternal, Message: "internal server error"}},
+	}
+
+	for _, test := range tests {
+		handler := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			errorHandler(w, test.status, test.msg, r.URL.Path)
+		}))
+
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/galleries", nil)
+		if test.requestID != "" {
+			request.Header.Set(requestIDHeader, test.requestID)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		var response ErrorResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Errorf("%d: invalid JSON %q: %s", test.status, recorder.Body.String(), err)
+			continue
+		}
+
+		requestID := recorder.Header().Get(requestIDHeader)
+		if requestID == "" || response.RequestID != requestID {
+			t.Errorf("%d: expected the request ID %q in the body, got %q", test.status, requestID, response.RequestID)
+		}
+		if test.requestID != "" && requestID != test.requestID {
+			t.Errorf("%d: expected the given request ID %q, got %q", test.status, test.requestID, requestID)
+		}
+
+		response.RequestID = test.expected.RequestID
+		if recorder.Code != test.expected.Status || response.Status != test.expected.Status ||
+			response.Code != test.expected.Code || response.Message != test.expected.Message {
+			t.Errorf("%d: expected %+v, got %d %+v", test.status, test.expected, recorder.Code, response)
+		}
+	}
+}
+
+func TestRequestIDIsSanitized(t *testing.T) {
+	var requestID string
+	handler := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID = requestIDOf(r)
+	}))
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set(requestIDHeader, "bad id\nwith a newline")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	if len(requestID) != 32 || recorder.Header().Get(requestIDHeader) != requestID {
+		t.Errorf("Expected a generated request ID, got %q and header %q", requestID, recorder.Header().Get(requestIDHeader))
+	}
+}
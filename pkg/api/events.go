@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/db"
+	"github.com/Mangatsu/server/pkg/events"
+	"github.com/Mangatsu/server/pkg/log"
+	"go.uber.org/zap"
+)
+
+// heartbeatInterval keeps idle connections open through proxies.
+const heartbeatInterval = 30 * time.Second
+
+// streamsCtx is cancelled when the server shuts down, ending the streams that would otherwise keep it waiting.
+var streamsCtx, shutdownStreams = context.WithCancel(context.Background())
+
+// streamEvents streams the events of the scan, thumbnail and metadata tasks as Server-Sent Events.
+// Admins receive all events. Others receive the task events and the events of the galleries they can access.
+// Events missed while reconnecting are replayed based on the Last-Event-ID header.
+func streamEvents(w http.ResponseWriter, r *http.Request) {
+	access, userUUID := hasAccess(w, r, db.NoRole)
+	if !access {
+		return
+	}
+	admin, _ := verifyToken(r, readJWT(r), db.Admin)
+
+	var types []events.Type
+	if value := r.URL.Query().Get("types"); value != "" {
+		for _, eventType := range strings.Split(value, ",") {
+			types = append(types, events.Type(strings.TrimSpace(eventType)))
+		}
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+	lastID, _ := strconv.ParseUint(lastEventID, 10, 64)
+
+	// The stream is kept open longer than the write timeout of the server.
+	if err := clearWriteDeadline(w); err != nil {
+		errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
+		return
+	}
+	controller := http.NewResponseController(w)
+
+	subscription, missed := events.DefaultBus.Subscribe(lastID)
+	defer events.DefaultBus.Unsubscribe(subscription)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event events.Event) bool {
+		if len(types) > 0 && !slices.Contains(types, event.Type) {
+			return true
+		}
+		if !admin && !eventVisible(event, userUUID) {
+			return true
+		}
+
+		data, err := json.Marshal(event)
+		if err != nil {
+			log.Z.Error("failed to encode event", zap.String("err", err.Error()))
+			return true
+		}
+
+		_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+		return err == nil
+	}
+
+	for _, event := range missed {
+		if !send(event) {
+			return
+		}
+	}
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-streamsCtx.Done():
+			return
+		case event, ok := <-subscription.C:
+			// Closed if the client cannot keep up. The client reconnects and gets the missed events.
+			if !ok || !send(event) {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// eventVisible returns true if a non-admin user may see the event. Errors contain paths, so they are only for admins.
+func eventVisible(event events.Event, userUUID *string) bool {
+	switch event.Type {
+	case events.TaskStarted, events.TaskFinished:
+		return true
+	case events.GalleryAdded, events.ThumbnailGenerated, events.MetadataParsed:
+		return db.CanAccessGallery(event.GalleryUUID, userUUID)
+	default:
+		return false
+	}
+}
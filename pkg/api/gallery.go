@@ -1,15 +1,20 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"github.com/Mangatsu/server/pkg/utils"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/Mangatsu/server/internal/config"
 	"github.com/Mangatsu/server/pkg/cache"
 	"github.com/Mangatsu/server/pkg/db"
 	"github.com/Mangatsu/server/pkg/log"
+	"github.com/Mangatsu/server/pkg/metadata"
 	"github.com/Mangatsu/server/pkg/types/sqlite/model"
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
@@ -25,9 +30,10 @@ type MetadataResult struct {
 	Tags map[string][]string
 
 	Reference struct {
-		ExhToken *string
-		ExhGid   *int32
-		Urls     *string
+		ExhToken  *string
+		ExhGid    *int32
+		AnilistID *int32
+		Urls      *string
 	} `alias:"reference.*"`
 
 	GalleryPref *struct {
@@ -37,6 +43,9 @@ type MetadataResult struct {
 	} `alias:"gallery_pref.*"`
 
 	Library model.Library `json:"-"`
+
+	// CacheQuery is appended to the URLs of the cached files and thumbnails of the gallery, e.g. in <img> tags.
+	CacheQuery string `json:",omitempty"`
 }
 
 type GalleryResult struct {
@@ -68,6 +77,24 @@ type UpdateGalleryForm struct {
 	Tags            map[string][]string
 }
 
+type LockForm struct {
+	Fields []string `schema:"required"`
+}
+
+type BulkEditForm struct {
+	UUIDs      []string
+	Filter     *string // Same query parameters as in listing, e.g. category=manga&tag=artist:name
+	AddTags    map[string][]string
+	RemoveTags map[string][]string
+	Series     *string
+	Category   *string
+	Language   *string
+	Nsfw       *bool
+	Hidden     *bool
+	Metadata   bool // Re-runs the metadata parsers (x, ehdl, hath and fuzzy) for the galleries
+	DryRun     bool
+}
+
 // returnGalleries returns galleries as JSON.
 func returnGalleries(w http.ResponseWriter, r *http.Request) {
 	access, userUUID := hasAccess(w, r, db.NoRole)
@@ -209,13 +236,14 @@ func returnRandomGallery(w http.ResponseWriter, r *http.Request) {
 	}, r.URL.Path)
 }
 
-// returnTags returns all tags as JSON.
+// returnTags returns the tags of the galleries the user may view as JSON.
 func returnTags(w http.ResponseWriter, r *http.Request) {
-	if access, _ := hasAccess(w, r, db.NoRole); !access {
+	access, userUUID := hasAccess(w, r, db.NoRole)
+	if !access {
 		return
 	}
 
-	tags, _, err := db.GetTags("", true)
+	tags, err := db.GetAllTags(userUUID)
 	if handleResult(w, tags, err, true, r.RequestURI) {
 		return
 	}
@@ -223,13 +251,14 @@ func returnTags(w http.ResponseWriter, r *http.Request) {
 	resultToJSON(w, tags, r.RequestURI)
 }
 
-// returnCategories returns all public categories as JSON.
+// returnCategories returns the categories of the galleries the user may view as JSON.
 func returnCategories(w http.ResponseWriter, r *http.Request) {
-	if access, _ := hasAccess(w, r, db.NoRole); !access {
+	access, userUUID := hasAccess(w, r, db.NoRole)
+	if !access {
 		return
 	}
 
-	categories, err := db.GetCategories()
+	categories, err := db.GetCategories(userUUID)
 	if handleResult(w, categories, err, true, r.RequestURI) {
 		return
 	}
@@ -240,28 +269,11 @@ func returnCategories(w http.ResponseWriter, r *http.Request) {
 	}, r.RequestURI)
 }
 
-// returnSeries returns all series as JSON.
-func returnSeries(w http.ResponseWriter, r *http.Request) {
-	if access, _ := hasAccess(w, r, db.NoRole); !access {
-		return
-	}
-
-	series, err := db.GetSeries()
-	if handleResult(w, series, err, true, r.RequestURI) {
-		return
-	}
-
-	resultToJSON(w, GenericStringResult{
-		Data:  series,
-		Count: len(series),
-	}, r.RequestURI)
-}
-
 // updateGallery updates a gallery and its reference and tags.
 // If tags field is specified and empty, all references to this gallery's tags will be removed.
 // If tags is not specified, no changes to tags will be made.
 func updateGallery(w http.ResponseWriter, r *http.Request) {
-	access, _ := hasAccess(w, r, db.Admin)
+	access, userUUID := hasAccess(w, r, db.Admin)
 	if !access {
 		return
 	}
@@ -303,21 +315,215 @@ func updateGallery(w http.ResponseWriter, r *http.Request) {
 
 	var tags []model.Tag
 	if formData.Tags != nil {
-		tags = []model.Tag{}
-		for namespace, names := range formData.Tags {
-			for _, name := range names {
-				tag := model.Tag{
-					Namespace: namespace,
-					Name:      name,
-				}
-				tags = append(tags, tag)
-			}
+		tags = convertMapToTags(formData.Tags)
+	}
+
+	if err := db.UpdateGallery(newGallery, tags, newReference, false, db.Author{
+		Source:   db.SourceAPI,
+		UserUUID: userUUID,
+	}); err != nil {
+		errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
+		return
+	}
+	messageToJSON(w, "gallery updated", r.URL.Path)
+}
+
+// returnRevisions returns the edit history of a gallery, the latest first.
+func returnRevisions(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	galleryUUID := mux.Vars(r)["uuid"]
+	revisions, err := db.GetRevisions(galleryUUID)
+	if handleResult(w, revisions, err, true, r.URL.Path) {
+		return
+	}
+
+	resultToJSON(w, struct {
+		Data  []db.Revision
+		Count int
+	}{
+		Data:  revisions,
+		Count: len(revisions),
+	}, r.URL.Path)
+}
+
+// revertGallery restores a gallery to the state before the given revision.
+func revertGallery(w http.ResponseWriter, r *http.Request) {
+	access, userUUID := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	params := mux.Vars(r)
+	revisionID, err := strconv.ParseInt(params["id"], 10, 32)
+	if err != nil {
+		errorHandler(w, http.StatusBadRequest, "invalid revision id", r.URL.Path)
+		return
+	}
+
+	err = db.RevertGallery(params["uuid"], int32(revisionID), db.Author{Source: db.SourceRevert, UserUUID: userUUID})
+	if handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+
+	messageToJSON(w, "gallery reverted", r.URL.Path)
+}
+
+// returnLocks returns the fields of a gallery locked from the metadata parsers.
+func returnLocks(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	locks, err := db.GetLocks(mux.Vars(r)["uuid"])
+	if handleResult(w, locks, err, true, r.URL.Path) {
+		return
+	}
+
+	resultToJSON(w, struct {
+		Data     []string
+		Lockable []string
+		Count    int
+	}{
+		Data:     locks,
+		Lockable: db.LockableFields,
+		Count:    len(locks),
+	}, r.URL.Path)
+}
+
+// lockFields locks the given fields of a gallery. Fields edited through the API are locked automatically.
+func lockFields(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	formData := &LockForm{}
+	if err := json.NewDecoder(r.Body).Decode(formData); err != nil {
+		errorHandler(w, http.StatusBadRequest, err.Error(), r.URL.Path)
+		return
+	}
+
+	for _, field := range formData.Fields {
+		if !slices.Contains(db.LockableFields, field) {
+			errorHandler(w, http.StatusBadRequest, "field cannot be locked: "+field, r.URL.Path)
+			return
 		}
 	}
 
-	if err := db.UpdateGallery(newGallery, tags, newReference, false); err != nil {
+	if err := db.LockFields(mux.Vars(r)["uuid"], formData.Fields); err != nil {
+		errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
+		return
+	}
+
+	messageToJSON(w, "fields locked", r.URL.Path)
+}
+
+// unlockFields clears the locks of a gallery. Comma-separated fields query parameter limits the fields to unlock.
+func unlockFields(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	var fields []string
+	if value := r.URL.Query().Get("fields"); value != "" {
+		fields = strings.Split(value, ",")
+	}
+
+	if err := db.UnlockFields(mux.Vars(r)["uuid"], fields); err != nil {
 		errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
 		return
 	}
-	fmt.Fprintf(w, `{ "Message": "gallery updated" }`)
+
+	messageToJSON(w, "fields unlocked", r.URL.Path)
+}
+
+// bulkEditGalleries applies the same edit to many galleries selected by UUIDs or a filter.
+// All changes are made in a single transaction. With DryRun, the changes are only reported.
+func bulkEditGalleries(w http.ResponseWriter, r *http.Request) {
+	access, userUUID := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	formData := &BulkEditForm{}
+	if err := json.NewDecoder(r.Body).Decode(formData); err != nil {
+		errorHandler(w, http.StatusBadRequest, err.Error(), r.URL.Path)
+		return
+	}
+
+	if (formData.UUIDs == nil) == (formData.Filter == nil) {
+		errorHandler(w, http.StatusBadRequest, "either UUIDs or Filter is required", r.URL.Path)
+		return
+	}
+
+	edit := db.BulkEdit{
+		AddTags:    convertMapToTags(formData.AddTags),
+		RemoveTags: convertMapToTags(formData.RemoveTags),
+		Series:     formData.Series,
+		Category:   formData.Category,
+		Language:   formData.Language,
+		Nsfw:       formData.Nsfw,
+		Hidden:     formData.Hidden,
+	}
+	if edit.Empty() && !formData.Metadata {
+		errorHandler(w, http.StatusBadRequest, "no operations specified", r.URL.Path)
+		return
+	}
+
+	galleryUUIDs := formData.UUIDs
+	if formData.Filter != nil {
+		query, err := url.ParseQuery(*formData.Filter)
+		if err != nil {
+			errorHandler(w, http.StatusBadRequest, "invalid filter: "+err.Error(), r.URL.Path)
+			return
+		}
+
+		if galleryUUIDs, err = db.GetGalleryUUIDs(parseFilters(query), userUUID); err != nil {
+			errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
+			return
+		}
+	}
+
+	results, committed, err := db.BulkUpdateGalleries(galleryUUIDs, edit, formData.DryRun, db.Author{
+		Source:   db.SourceAPI,
+		UserUUID: userUUID,
+	})
+	if err != nil {
+		errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
+		return
+	}
+
+	if committed && formData.Metadata {
+		utils.RunJob(func(ctx context.Context) {
+			metadata.ParseGalleryMetadata(ctx, map[metadata.MetaType]bool{
+				metadata.XMeta:      true,
+				metadata.EHDLMeta:   true,
+				metadata.HathMeta:   true,
+				metadata.FuzzyMatch: true,
+			}, galleryUUIDs)
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	if !committed && !formData.DryRun {
+		w.WriteHeader(http.StatusConflict)
+	}
+
+	resultToJSON(w, struct {
+		Data      []db.BulkResult
+		Count     int
+		Committed bool
+		DryRun    bool
+	}{
+		Data:      results,
+		Count:     len(results),
+		Committed: committed,
+		DryRun:    formData.DryRun,
+	}, r.URL.Path)
 }
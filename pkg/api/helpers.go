@@ -11,20 +11,27 @@ import (
 	"github.com/weppos/publicsuffix-go/publicsuffix"
 	"math"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func parseQueryParams(r *http.Request) db.Filters {
-	order := db.Order(r.URL.Query().Get("order"))
-	sortBy := db.SortBy(r.URL.Query().Get("sortby"))
-	searchTerm := r.URL.Query().Get("search")
-	category := r.URL.Query().Get("category")
-	series := r.URL.Query().Get("series")
-	favoriteGroup := r.URL.Query().Get("favorite")
-	nsfw := r.URL.Query().Get("nsfw")
-	rawTags := r.URL.Query()["tag"] // namespace:name
-	grouped := r.URL.Query().Get("grouped")
+	return parseFilters(r.URL.Query())
+}
+
+// parseFilters parses gallery filters from query parameters.
+func parseFilters(query url.Values) db.Filters {
+	order := db.Order(query.Get("order"))
+	sortBy := db.SortBy(query.Get("sortby"))
+	searchTerm := query.Get("search")
+	category := query.Get("category")
+	series := query.Get("series")
+	favoriteGroup := query.Get("favorite")
+	nsfw := query.Get("nsfw")
+	rawTags := query["tag"] // namespace:name
+	grouped := query.Get("grouped")
 
 	var tags []model.Tag
 	for _, rawTag := range rawTags {
@@ -35,21 +42,21 @@ func parseQueryParams(r *http.Request) db.Filters {
 		tags = append(tags, model.Tag{Namespace: tag[0], Name: tag[1]})
 	}
 
-	limit, err := strconv.ParseUint(r.URL.Query().Get("limit"), 10, 64)
+	limit, err := strconv.ParseUint(query.Get("limit"), 10, 64)
 	if err != nil {
 		limit = 50
 	} else {
 		limit = utils.ClampU(limit, 1, 100)
 	}
 
-	offset, err := strconv.ParseUint(r.URL.Query().Get("offset"), 10, 64)
+	offset, err := strconv.ParseUint(query.Get("offset"), 10, 64)
 	if err != nil {
 		offset = 0
 	} else {
 		offset = utils.ClampU(offset, 0, math.MaxUint64)
 	}
 
-	seed, err := strconv.ParseUint(r.URL.Query().Get("seed"), 10, 64)
+	seed, err := strconv.ParseUint(query.Get("seed"), 10, 64)
 	if err != nil {
 		seed = 0
 	}
@@ -70,6 +77,17 @@ func parseQueryParams(r *http.Request) db.Filters {
 	}
 }
 
+// convertMapToTags converts tags mapped by their namespace to a list.
+func convertMapToTags(tagMap map[string][]string) []model.Tag {
+	tags := []model.Tag{}
+	for namespace, names := range tagMap {
+		for _, name := range names {
+			tags = append(tags, model.Tag{Namespace: namespace, Name: name})
+		}
+	}
+	return tags
+}
+
 func convertTagsToMap(tags []model.Tag) map[string][]string {
 	tagMap := map[string][]string{}
 	for _, tag := range tags {
@@ -79,6 +97,11 @@ func convertTagsToMap(tags []model.Tag) map[string][]string {
 }
 
 func convertMetadata(metadata db.CombinedMetadata) MetadataResult {
+	var cacheQuery string
+	if config.Options.Cache.WebServer {
+		cacheQuery = signCacheQuery(metadata.UUID, time.Now())
+	}
+
 	return MetadataResult{
 		ArchivePath: metadata.ArchivePath,
 		Hidden:      metadata.Hidden,
@@ -87,17 +110,20 @@ func convertMetadata(metadata db.CombinedMetadata) MetadataResult {
 		Reference:   metadata.Reference,
 		GalleryPref: metadata.GalleryPref,
 		Library:     metadata.Library,
+		CacheQuery:  cacheQuery,
 	}
 }
 
 // hasAccess handles access based on the Visibility option. Role restricts access to the specified role.
 // NoRole (0) allows access to anonymous users if the Visibility is Public or Restricted (passphrase required).
+// Personal access tokens are accepted in place of a JWT if they have the scope required by the route.
 func hasAccess(w http.ResponseWriter, r *http.Request, role db.Role) (bool, *string) {
-	publicAccess := config.Options.Visibility == config.Public && role == db.NoRole
+	visibility := config.Options.Reloadable().Visibility
+	publicAccess := visibility == config.Public && role == db.NoRole
 
 	token := readJWT(r)
 	if token != "" {
-		access, userUUID := verifyJWT(token, role)
+		access, userUUID := verifyToken(r, token, role)
 		if access {
 			return access, userUUID
 		}
@@ -106,9 +132,9 @@ func hasAccess(w http.ResponseWriter, r *http.Request, role db.Role) (bool, *str
 			return true, nil
 		}
 
-		restrictedAccess := config.Options.Visibility == config.Restricted && role == db.NoRole
-		if restrictedAccess && token == config.Credentials.Passphrase {
-			return true, nil
+		restrictedAccess := visibility == config.Restricted && role == db.NoRole
+		if restrictedAccess && !isSessionToken(token) {
+			return checkPassphrase(w, r, token), nil
 		}
 
 		errorHandler(w, http.StatusUnauthorized, "", r.URL.Path)
@@ -120,8 +146,14 @@ func hasAccess(w http.ResponseWriter, r *http.Request, role db.Role) (bool, *str
 		credentials := &Credentials{}
 		err := json.NewDecoder(r.Body).Decode(credentials)
 		if err == nil && credentials.Username != "" && credentials.Password != "" {
-			access, userUUID, _ := loginHelper(w, *credentials, role)
+			access, userUUID, _ := loginHelper(w, r, *credentials, role)
 			if !access {
+				return false, nil
+			}
+			if db.TOTPEnabled(*userUUID) && !verifySecondFactor(w, r, *userUUID, credentials.Code) {
+				return false, nil
+			}
+			if adminTwoFactorMissing(role, *userUUID) {
 				errorHandler(w, http.StatusUnauthorized, "", r.URL.Path)
 				return false, nil
 			}
@@ -138,24 +170,59 @@ func hasAccess(w http.ResponseWriter, r *http.Request, role db.Role) (bool, *str
 	return false, nil
 }
 
-// loginHelper handles login
-func loginHelper(w http.ResponseWriter, credentials Credentials, requiredRole db.Role) (bool, *string, *int32) {
-	err := db.MigratePassword(credentials.Username, credentials.Password)
+// loginHelper handles login. Failed attempts are throttled by IP and username, and lock the account after too many.
+func loginHelper(w http.ResponseWriter, r *http.Request, credentials Credentials, requiredRole db.Role) (bool, *string, *int32) {
+	now := time.Now()
+	keys := []string{ipKey(clientIP(r)), usernameKey(credentials.Username)}
+	if wait := throttle.retryAfter(now, keys...); wait > 0 {
+		tooManyRequests(w, r, wait)
+		return false, nil, nil
+	}
+
+	state, err := db.GetLoginState(credentials.Username)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			errorHandler(w, http.StatusUnauthorized, "", "")
+			loginFailed(r, keys, &credentials.Username, nil, "unknown user")
+			errorHandler(w, http.StatusUnauthorized, "", r.URL.Path)
 		} else {
-			errorHandler(w, http.StatusInternalServerError, err.Error(), "")
+			errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
+		}
+		return false, nil, nil
+	}
+	if state.LockedUntil != nil {
+		db.NewLoginAttempt(&credentials.Username, &state.UUID, clientIP(r), false, "account locked")
+		tooManyRequests(w, r, state.LockedUntil.Sub(now))
+		return false, nil, nil
+	}
+
+	err = db.MigratePassword(credentials.Username, credentials.Password)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorHandler(w, http.StatusUnauthorized, "", r.URL.Path)
+		} else {
+			errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
 		}
 		return false, nil, nil
 	}
 
 	userUUID, role, err := db.Login(credentials.Username, credentials.Password, requiredRole)
 	if err != nil || userUUID == nil {
-		errorHandler(w, http.StatusUnauthorized, "", "")
+		loginFailed(r, keys, &credentials.Username, &state.UUID, "invalid credentials")
+		errorHandler(w, http.StatusUnauthorized, "", r.URL.Path)
 		return false, nil, nil
 	}
 
+	// Checked only after the password, so that the state is not revealed to others.
+	if state.Pending {
+		db.NewLoginAttempt(&credentials.Username, &state.UUID, clientIP(r), false, "pending approval")
+		errorHandler(w, http.StatusForbidden, "", r.URL.Path)
+		return false, nil, nil
+	}
+
+	// The IP is not reset, so that logging in to an own account does not allow guessing the passwords of others.
+	throttle.reset(usernameKey(credentials.Username))
+	db.ResetLoginFailures(*userUUID)
+
 	return true, userUUID, role
 }
 
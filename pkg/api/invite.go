@@ -0,0 +1,136 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/db"
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	"github.com/Mangatsu/server/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+type InviteForm struct {
+	Role      string // admin, member or viewer. Defaults to viewer.
+	MaxUses   *int64 // Defaults to 1.
+	ExpiresIn *int64 // Seconds. Defaults to 7 days.
+}
+
+// returnInvites returns all invites. Only for admins.
+func returnInvites(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	invites, err := db.GetInvites()
+	if handleResult(w, invites, err, true, r.URL.Path) {
+		return
+	}
+
+	resultToJSON(w, struct {
+		Data  []model.Invite
+		Count int
+	}{
+		Data:  invites,
+		Count: len(invites),
+	}, r.URL.Path)
+}
+
+// newInvite creates an invite code for registering with a preset role. The code is only shown in this response.
+func newInvite(w http.ResponseWriter, r *http.Request) {
+	access, userUUID := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+	if userUUID == nil {
+		errorHandler(w, http.StatusBadRequest, "", r.URL.Path)
+		return
+	}
+
+	formData := &InviteForm{}
+	if err := json.NewDecoder(r.Body).Decode(formData); err != nil {
+		errorHandler(w, http.StatusBadRequest, err.Error(), r.URL.Path)
+		return
+	}
+
+	role := db.Viewer
+	if formData.Role != "" {
+		parsedRole, ok := db.ParseRole(formData.Role, db.Admin)
+		if !ok || parsedRole == db.NoRole {
+			errorHandler(w, http.StatusBadRequest, "role not valid", r.URL.Path)
+			return
+		}
+		role = parsedRole
+	}
+
+	maxUses := int64(1)
+	if formData.MaxUses != nil {
+		maxUses = utils.Clamp(*formData.MaxUses, 1, 1000)
+	}
+
+	expiresIn := int64(60 * 60 * 24 * 7)
+	if formData.ExpiresIn != nil {
+		expiresIn = utils.Clamp(*formData.ExpiresIn, 60, 60*60*24*365)
+	}
+	expiresAt := time.Now().Add(time.Duration(expiresIn) * time.Second)
+
+	plainCode, invite, err := db.NewInvite(role, int32(maxUses), expiresAt, *userUUID)
+	if handleResult(w, invite, err, false, r.URL.Path) {
+		return
+	}
+
+	resultToJSON(w, struct {
+		Code string
+		Data model.Invite
+	}{
+		Code: plainCode,
+		Data: invite,
+	}, r.URL.Path)
+}
+
+// deleteInvite revokes an invite. Only for admins.
+func deleteInvite(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	err := db.DeleteInvite(mux.Vars(r)["id"])
+	if handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+
+	messageToJSON(w, "invite revoked", r.URL.Path)
+}
+
+// approveUser allows a self-registered user waiting for approval to log in. Only for admins.
+func approveUser(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	err := db.ApproveUser(mux.Vars(r)["uuid"])
+	if handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+
+	messageToJSON(w, "user approved", r.URL.Path)
+}
+
+// rejectUser removes a self-registered user waiting for approval. Only for admins.
+func rejectUser(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	err := db.RejectUser(mux.Vars(r)["uuid"])
+	if handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+
+	messageToJSON(w, "user rejected", r.URL.Path)
+}
@@ -70,7 +70,8 @@ func verifyJWT(tokenString string, role db.Role) (bool, *string) {
 		claimedRole = int(*claims.Roles)
 	}
 
-	if err == nil && ok && token.Valid && db.VerifySession(claims.ID, claims.Subject) && claimedRole >= int(role) {
+	if err == nil && ok && token.Valid && db.VerifySession(claims.ID, claims.Subject) && claimedRole >= int(role) &&
+		!adminTwoFactorMissing(role, claims.Subject) {
 		return true, &claims.Subject
 	}
 
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Mangatsu/server/internal/config"
+	"github.com/Mangatsu/server/pkg/db"
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	"github.com/gorilla/mux"
+)
+
+type LibraryForm struct {
+	Path      *string
+	Layout    *string `schema:"enum=freeform|structured"`
+	Name      *string
+	Nsfw      *bool   // Default for new galleries
+	Language  *string // Default for new galleries
+	Direction *string // ltr, rtl or empty to use MTSU_LTR
+}
+
+// returnLibraries returns all libraries.
+func returnLibraries(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	libraries, err := db.GetOnlyLibraries()
+	if handleResult(w, libraries, err, true, r.URL.Path) {
+		return
+	}
+
+	resultToJSON(w, struct {
+		Data  []model.Library
+		Count int
+	}{
+		Data:  libraries,
+		Count: len(libraries),
+	}, r.URL.Path)
+}
+
+// returnLibrary returns a library.
+func returnLibrary(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	libraryID, ok := libraryIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	library, err := db.GetLibrary(libraryID)
+	if handleResult(w, library, err, false, r.URL.Path) {
+		return
+	}
+
+	resultToJSON(w, library, r.URL.Path)
+}
+
+// newLibrary adds a library. Its galleries are found by the next scan.
+func newLibrary(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	edit, ok := libraryEditFromRequest(w, r)
+	if !ok {
+		return
+	}
+	if edit.Path == nil || edit.Layout == nil {
+		errorHandler(w, http.StatusBadRequest, "path and layout are required", r.URL.Path)
+		return
+	}
+
+	library := model.Library{
+		Path:     *edit.Path,
+		Layout:   *edit.Layout,
+		Name:     edit.Name,
+		Language: edit.Language,
+		Ltr:      edit.LTR,
+	}
+	if edit.Nsfw != nil {
+		library.Nsfw = *edit.Nsfw
+	}
+
+	library, err := db.NewLibrary(library)
+	if errors.Is(err, db.ErrLibraryExists) {
+		errorHandler(w, http.StatusConflict, err.Error(), r.URL.Path)
+		return
+	}
+	if handleResult(w, library, err, false, r.URL.Path) {
+		return
+	}
+
+	resultToJSON(w, library, r.URL.Path)
+}
+
+// updateLibrary changes the options of a library.
+func updateLibrary(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	libraryID, ok := libraryIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	edit, ok := libraryEditFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	library, err := db.UpdateLibrary(libraryID, edit)
+	if errors.Is(err, db.ErrLibraryExists) {
+		errorHandler(w, http.StatusConflict, err.Error(), r.URL.Path)
+		return
+	}
+	if handleResult(w, library, err, false, r.URL.Path) {
+		return
+	}
+
+	resultToJSON(w, library, r.URL.Path)
+}
+
+// deleteLibrary removes a library and moves its galleries to the trash. Archives are left in place.
+func deleteLibrary(w http.ResponseWriter, r *http.Request) {
+	access, userUUID := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	libraryID, ok := libraryIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := db.DeleteLibrary(libraryID, db.Author{Source: db.SourceAPI, UserUUID: userUUID})
+	if handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+
+	messageToJSON(w, "library removed", r.URL.Path)
+}
+
+func libraryIDFromRequest(w http.ResponseWriter, r *http.Request) (int32, bool) {
+	libraryID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		errorHandler(w, http.StatusBadRequest, "invalid library id", r.URL.Path)
+		return 0, false
+	}
+
+	return int32(libraryID), true
+}
+
+// libraryEditFromRequest parses and validates the library form.
+func libraryEditFromRequest(w http.ResponseWriter, r *http.Request) (db.LibraryEdit, bool) {
+	formData := &LibraryForm{}
+	if err := json.NewDecoder(r.Body).Decode(formData); err != nil {
+		errorHandler(w, http.StatusBadRequest, err.Error(), r.URL.Path)
+		return db.LibraryEdit{}, false
+	}
+
+	edit := db.LibraryEdit{
+		Layout:   formData.Layout,
+		Name:     formData.Name,
+		Nsfw:     formData.Nsfw,
+		Language: formData.Language,
+	}
+
+	if formData.Path != nil {
+		libraryPath := filepath.ToSlash(filepath.Clean(*formData.Path))
+		info, err := os.Stat(libraryPath)
+		if *formData.Path == "" || err != nil || !info.IsDir() {
+			errorHandler(w, http.StatusBadRequest, "path not found or not a directory", r.URL.Path)
+			return db.LibraryEdit{}, false
+		}
+		edit.Path = &libraryPath
+	}
+
+	if formData.Layout != nil && *formData.Layout != string(config.Freeform) && *formData.Layout != config.Structured {
+		errorHandler(w, http.StatusBadRequest, "layout must be freeform or structured", r.URL.Path)
+		return db.LibraryEdit{}, false
+	}
+
+	if formData.Direction != nil {
+		switch *formData.Direction {
+		case "ltr", "rtl":
+			ltr := *formData.Direction == "ltr"
+			edit.LTR = &ltr
+		case "":
+			edit.ClearLTR = true
+		default:
+			errorHandler(w, http.StatusBadRequest, "direction must be ltr, rtl or empty", r.URL.Path)
+			return db.LibraryEdit{}, false
+		}
+	}
+
+	return edit, true
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Mangatsu/server/internal/config"
+	"github.com/Mangatsu/server/pkg/cache"
+	"github.com/Mangatsu/server/pkg/db"
+	"github.com/Mangatsu/server/pkg/log"
+	"github.com/Mangatsu/server/pkg/metrics"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+var registerGaugesOnce sync.Once
+
+// statusRecorder records the status code of the response. Unwrap lets http.ResponseController flush the stream
+// of events through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// metricsMiddleware counts the requests and measures their latency by the route template, such as
+// /api/v1/galleries/{uuid}, so that the number of series stays bounded.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+		next.ServeHTTP(recorder, r)
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		metrics.HTTPRequests.Inc(r.Method, route, strconv.Itoa(recorder.status))
+		metrics.HTTPDuration.Observe(time.Since(start).Seconds(), r.Method, route)
+	})
+}
+
+// returnMetrics serves the metrics in the Prometheus text format. Requires the metrics token if one is set.
+func returnMetrics(w http.ResponseWriter, r *http.Request) {
+	token := config.Credentials.MetricsToken
+	if token != "" && subtle.ConstantTimeCompare([]byte(readMetricsToken(r)), []byte(token)) != 1 {
+		errorHandler(w, http.StatusUnauthorized, "", r.URL.Path)
+		return
+	}
+
+	registerGaugesOnce.Do(registerGauges)
+	metrics.Handler().ServeHTTP(w, r)
+}
+
+// readMetricsToken reads the bearer token from the Authorization header.
+func readMetricsToken(r *http.Request) string {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
+}
+
+// registerGauges registers the totals read from the database and the cache when the metrics are collected.
+func registerGauges() {
+	totals := func(value func(db.Totals) int) func() (float64, bool) {
+		return func() (float64, bool) {
+			result, err := db.GetTotals()
+			if err != nil {
+				log.Z.Error("failed to count totals for metrics", zap.String("err", err.Error()))
+				return 0, false
+			}
+
+			return float64(value(result)), true
+		}
+	}
+
+	metrics.NewGaugeFunc("mangatsu_galleries", "Galleries outside the trash.",
+		totals(func(t db.Totals) int { return t.Galleries }))
+	metrics.NewGaugeFunc("mangatsu_tags", "Tags of the galleries.",
+		totals(func(t db.Totals) int { return t.Tags }))
+	metrics.NewGaugeFunc("mangatsu_users", "Registered users.",
+		totals(func(t db.Totals) int { return t.Users }))
+	metrics.NewGaugeFunc("mangatsu_cache_galleries", "Galleries extracted to the cache.",
+		func() (float64, bool) { return float64(cache.Size()), true })
+}
+
+// metricsHandler serves the metrics on a separate address, for example only on the loopback interface.
+func metricsHandler() http.Handler {
+	router := http.NewServeMux()
+	router.HandleFunc("GET /metrics", returnMetrics)
+
+	return router
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/Mangatsu/server/internal/config"
+	"github.com/Mangatsu/server/pkg/db"
+	"github.com/Mangatsu/server/pkg/log"
+	"github.com/Mangatsu/server/pkg/oidc"
+	"github.com/Mangatsu/server/pkg/utils"
+	"go.uber.org/zap"
+)
+
+var oidcProvider *oidc.Provider
+var oidcProviderMu sync.Mutex
+
+// oidcStateCookie ties the login to the browser that started it.
+const oidcStateCookie = "mtsu.oidc_state"
+
+// oidcLogin redirects the user to log in at the OpenID Connect provider.
+func oidcLogin(w http.ResponseWriter, r *http.Request) {
+	if !config.Options.OIDC.Enabled() {
+		errorHandler(w, http.StatusNotFound, "", r.URL.Path)
+		return
+	}
+
+	provider, err := getOIDCProvider(r.Context())
+	if err != nil {
+		errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
+		return
+	}
+
+	authURL, state, err := provider.StartLogin()
+	if err != nil {
+		errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
+		return
+	}
+
+	http.SetCookie(w, newOIDCStateCookie(state, int(oidc.LoginTTL.Seconds())))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// oidcCallback finishes the OpenID Connect login. The user is created on the first login and their role is
+// synced from the groups claim. Sets the same cookie as the regular login.
+func oidcCallback(w http.ResponseWriter, r *http.Request) {
+	if !config.Options.OIDC.Enabled() {
+		errorHandler(w, http.StatusNotFound, "", r.URL.Path)
+		return
+	}
+
+	// The state cookie is only used once, whatever the outcome.
+	var browserState string
+	if cookie, err := r.Cookie(oidcStateCookie); err == nil {
+		browserState = cookie.Value
+	}
+	http.SetCookie(w, newOIDCStateCookie("", -1))
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		log.Z.Debug("oidc login failed at the provider",
+			zap.String("error", query.Get("error")),
+			zap.String("description", query.Get("error_description")))
+		errorHandler(w, http.StatusUnauthorized, "", r.URL.Path)
+		return
+	}
+
+	provider, err := getOIDCProvider(r.Context())
+	if err != nil {
+		errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
+		return
+	}
+
+	identity, err := provider.FinishLogin(r.Context(), query.Get("state"), browserState, query.Get("code"))
+	if errors.Is(err, oidc.ErrInvalidState) {
+		errorHandler(w, http.StatusBadRequest, err.Error(), r.URL.Path)
+		return
+	}
+	if err != nil {
+		log.Z.Info("oidc login failed", zap.String("err", err.Error()))
+		errorHandler(w, http.StatusUnauthorized, "", r.URL.Path)
+		return
+	}
+
+	role, allowed := oidcRole(identity.Groups)
+	if !allowed {
+		log.Z.Info("oidc login denied, no role for the groups",
+			zap.String("subject", identity.Subject),
+			zap.Strings("groups", identity.Groups))
+		errorHandler(w, http.StatusForbidden, "", r.URL.Path)
+		return
+	}
+
+	userUUID, role, err := db.ProvisionExternalUser(identity.Issuer, identity.Subject, identity.Username, role)
+	if err != nil {
+		errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
+		return
+	}
+
+	sessionName := "OpenID Connect"
+	claimedRole := int32(role)
+	token, err := newJWT(userUUID, nil, &sessionName, &claimedRole)
+	if err != nil {
+		errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
+		return
+	}
+
+	http.SetCookie(w, newJWTCookie("Bearer "+token, utils.ClampCookieAge(nil)))
+	http.Redirect(w, r, config.Options.OIDC.LoginRedirect, http.StatusFound)
+}
+
+// newOIDCStateCookie returns the cookie holding the state of the login. SameSite=Lax, so that it's sent when the
+// provider redirects back.
+func newOIDCStateCookie(state string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Domain:   config.Options.Domain,
+		Path:     "/api/v1/oidc",
+		MaxAge:   maxAge,
+		Secure:   config.Options.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// getOIDCProvider returns the OpenID Connect provider. Discovery is done on the first login, so that the server
+// starts even if the provider is unavailable.
+func getOIDCProvider(ctx context.Context) (*oidc.Provider, error) {
+	oidcProviderMu.Lock()
+	defer oidcProviderMu.Unlock()
+
+	if oidcProvider != nil {
+		return oidcProvider, nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, config.Options.OIDC, config.Credentials.OIDCClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	oidcProvider = provider
+	return oidcProvider, nil
+}
+
+// oidcRole returns the highest role mapped to the groups, or the default role. False if the user has neither.
+func oidcRole(groups []string) (db.Role, bool) {
+	found := false
+	highest := db.NoRole
+	for _, group := range groups {
+		roleName, mapped := config.Options.OIDC.RoleMapping[group]
+		if !mapped {
+			continue
+		}
+
+		role, ok := db.ParseRole(roleName, db.Admin)
+		if !ok {
+			log.Z.Warn("invalid role in MTSU_OIDC_ROLE_MAPPING", zap.String("group", group), zap.String("role", roleName))
+			continue
+		}
+
+		if !found || role > highest {
+			highest = role
+			found = true
+		}
+	}
+	if found {
+		return highest, true
+	}
+
+	if config.Options.OIDC.DefaultRole == "" {
+		return db.NoRole, false
+	}
+
+	return db.ParseRole(config.Options.OIDC.DefaultRole, db.Admin)
+}
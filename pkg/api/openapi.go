@@ -0,0 +1,320 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Mangatsu/server/pkg/cache"
+	"github.com/Mangatsu/server/pkg/db"
+	"github.com/Mangatsu/server/pkg/openapi"
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	"github.com/gorilla/mux"
+)
+
+// maxBodySize limits the size of the JSON request bodies.
+const maxBodySize = 1 << 20
+
+// operation describes a route in the OpenAPI document. Body is the type the request body is decoded to, and it is
+// validated against the schema generated from it. Public routes do not need credentials.
+type operation struct {
+	Method   string
+	Path     string
+	Tag      string
+	Summary  string
+	Query    []string
+	Body     any
+	Response any
+	Public   bool
+}
+
+var galleryFilters = []string{"order", "sortby", "search", "category", "series", "favorite", "nsfw", "tag", "grouped",
+	"limit", "offset", "seed"}
+
+// operations must list every route of handleRequests. Paths are in the OpenAPI format without the patterns.
+var operations = []operation{
+	{Method: "GET", Path: "/", Tag: "info", Summary: "Link to the API", Public: true},
+	{Method: "GET", Path: "/api", Tag: "info", Summary: "Server version and public options", Public: true},
+	{Method: "GET", Path: "/api/v1/openapi.json", Tag: "info", Summary: "This OpenAPI document", Public: true},
+	{Method: "GET", Path: "/api/v1/statistics", Tag: "info", Summary: "Statistics (not implemented)"},
+	{Method: "GET", Path: "/metrics", Tag: "info", Summary: "Prometheus metrics, if enabled without a separate address"},
+	{Method: "GET", Path: "/cache/{path}", Tag: "cache", Summary: "Cached page or thumbnail", Query: []string{"sig"}},
+
+	{Method: "POST", Path: "/api/v1/register", Tag: "auth", Summary: "Register a user", Body: Credentials{}, Public: true},
+	{Method: "POST", Path: "/api/v1/login", Tag: "auth", Summary: "Log in with a password or the passphrase", Body: Credentials{}, Response: LoginResponse{}, Public: true},
+	{Method: "POST", Path: "/api/v1/login/2fa", Tag: "auth", Summary: "Finish a login with a two-factor code", Body: TwoFactorLoginForm{}, Response: LoginResponse{}, Public: true},
+	{Method: "POST", Path: "/api/v1/logout", Tag: "auth", Summary: "Log out", Public: true},
+	{Method: "GET", Path: "/api/v1/oidc/login", Tag: "auth", Summary: "Start an OpenID Connect login", Public: true},
+	{Method: "GET", Path: "/api/v1/oidc/callback", Tag: "auth", Summary: "Finish an OpenID Connect login", Query: []string{"code", "state", "error", "error_description"}, Public: true},
+
+	{Method: "GET", Path: "/api/v1/users", Tag: "users", Summary: "List users"},
+	{Method: "PUT", Path: "/api/v1/users/{uuid}", Tag: "users", Summary: "Update a user", Body: db.UserForm{}},
+	{Method: "DELETE", Path: "/api/v1/users/{uuid}", Tag: "users", Summary: "Delete a user"},
+	{Method: "GET", Path: "/api/v1/users/me/favorites", Tag: "users", Summary: "List favorite groups of the user"},
+	{Method: "GET", Path: "/api/v1/users/me/sessions", Tag: "users", Summary: "List sessions of the user"},
+	{Method: "DELETE", Path: "/api/v1/users/me/sessions", Tag: "users", Summary: "Delete a session of the user", Body: SessionForm{}},
+	{Method: "GET", Path: "/api/v1/users/me/tokens", Tag: "users", Summary: "List personal access tokens of the user"},
+	{Method: "POST", Path: "/api/v1/users/me/tokens", Tag: "users", Summary: "Create a personal access token", Body: APITokenForm{}},
+	{Method: "DELETE", Path: "/api/v1/users/me/tokens/{id}", Tag: "users", Summary: "Revoke a personal access token"},
+	{Method: "POST", Path: "/api/v1/users/me/2fa", Tag: "users", Summary: "Start enabling two-factor authentication"},
+	{Method: "DELETE", Path: "/api/v1/users/me/2fa", Tag: "users", Summary: "Disable two-factor authentication", Body: TwoFactorForm{}},
+	{Method: "POST", Path: "/api/v1/users/me/2fa/confirm", Tag: "users", Summary: "Enable two-factor authentication", Body: TwoFactorForm{}},
+	{Method: "POST", Path: "/api/v1/users/me/2fa/recovery-codes", Tag: "users", Summary: "Regenerate the recovery codes", Body: TwoFactorForm{}},
+	{Method: "DELETE", Path: "/api/v1/users/{uuid}/2fa", Tag: "users", Summary: "Reset two-factor authentication of a user"},
+	{Method: "DELETE", Path: "/api/v1/users/{uuid}/lock", Tag: "users", Summary: "Unlock a user locked by failed logins"},
+	{Method: "POST", Path: "/api/v1/users/{uuid}/approve", Tag: "users", Summary: "Approve a registered user"},
+	{Method: "POST", Path: "/api/v1/users/{uuid}/reject", Tag: "users", Summary: "Reject a registered user"},
+	{Method: "GET", Path: "/api/v1/login-attempts", Tag: "users", Summary: "List login attempts", Query: []string{"limit", "offset", "username", "user", "ip", "failed"}},
+	{Method: "GET", Path: "/api/v1/invites", Tag: "users", Summary: "List invites"},
+	{Method: "POST", Path: "/api/v1/invites", Tag: "users", Summary: "Create an invite", Body: InviteForm{}},
+	{Method: "DELETE", Path: "/api/v1/invites/{id}", Tag: "users", Summary: "Revoke an invite"},
+
+	{Method: "GET", Path: "/api/v1/groups", Tag: "groups", Summary: "List groups and their members"},
+	{Method: "POST", Path: "/api/v1/groups", Tag: "groups", Summary: "Create a group", Body: GroupForm{}},
+	{Method: "DELETE", Path: "/api/v1/groups/{id}", Tag: "groups", Summary: "Delete a group"},
+	{Method: "PUT", Path: "/api/v1/groups/{id}/members/{uuid}", Tag: "groups", Summary: "Add a member to a group"},
+	{Method: "DELETE", Path: "/api/v1/groups/{id}/members/{uuid}", Tag: "groups", Summary: "Remove a member from a group"},
+
+	{Method: "GET", Path: "/api/v1/config", Tag: "server", Summary: "Configuration in use, secrets redacted"},
+	{Method: "POST", Path: "/api/v1/config/reload", Tag: "server", Summary: "Reload the options that can be changed at runtime"},
+	{Method: "GET", Path: "/api/v1/status", Tag: "tasks", Summary: "Processing status and the latest task runs", Response: cache.StatusSnapshot{}},
+	{Method: "DELETE", Path: "/api/v1/status", Tag: "tasks", Summary: "Clear the processing status"},
+	{Method: "GET", Path: "/api/v1/events", Tag: "tasks", Summary: "Task events as Server-Sent Events", Query: []string{"types", "lastEventId"}},
+	{Method: "GET", Path: "/api/v1/scan", Tag: "tasks", Summary: "Scan the libraries for new archives", Query: []string{"full"}},
+	{Method: "GET", Path: "/api/v1/thumbnails", Tag: "tasks", Summary: "Generate thumbnails", Query: []string{"pages", "force"}},
+	{Method: "GET", Path: "/api/v1/meta", Tag: "tasks", Summary: "Parse metadata", Query: []string{"title", "x", "ehdl", "hath", "fuzzy", "providers"}},
+	{Method: "GET", Path: "/api/v1/meta/review", Tag: "tasks", Summary: "List metadata matches waiting for a review", Query: []string{"status"}},
+	{Method: "GET", Path: "/api/v1/meta/review/{id}", Tag: "tasks", Summary: "Metadata match waiting for a review"},
+	{Method: "POST", Path: "/api/v1/meta/review/{id}/accept", Tag: "tasks", Summary: "Accept a metadata match"},
+	{Method: "POST", Path: "/api/v1/meta/review/{id}/reject", Tag: "tasks", Summary: "Reject a metadata match"},
+
+	{Method: "GET", Path: "/api/v1/categories", Tag: "galleries", Summary: "List categories"},
+	{Method: "GET", Path: "/api/v1/series", Tag: "series", Summary: "List series"},
+	{Method: "GET", Path: "/api/v1/series/{id}", Tag: "series", Summary: "Series and its galleries in reading order", Response: SeriesGalleriesResult{}},
+	{Method: "PUT", Path: "/api/v1/series/{id}", Tag: "series", Summary: "Rename a series or update its details", Body: SeriesForm{}},
+	{Method: "DELETE", Path: "/api/v1/series/{id}", Tag: "series", Summary: "Remove a series from its galleries"},
+	{Method: "GET", Path: "/api/v1/series/{id}/next", Tag: "series", Summary: "Next unread gallery of a series", Response: MetadataResult{}},
+	{Method: "POST", Path: "/api/v1/series/{id}/merge", Tag: "series", Summary: "Merge series into a series", Body: SeriesMergeForm{}},
+	{Method: "PUT", Path: "/api/v1/series/{id}/order", Tag: "series", Summary: "Set the reading order of a series", Body: SeriesOrderForm{}},
+	{Method: "GET", Path: "/api/v1/tags", Tag: "galleries", Summary: "List tags"},
+
+	{Method: "GET", Path: "/api/v1/libraries", Tag: "libraries", Summary: "List libraries"},
+	{Method: "POST", Path: "/api/v1/libraries", Tag: "libraries", Summary: "Add a library", Body: LibraryForm{}, Response: model.Library{}},
+	{Method: "GET", Path: "/api/v1/libraries/{id}", Tag: "libraries", Summary: "Library", Response: model.Library{}},
+	{Method: "PUT", Path: "/api/v1/libraries/{id}", Tag: "libraries", Summary: "Update a library", Body: LibraryForm{}, Response: model.Library{}},
+	{Method: "DELETE", Path: "/api/v1/libraries/{id}", Tag: "libraries", Summary: "Remove a library"},
+	{Method: "GET", Path: "/api/v1/libraries/{id}/access", Tag: "libraries", Summary: "List access rules of a library"},
+	{Method: "PUT", Path: "/api/v1/libraries/{id}/access", Tag: "libraries", Summary: "Allow or deny access to a library", Body: LibraryAccessForm{}},
+	{Method: "DELETE", Path: "/api/v1/libraries/{id}/access/{type}/{principal}", Tag: "libraries", Summary: "Remove an access rule"},
+
+	{Method: "GET", Path: "/api/v1/webhooks", Tag: "webhooks", Summary: "List webhooks"},
+	{Method: "POST", Path: "/api/v1/webhooks", Tag: "webhooks", Summary: "Register a webhook, returning its signing secret", Body: WebhookForm{}, Response: WebhookResult{}},
+	{Method: "GET", Path: "/api/v1/webhooks/{id}", Tag: "webhooks", Summary: "Webhook", Response: WebhookResult{}},
+	{Method: "PUT", Path: "/api/v1/webhooks/{id}", Tag: "webhooks", Summary: "Update a webhook", Body: WebhookForm{}, Response: WebhookResult{}},
+	{Method: "DELETE", Path: "/api/v1/webhooks/{id}", Tag: "webhooks", Summary: "Remove a webhook and its deliveries"},
+	{Method: "GET", Path: "/api/v1/webhooks/{id}/deliveries", Tag: "webhooks", Summary: "Delivery log of a webhook", Query: []string{"status", "limit", "offset"}},
+	{Method: "POST", Path: "/api/v1/webhooks/{id}/deliveries/{delivery}/retry", Tag: "webhooks", Summary: "Deliver again"},
+
+	{Method: "GET", Path: "/api/v1/trash", Tag: "galleries", Summary: "List deleted galleries"},
+	{Method: "POST", Path: "/api/v1/trash/{uuid}/restore", Tag: "galleries", Summary: "Restore a deleted gallery"},
+	{Method: "DELETE", Path: "/api/v1/trash/{uuid}", Tag: "galleries", Summary: "Purge a deleted gallery"},
+
+	{Method: "GET", Path: "/api/v1/galleries", Tag: "galleries", Summary: "List galleries", Query: galleryFilters},
+	{Method: "GET", Path: "/api/v1/galleries/count", Tag: "galleries", Summary: "Count galleries", Query: galleryFilters},
+	{Method: "GET", Path: "/api/v1/galleries/random", Tag: "galleries", Summary: "Random gallery", Query: galleryFilters},
+	{Method: "POST", Path: "/api/v1/galleries/bulk", Tag: "galleries", Summary: "Edit many galleries", Body: BulkEditForm{}},
+	{Method: "PUT", Path: "/api/v1/galleries/{uuid}", Tag: "galleries", Summary: "Update a gallery", Body: UpdateGalleryForm{}},
+	{Method: "GET", Path: "/api/v1/galleries/{uuid}", Tag: "galleries", Summary: "Gallery and its files", Query: []string{"meta"}, Response: GalleryResult{}},
+	{Method: "DELETE", Path: "/api/v1/galleries/{uuid}", Tag: "galleries", Summary: "Move a gallery to the trash"},
+	{Method: "GET", Path: "/api/v1/galleries/{uuid}/revisions", Tag: "galleries", Summary: "Edit history of a gallery"},
+	{Method: "POST", Path: "/api/v1/galleries/{uuid}/revisions/{id}/revert", Tag: "galleries", Summary: "Undo a revision"},
+	{Method: "GET", Path: "/api/v1/galleries/{uuid}/locks", Tag: "galleries", Summary: "Locked fields of a gallery"},
+	{Method: "PUT", Path: "/api/v1/galleries/{uuid}/locks", Tag: "galleries", Summary: "Lock fields of a gallery", Body: LockForm{}},
+	{Method: "DELETE", Path: "/api/v1/galleries/{uuid}/locks", Tag: "galleries", Summary: "Unlock fields of a gallery", Query: []string{"fields"}},
+	{Method: "PATCH", Path: "/api/v1/galleries/{uuid}/progress/{progress}", Tag: "galleries", Summary: "Update the reading progress"},
+	{Method: "PATCH", Path: "/api/v1/galleries/{uuid}/favorite/{name}", Tag: "galleries", Summary: "Add a gallery to a favorite group"},
+	{Method: "PATCH", Path: "/api/v1/galleries/{uuid}/favorite", Tag: "galleries", Summary: "Remove a gallery from the favorites"},
+}
+
+// requestSchemas are the generated schemas of the request bodies by the route, such as "PUT /api/v1/users/{uuid}".
+var requestSchemas = make(map[string]*openapi.Schema)
+
+var openAPIOnce sync.Once
+var openAPIDocument []byte
+
+func init() {
+	for _, op := range operations {
+		if op.Body != nil {
+			requestSchemas[op.Method+" "+op.Path] = openapi.SchemaOf(op.Body)
+		}
+	}
+}
+
+// buildOpenAPI generates the OpenAPI document from the operations.
+func buildOpenAPI() openapi.Document {
+	document := openapi.Document{
+		OpenAPI: "3.0.3",
+		Info:    openapi.Info{Title: "Mangatsu API", Version: serverVersion},
+		Paths:   make(map[string]map[string]openapi.Operation),
+		Components: openapi.Components{
+			Schemas: map[string]*openapi.Schema{"Error": openapi.SchemaOf(ErrorResponse{})},
+			SecuritySchemes: map[string]openapi.SecurityScheme{
+				"bearer": {Type: "http", Scheme: "bearer"},
+				"cookie": {Type: "apiKey", In: "cookie", Name: "mtsu.jwt"},
+			},
+		},
+	}
+
+	errorResponse := func(description string) openapi.Response {
+		return openapi.Response{Description: description, Content: openapi.JSON(openapi.Ref("Error"))}
+	}
+	schemaRef := func(v any) *openapi.Schema {
+		name := reflect.TypeOf(v).Name()
+		document.Components.Schemas[name] = openapi.SchemaOf(v)
+		return openapi.Ref(name)
+	}
+
+	for _, op := range operations {
+		operation := openapi.Operation{
+			Summary: op.Summary,
+			Tags:    []string{op.Tag},
+			Responses: map[string]openapi.Response{
+				"200":     {Description: "OK"},
+				"default": errorResponse("Error"),
+			},
+		}
+
+		for _, match := range pathParamR.FindAllStringSubmatch(op.Path, -1) {
+			operation.Parameters = append(operation.Parameters, openapi.Parameter{
+				Name: match[1], In: "path", Required: true, Schema: &openapi.Schema{Type: "string"},
+			})
+		}
+		for _, name := range op.Query {
+			operation.Parameters = append(operation.Parameters, openapi.Parameter{
+				Name: name, In: "query", Schema: &openapi.Schema{Type: "string"},
+			})
+		}
+
+		if op.Body != nil {
+			operation.RequestBody = &openapi.RequestBody{Required: true, Content: openapi.JSON(schemaRef(op.Body))}
+			operation.Responses["400"] = errorResponse("Invalid request body")
+		}
+		if op.Response != nil {
+			operation.Responses["200"] = openapi.Response{Description: "OK", Content: openapi.JSON(schemaRef(op.Response))}
+		}
+		if !op.Public {
+			operation.Security = []map[string][]string{{}, {"bearer": {}}, {"cookie": {}}}
+		}
+
+		if document.Paths[op.Path] == nil {
+			document.Paths[op.Path] = make(map[string]openapi.Operation)
+		}
+		document.Paths[op.Path][strings.ToLower(op.Method)] = operation
+	}
+
+	return document
+}
+
+// returnOpenAPI returns the OpenAPI document of the API.
+func returnOpenAPI(w http.ResponseWriter, r *http.Request) {
+	openAPIOnce.Do(func() {
+		var err error
+		if openAPIDocument, err = json.Marshal(buildOpenAPI()); err != nil {
+			panic(err)
+		}
+	})
+
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	_, _ = w.Write(openAPIDocument)
+}
+
+var pathParamR = regexp.MustCompile(`{([^}]+)}`)
+
+// routePath returns the path of the matched route in the OpenAPI format, without the patterns of the variables.
+// The patterns may contain braces themselves, such as {uuid:[0-9a-f]{8}}.
+func routePath(route *mux.Route) string {
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+
+	var path strings.Builder
+	depth := 0
+	inPattern := false
+	for _, char := range template {
+		switch {
+		case char == '{':
+			depth++
+			if depth == 1 {
+				path.WriteRune(char)
+				continue
+			}
+		case char == '}':
+			depth--
+			if depth == 0 {
+				inPattern = false
+				path.WriteRune(char)
+				continue
+			}
+		case char == ':' && depth == 1:
+			inPattern = true
+		}
+
+		if !inPattern {
+			path.WriteRune(char)
+		}
+	}
+
+	return path.String()
+}
+
+// validateRequest validates JSON request bodies against the schemas of the routes before they are handled.
+func validateRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		schema, found := requestSchemas[r.Method+" "+routePath(route)]
+		if !found {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				errorHandler(w, http.StatusRequestEntityTooLarge, "", r.URL.Path)
+				return
+			}
+			errorHandler(w, http.StatusBadRequest, "could not read the request body", r.URL.Path)
+			return
+		}
+		if len(bytes.TrimSpace(body)) == 0 {
+			errorWithCode(w, http.StatusBadRequest, CodeBodyRequired, "request body is required", r.URL.Path)
+			return
+		}
+
+		problems, err := schema.ValidateJSON(body)
+		if err != nil {
+			errorWithCode(w, http.StatusBadRequest, CodeInvalidJSON, "invalid JSON: "+err.Error(), r.URL.Path)
+			return
+		}
+		if len(problems) > 0 {
+			validationFailed(w, "invalid request body", problems, r.URL.Path)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Mangatsu/server/internal/config"
+	"github.com/gorilla/mux"
+)
+
+// TestOperationsCoverRoutes checks that every route is described in the OpenAPI document and vice versa.
+func TestOperationsCoverRoutes(t *testing.T) {
+	config.Options = &config.OptionsModel{
+		Cache:   config.CacheOptions{WebServer: true},
+		Metrics: config.MetricsOptions{Enabled: true},
+	}
+
+	documented := make(map[string]bool)
+	for _, op := range operations {
+		key := op.Method + " " + op.Path
+		if documented[key] {
+			t.Errorf("%s is described more than once", key)
+		}
+		documented[key] = true
+	}
+
+	routed := make(map[string]bool)
+	err := newRouter().Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		methods, err := route.GetMethods()
+		if err != nil {
+			// Only the file server of the cache is routed by a prefix without methods.
+			routed["GET /cache/{path}"] = true
+			return nil
+		}
+
+		for _, method := range methods {
+			routed[method+" "+routePath(route)] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key := range routed {
+		if !documented[key] {
+			t.Errorf("%s is not described in the OpenAPI document", key)
+		}
+	}
+	for key := range documented {
+		if !routed[key] {
+			t.Errorf("%s is described but not routed", key)
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	document := buildOpenAPI()
+
+	content, err := json.Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parsed map[string]any
+	if err = json.Unmarshal(content, &parsed); err != nil {
+		t.Fatal(err)
+	}
+
+	login := document.Paths["/api/v1/login"]["post"]
+	if login.RequestBody == nil || login.RequestBody.Content["application/json"].Schema.Ref != "#/components/schemas/Credentials" {
+		t.Errorf("Expected the login to refer to the Credentials schema, got %+v", login.RequestBody)
+	}
+
+	for _, name := range []string{"Credentials", "UpdateGalleryForm", "BulkEditForm", "Error"} {
+		if document.Components.Schemas[name] == nil {
+			t.Errorf("Expected the %s schema in the components", name)
+		}
+	}
+
+	uuidParams := document.Paths["/api/v1/galleries/{uuid}"]["get"].Parameters
+	if len(uuidParams) == 0 || uuidParams[0].Name != "uuid" || uuidParams[0].In != "path" {
+		t.Errorf("Expected the uuid path parameter, got %+v", uuidParams)
+	}
+}
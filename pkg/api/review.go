@@ -0,0 +1,134 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Mangatsu/server/pkg/db"
+	"github.com/gorilla/mux"
+)
+
+// returnMetaCandidates returns metadata matches in the review queue. Defaults to pending ones.
+func returnMetaCandidates(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	status := db.CandidateStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "":
+		status = db.CandidatePending
+	case "all":
+		status = ""
+	case db.CandidatePending, db.CandidateAccepted, db.CandidateRejected:
+	default:
+		errorHandler(w, http.StatusBadRequest, "invalid status", r.URL.Path)
+		return
+	}
+
+	candidates, err := db.GetMetaCandidates(status)
+	if handleResult(w, candidates, err, true, r.URL.Path) {
+		return
+	}
+
+	resultToJSON(w, struct {
+		Data  []db.ReviewCandidate
+		Count int
+	}{
+		Data:  candidates,
+		Count: len(candidates),
+	}, r.URL.Path)
+}
+
+// returnMetaCandidate returns a candidate and the changes accepting it would make to the gallery.
+func returnMetaCandidate(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	candidate, ok := candidateFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	changes, err := db.PreviewMetaCandidate(candidate)
+	if handleResult(w, changes, err, true, r.URL.Path) {
+		return
+	}
+
+	resultToJSON(w, struct {
+		Candidate db.ReviewCandidate
+		Changes   []db.FieldChange
+	}{
+		Candidate: candidate,
+		Changes:   changes,
+	}, r.URL.Path)
+}
+
+// acceptMetaCandidate applies the candidate to the gallery.
+func acceptMetaCandidate(w http.ResponseWriter, r *http.Request) {
+	access, userUUID := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	candidate, ok := candidateFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if candidate.Status != db.CandidatePending {
+		errorHandler(w, http.StatusConflict, "candidate already "+string(candidate.Status), r.URL.Path)
+		return
+	}
+
+	if err := db.AcceptMetaCandidate(candidate, userUUID); err != nil {
+		errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
+		return
+	}
+
+	messageToJSON(w, "candidate accepted", r.URL.Path)
+}
+
+// rejectMetaCandidate rejects the candidate. Rejected candidates are not proposed again.
+func rejectMetaCandidate(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	candidate, ok := candidateFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if candidate.Status == db.CandidateAccepted {
+		errorHandler(w, http.StatusConflict, "candidate already accepted", r.URL.Path)
+		return
+	}
+
+	if err := db.SetMetaCandidateStatus(candidate.ID, db.CandidateRejected); err != nil {
+		errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
+		return
+	}
+
+	messageToJSON(w, "candidate rejected", r.URL.Path)
+}
+
+// candidateFromRequest returns the candidate of the id path parameter. Writes the error response if not found.
+func candidateFromRequest(w http.ResponseWriter, r *http.Request) (db.ReviewCandidate, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		errorHandler(w, http.StatusBadRequest, "invalid candidate id", r.URL.Path)
+		return db.ReviewCandidate{}, false
+	}
+
+	candidate, err := db.GetMetaCandidate(int32(id))
+	if handleResult(w, candidate, err, false, r.URL.Path) {
+		return db.ReviewCandidate{}, false
+	}
+
+	return candidate, true
+}
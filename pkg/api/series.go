@@ -0,0 +1,299 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/db"
+	"github.com/gorilla/mux"
+)
+
+type SeriesForm struct {
+	Name              *string `schema:"min=1"`
+	Description       *string
+	Cover             *string // UUID of a gallery of the series. Empty for the first gallery.
+	Status            *string `schema:"enum=|ongoing|completed|hiatus|cancelled"`
+	AlternativeTitles []string
+	Tags              map[string][]string
+}
+
+type SeriesMergeForm struct {
+	Series []int32 `schema:"required,min=1"` // IDs of the series merged into this one
+}
+
+type SeriesOrderForm struct {
+	Galleries []string `schema:"required"` // UUIDs in reading order. Empty to order by volume and chapter.
+}
+
+type SeriesResult struct {
+	ID                int32
+	Name              string
+	Description       *string
+	Cover             *string
+	Status            *string
+	AlternativeTitles []string
+	Tags              map[string][]string
+	GalleryCount      int32
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+// SeriesGalleriesResult is a series with its galleries in reading order.
+type SeriesGalleriesResult struct {
+	Meta      SeriesResult
+	Galleries []MetadataResult
+	Count     int
+}
+
+// returnSeries returns all series that have galleries the user may view.
+func returnSeries(w http.ResponseWriter, r *http.Request) {
+	access, userUUID := hasAccess(w, r, db.NoRole)
+	if !access {
+		return
+	}
+
+	series, err := db.GetSeries(userUUID)
+	if handleResult(w, series, err, true, r.RequestURI) {
+		return
+	}
+
+	results := make([]SeriesResult, 0, len(series))
+	for _, s := range series {
+		results = append(results, convertSeries(s))
+	}
+
+	resultToJSON(w, struct {
+		Data  []SeriesResult
+		Count int
+	}{
+		Data:  results,
+		Count: len(results),
+	}, r.RequestURI)
+}
+
+// returnSeriesGalleries returns a series with its galleries in reading order.
+func returnSeriesGalleries(w http.ResponseWriter, r *http.Request) {
+	access, userUUID := hasAccess(w, r, db.NoRole)
+	if !access {
+		return
+	}
+
+	series, galleries, ok := seriesGalleriesFromRequest(w, r, userUUID)
+	if !ok {
+		return
+	}
+
+	result := SeriesGalleriesResult{Meta: convertSeries(series), Galleries: make([]MetadataResult, 0, len(galleries))}
+	for _, gallery := range galleries {
+		result.Galleries = append(result.Galleries, convertMetadata(gallery))
+	}
+	result.Count = len(result.Galleries)
+	if result.Meta.Cover == nil && result.Count > 0 {
+		result.Meta.Cover = &result.Galleries[0].UUID
+	}
+
+	resultToJSON(w, result, r.URL.Path)
+}
+
+// returnNextUnread returns the gallery of the series to read next: the furthest started one if it is not finished,
+// otherwise the one after it. Not found if the last one has been read.
+func returnNextUnread(w http.ResponseWriter, r *http.Request) {
+	access, userUUID := hasAccess(w, r, db.NoRole)
+	if !access {
+		return
+	}
+
+	_, galleries, ok := seriesGalleriesFromRequest(w, r, userUUID)
+	if !ok {
+		return
+	}
+
+	next := db.NextUnread(galleries)
+	if next == nil {
+		errorHandler(w, http.StatusNotFound, "every gallery of the series has been read", r.URL.Path)
+		return
+	}
+
+	resultToJSON(w, convertMetadata(*next), r.URL.Path)
+}
+
+// updateSeries renames a series or changes its details. Only for admins.
+func updateSeries(w http.ResponseWriter, r *http.Request) {
+	access, userUUID := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	seriesID, ok := seriesIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	formData := &SeriesForm{}
+	if err := json.NewDecoder(r.Body).Decode(formData); err != nil {
+		errorHandler(w, http.StatusBadRequest, err.Error(), r.URL.Path)
+		return
+	}
+
+	edit := db.SeriesEdit{
+		Description:       formData.Description,
+		Cover:             formData.Cover,
+		Status:            formData.Status,
+		AlternativeTitles: formData.AlternativeTitles,
+	}
+	if formData.Name != nil {
+		name := strings.TrimSpace(*formData.Name)
+		if name == "" {
+			errorHandler(w, http.StatusBadRequest, "name is required", r.URL.Path)
+			return
+		}
+		edit.Name = &name
+	}
+	if formData.Tags != nil {
+		edit.Tags = convertMapToTags(formData.Tags)
+	}
+
+	err := db.UpdateSeries(seriesID, edit, db.Author{Source: db.SourceAPI, UserUUID: userUUID})
+	if errors.Is(err, db.ErrSeriesExists) {
+		errorHandler(w, http.StatusConflict, err.Error(), r.URL.Path)
+		return
+	}
+	if errors.Is(err, db.ErrNotInSeries) {
+		errorHandler(w, http.StatusBadRequest, "cover "+err.Error(), r.URL.Path)
+		return
+	}
+	if handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+
+	messageToJSON(w, "series updated", r.URL.Path)
+}
+
+// mergeSeries moves the galleries, titles and tags of the given series to a series. Only for admins.
+func mergeSeries(w http.ResponseWriter, r *http.Request) {
+	access, userUUID := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	seriesID, ok := seriesIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	formData := &SeriesMergeForm{}
+	if err := json.NewDecoder(r.Body).Decode(formData); err != nil {
+		errorHandler(w, http.StatusBadRequest, err.Error(), r.URL.Path)
+		return
+	}
+
+	err := db.MergeSeries(seriesID, formData.Series, db.Author{Source: db.SourceAPI, UserUUID: userUUID})
+	if handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+
+	messageToJSON(w, "series merged", r.URL.Path)
+}
+
+// setSeriesOrder sets the reading order of the galleries of a series. Only for admins.
+func setSeriesOrder(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	seriesID, ok := seriesIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	formData := &SeriesOrderForm{}
+	if err := json.NewDecoder(r.Body).Decode(formData); err != nil {
+		errorHandler(w, http.StatusBadRequest, err.Error(), r.URL.Path)
+		return
+	}
+
+	err := db.SetSeriesOrder(seriesID, formData.Galleries)
+	if errors.Is(err, db.ErrNotInSeries) {
+		errorHandler(w, http.StatusBadRequest, err.Error(), r.URL.Path)
+		return
+	}
+	if handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+
+	messageToJSON(w, "series order updated", r.URL.Path)
+}
+
+// deleteSeries removes a series. Its galleries are kept without a series. Only for admins.
+func deleteSeries(w http.ResponseWriter, r *http.Request) {
+	access, userUUID := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	seriesID, ok := seriesIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := db.DeleteSeries(seriesID, db.Author{Source: db.SourceAPI, UserUUID: userUUID})
+	if handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+
+	messageToJSON(w, "series removed", r.URL.Path)
+}
+
+// seriesGalleriesFromRequest returns the series of the request and its galleries the user may view in reading order.
+func seriesGalleriesFromRequest(
+	w http.ResponseWriter,
+	r *http.Request,
+	userUUID *string,
+) (db.SeriesInfo, []db.CombinedMetadata, bool) {
+	seriesID, ok := seriesIDFromRequest(w, r)
+	if !ok {
+		return db.SeriesInfo{}, nil, false
+	}
+
+	series, err := db.GetSeriesByID(seriesID, userUUID)
+	if handleResult(w, series, err, false, r.URL.Path) {
+		return db.SeriesInfo{}, nil, false
+	}
+
+	galleries, _, err := db.GetGalleries(db.Filters{Series: series.Name, SortBy: db.Reading}, true, userUUID)
+	if handleResult(w, galleries, err, true, r.URL.Path) {
+		return db.SeriesInfo{}, nil, false
+	}
+
+	return series, galleries, true
+}
+
+func convertSeries(series db.SeriesInfo) SeriesResult {
+	return SeriesResult{
+		ID:                series.ID,
+		Name:              series.Name,
+		Description:       series.Description,
+		Cover:             series.Cover,
+		Status:            series.Status,
+		AlternativeTitles: series.Titles(),
+		Tags:              convertTagsToMap(series.Tags),
+		GalleryCount:      series.GalleryCount,
+		CreatedAt:         series.CreatedAt,
+		UpdatedAt:         series.UpdatedAt,
+	}
+}
+
+func seriesIDFromRequest(w http.ResponseWriter, r *http.Request) (int32, bool) {
+	seriesID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		errorHandler(w, http.StatusBadRequest, "invalid series id", r.URL.Path)
+		return 0, false
+	}
+
+	return int32(seriesID), true
+}
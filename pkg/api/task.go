@@ -1,12 +1,14 @@
 package api
 
 import (
-	"fmt"
+	"context"
 	"github.com/Mangatsu/server/pkg/cache"
 	"github.com/Mangatsu/server/pkg/db"
 	"github.com/Mangatsu/server/pkg/library"
 	"github.com/Mangatsu/server/pkg/metadata"
+	"github.com/Mangatsu/server/pkg/utils"
 	"net/http"
+	"strings"
 )
 
 func scanLibraries(w http.ResponseWriter, r *http.Request) {
@@ -16,10 +18,9 @@ func scanLibraries(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// fullScan := r.URL.Query().Get("full")
-	go library.ScanArchives()
+	utils.RunJob(library.ScanArchives)
 
-	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
-	fmt.Fprintf(w, `{ "Message": "started scanning for new archives." }`)
+	messageToJSON(w, "started scanning for new archives.", r.URL.Path)
 }
 
 func returnProcessingStatus(w http.ResponseWriter, r *http.Request) {
@@ -28,8 +29,19 @@ func returnProcessingStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	status := cache.ProcessingStatusCache
-	resultToJSON(w, status, r.URL.Path)
+	resultToJSON(w, cache.ProcessingStatusCache.Snapshot(), r.URL.Path)
+}
+
+// resetProcessingStatus clears the results and the run history. Running tasks are not affected.
+func resetProcessingStatus(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	cache.ProcessingStatusCache.Reset()
+
+	messageToJSON(w, "processing status cleared", r.URL.Path)
 }
 
 func generateThumbnails(w http.ResponseWriter, r *http.Request) {
@@ -40,10 +52,11 @@ func generateThumbnails(w http.ResponseWriter, r *http.Request) {
 
 	pages := r.URL.Query().Get("pages")
 	force := r.URL.Query().Get("force")
-	go library.GenerateThumbnails(pages == "true", force == "true")
+	utils.RunJob(func(ctx context.Context) {
+		library.GenerateThumbnails(ctx, pages == "true", force == "true")
+	})
 
-	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
-	fmt.Fprintf(w, `{ "Message": "started generateting thumbnails. Prioritizing covers." }`)
+	messageToJSON(w, "started generateting thumbnails. Prioritizing covers.", r.URL.Path)
 }
 
 func findMetadata(w http.ResponseWriter, r *http.Request) {
@@ -57,23 +70,50 @@ func findMetadata(w http.ResponseWriter, r *http.Request) {
 	ehdl := r.URL.Query().Get("ehdl")
 	hath := r.URL.Query().Get("hath")
 	fuzzy := r.URL.Query().Get("fuzzy")
+	providers := r.URL.Query().Get("providers") // comma-separated names such as anilist,json
 
 	metaTypes := make(map[metadata.MetaType]bool)
 	metaTypes[metadata.XMeta] = x == "true"
 	metaTypes[metadata.EHDLMeta] = ehdl == "true"
 	metaTypes[metadata.HathMeta] = hath == "true"
 	metaTypes[metadata.FuzzyMatch] = fuzzy == "true"
-	go metadata.ParseMetadata(metaTypes)
+	parseFiles := metaTypes[metadata.XMeta] || metaTypes[metadata.EHDLMeta] || metaTypes[metadata.HathMeta]
 
-	if title == "true" {
-		go metadata.ParseTitles(true, false)
+	// Every input is validated before starting any of the jobs.
+	var providerNames []string
+	if providers != "" {
+		for _, name := range strings.Split(providers, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if _, ok := metadata.GetProvider(name); !ok {
+				errorHandler(w, http.StatusBadRequest, "metadata provider not enabled: "+name, r.URL.Path)
+				return
+			}
+			providerNames = append(providerNames, name)
+		}
 	}
 
-	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
-	if metaTypes[metadata.XMeta] || metaTypes[metadata.EHDLMeta] || metaTypes[metadata.HathMeta] || title == "true" {
-		fmt.Fprintf(w, `{ "Message": "started parsing given sources" }`)
+	if !parseFiles && title != "true" && len(providerNames) == 0 {
+		errorHandler(w, http.StatusBadRequest, "no sources specified", r.URL.Path)
 		return
 	}
 
-	errorHandler(w, http.StatusBadRequest, "no sources specified", r.URL.Path)
+	if parseFiles {
+		utils.RunJob(func(ctx context.Context) {
+			metadata.ParseMetadata(ctx, metaTypes)
+		})
+	}
+
+	if title == "true" {
+		utils.RunJob(func(ctx context.Context) {
+			metadata.ParseTitles(ctx, true, false)
+		})
+	}
+
+	if len(providerNames) > 0 {
+		utils.RunJob(func(ctx context.Context) {
+			metadata.ParseProviders(ctx, providerNames)
+		})
+	}
+
+	messageToJSON(w, "started parsing given sources", r.URL.Path)
 }
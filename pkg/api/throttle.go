@@ -0,0 +1,252 @@
+package api
+
+import (
+	"crypto/subtle"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Mangatsu/server/internal/config"
+	"github.com/Mangatsu/server/pkg/db"
+	"github.com/Mangatsu/server/pkg/log"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// throttleForget is how long after the last failure the failures of a key are forgotten.
+const throttleForget = time.Hour
+
+// loginThrottle slows down guessing of passwords, passphrases and codes. Each IP and username gets a few free
+// attempts, after which the wait between attempts doubles with every failure.
+type loginThrottle struct {
+	mu        sync.Mutex
+	entries   map[string]*throttleEntry
+	lastPrune time.Time
+}
+
+type throttleEntry struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+var throttle = &loginThrottle{entries: map[string]*throttleEntry{}}
+
+// retryAfter returns how long the caller has to wait before the next attempt, the longest block of the keys.
+// 0 if none of the keys is blocked.
+func (t *loginThrottle) retryAfter(now time.Time, keys ...string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var wait time.Duration
+	for _, key := range keys {
+		if entry, ok := t.entries[key]; ok && entry.blockedUntil.After(now) {
+			wait = max(wait, entry.blockedUntil.Sub(now))
+		}
+	}
+
+	return wait
+}
+
+// fail counts a failed attempt for the keys.
+func (t *loginThrottle) fail(now time.Time, keys ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune(now)
+
+	for _, key := range keys {
+		entry, ok := t.entries[key]
+		if !ok || now.Sub(entry.lastFailure) > throttleForget {
+			entry = &throttleEntry{}
+			t.entries[key] = entry
+		}
+
+		entry.failures++
+		entry.lastFailure = now
+
+		excess := entry.failures - config.Options.Login.FreeAttempts
+		if excess > 0 {
+			backoff := config.Options.Login.MaxBackoff
+			if excess <= 30 && time.Second<<(excess-1) < backoff {
+				backoff = time.Second << (excess - 1)
+			}
+			entry.blockedUntil = now.Add(backoff)
+		}
+	}
+}
+
+// reset forgets the failures of the keys after a successful attempt.
+func (t *loginThrottle) reset(keys ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, key := range keys {
+		delete(t.entries, key)
+	}
+}
+
+// prune removes the forgotten entries at most once a minute. Must be called with the lock held.
+func (t *loginThrottle) prune(now time.Time) {
+	if now.Sub(t.lastPrune) < time.Minute {
+		return
+	}
+	t.lastPrune = now
+
+	for key, entry := range t.entries {
+		if now.Sub(entry.lastFailure) > throttleForget && now.After(entry.blockedUntil) {
+			delete(t.entries, key)
+		}
+	}
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func usernameKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func twoFactorKey(userUUID string) string {
+	return "2fa:" + userUUID
+}
+
+// clientIP returns the IP of the client. X-Forwarded-For and X-Real-IP are only trusted with MTSU_TRUST_PROXY,
+// as clients can set them freely.
+func clientIP(r *http.Request) string {
+	if config.Options.TrustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			if ip := strings.TrimSpace(first); ip != "" {
+				return ip
+			}
+		}
+		if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+			return realIP
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// loginFailed counts a failed attempt for the throttle keys and towards the lockout of the user, if known,
+// and records it for admins.
+func loginFailed(r *http.Request, keys []string, username *string, userUUID *string, reason string) {
+	ip := clientIP(r)
+	throttle.fail(time.Now(), keys...)
+
+	if userUUID != nil {
+		lockedUntil, err := db.RecordLoginFailure(*userUUID, config.Options.Login.LockoutThreshold, config.Options.Login.LockoutDuration)
+		if err != nil {
+			log.Z.Error("failed to count failed login", zap.String("err", err.Error()), zap.String("user", *userUUID))
+		} else if lockedUntil != nil {
+			log.Z.Warn("account locked after failed logins",
+				zap.String("user", *userUUID),
+				zap.String("ip", ip),
+				zap.Time("lockedUntil", *lockedUntil))
+		}
+	}
+
+	db.NewLoginAttempt(username, userUUID, ip, false, reason)
+}
+
+// checkPassphrase compares the passphrase of the restricted mode. Failed attempts are throttled by IP.
+// Responds with 401 or 429 if it does not match.
+func checkPassphrase(w http.ResponseWriter, r *http.Request, passphrase string) bool {
+	keys := []string{ipKey(clientIP(r))}
+	if wait := throttle.retryAfter(time.Now(), keys...); wait > 0 {
+		tooManyRequests(w, r, wait)
+		return false
+	}
+
+	if !passphraseMatches(passphrase) {
+		loginFailed(r, keys, nil, nil, "invalid passphrase")
+		errorHandler(w, http.StatusUnauthorized, "", r.URL.Path)
+		return false
+	}
+
+	return true
+}
+
+func passphraseMatches(passphrase string) bool {
+	expected := config.Credentials.Passphrase
+	return expected != "" && subtle.ConstantTimeCompare([]byte(passphrase), []byte(expected)) == 1
+}
+
+// isSessionToken returns true if the token looks like a JWT or a personal access token. An expired session is
+// not counted as a guess of the passphrase.
+func isSessionToken(token string) bool {
+	return strings.HasPrefix(token, db.APITokenPrefix) || strings.Count(token, ".") == 2
+}
+
+// tooManyRequests responds with 429 and tells the client when to try again.
+func tooManyRequests(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	errorHandler(w, http.StatusTooManyRequests, "", r.URL.Path)
+}
+
+// returnLoginAttempts returns the latest login attempts. Only for admins.
+func returnLoginAttempts(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	query := r.URL.Query()
+	limit, err := strconv.ParseInt(query.Get("limit"), 10, 64)
+	if err != nil || limit < 1 || limit > 1000 {
+		limit = 100
+	}
+	offset, err := strconv.ParseInt(query.Get("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	attempts, err := db.GetLoginAttempts(db.LoginAttemptFilters{
+		Username:   query.Get("username"),
+		UserUUID:   query.Get("user"),
+		IP:         query.Get("ip"),
+		FailedOnly: query.Get("failed") == "true",
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if handleResult(w, attempts, err, true, r.URL.Path) {
+		return
+	}
+
+	resultToJSON(w, struct {
+		Data  interface{}
+		Count int
+	}{
+		Data:  attempts,
+		Count: len(attempts),
+	}, r.URL.Path)
+}
+
+// unlockUser removes the lockout from the account of the user. Only for admins.
+func unlockUser(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	username, err := db.UnlockUser(mux.Vars(r)["uuid"])
+	if handleResult(w, username, err, false, r.URL.Path) {
+		return
+	}
+
+	throttle.reset(usernameKey(username))
+	log.Z.Info("user unlocked", zap.String("username", username))
+
+	messageToJSON(w, "user unlocked", r.URL.Path)
+}
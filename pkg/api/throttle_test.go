@@ -0,0 +1,95 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Mangatsu/server/internal/config"
+)
+
+func TestLoginThrottle(t *testing.T) {
+	config.Options = &config.OptionsModel{
+		Login: config.LoginOptions{FreeAttempts: 2, MaxBackoff: 5 * time.Second},
+	}
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		failures int           // failed attempts at start
+		after    time.Duration // time of the check after start
+		reset    bool          // the key is reset before checking
+		wait     time.Duration
+	}{
+		{"first free attempt", 1, 0, false, 0},
+		{"last free attempt", 2, 0, false, 0},
+		{"first blocked attempt", 3, 0, false, time.Second},
+		{"doubled", 4, 0, false, 2 * time.Second},
+		{"doubled again", 5, 0, false, 4 * time.Second},
+		{"capped", 6, 0, false, 5 * time.Second},
+		{"capped after overflowing the shift", 40, 0, false, 5 * time.Second},
+		{"reset", 6, 0, true, 0},
+		{"after the wait", 4, 2 * time.Second, false, 0},
+	}
+
+	for _, test := range tests {
+		throttle := &loginThrottle{entries: map[string]*throttleEntry{}}
+		for range test.failures {
+			throttle.fail(start, "a")
+		}
+		if test.reset {
+			throttle.reset("a")
+		}
+
+		now := start.Add(test.after)
+		if wait := throttle.retryAfter(now, "a"); wait != test.wait {
+			t.Errorf("%s: expected to wait %s, got %s", test.name, test.wait, wait)
+		}
+		// A key without failures doesn't lift the block of another.
+		if wait := throttle.retryAfter(now, "b", "a"); wait != test.wait {
+			t.Errorf("%s: expected to wait %s with another key, got %s", test.name, test.wait, wait)
+		}
+	}
+}
+
+func TestLoginThrottleForget(t *testing.T) {
+	config.Options = &config.OptionsModel{
+		Login: config.LoginOptions{FreeAttempts: 2, MaxBackoff: 5 * time.Second},
+	}
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttle := &loginThrottle{entries: map[string]*throttleEntry{}}
+	for range 5 {
+		throttle.fail(start, "a")
+	}
+
+	// Failures within the window keep counting.
+	throttle.fail(start.Add(throttleForget), "a")
+	if wait := throttle.retryAfter(start.Add(throttleForget), "a"); wait != 5*time.Second {
+		t.Errorf("expected the failures to be remembered within %s, got a wait of %s", throttleForget, wait)
+	}
+
+	// A failure after the window starts over.
+	later := start.Add(2*throttleForget + time.Second)
+	throttle.fail(later, "a")
+	if wait := throttle.retryAfter(later, "a"); wait != 0 {
+		t.Errorf("expected the failures to be forgotten after %s, got a wait of %s", throttleForget, wait)
+	}
+	if failures := throttle.entries["a"].failures; failures != 1 {
+		t.Errorf("expected 1 failure after forgetting, got %d", failures)
+	}
+
+	// Forgotten entries are pruned on the next failure, but at most once a minute.
+	pruned := later.Add(throttleForget - 30*time.Second)
+	throttle.fail(pruned, "b")
+	throttle.fail(pruned.Add(40*time.Second), "b")
+	if _, ok := throttle.entries["a"]; !ok {
+		t.Error("expected the entry not to be pruned within a minute of the previous prune")
+	}
+	throttle.fail(pruned.Add(time.Minute+time.Second), "b")
+	if _, ok := throttle.entries["a"]; ok {
+		t.Error("expected the forgotten entry to be pruned")
+	}
+	if _, ok := throttle.entries["b"]; !ok {
+		t.Error("expected the recent entry not to be pruned")
+	}
+}
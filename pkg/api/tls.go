@@ -0,0 +1,46 @@
+package api
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"github.com/Mangatsu/server/internal/config"
+	"github.com/Mangatsu/server/pkg/certs"
+)
+
+// configureTLS makes the server serve HTTPS with the configured certificate, which is reloaded when the files
+// change. HTTP/2 is enabled by the server for TLS connections.
+func configureTLS(srv *http.Server) error {
+	reloader, err := certs.NewReloader(config.Options.TLS.CertFile, config.Options.TLS.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	srv.TLSConfig = &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	return nil
+}
+
+// redirectHandler redirects plain HTTP requests to the same URL on HTTPS.
+func redirectHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+		if host == "" {
+			errorHandler(w, http.StatusBadRequest, "host missing", r.URL.Path)
+			return
+		}
+
+		if config.Options.Port != "443" {
+			host = net.JoinHostPort(host, config.Options.Port)
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/db"
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	"github.com/Mangatsu/server/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+type APITokenForm struct {
+	Name      string   `schema:"required,min=1"`
+	Scopes    []string `schema:"required,min=1,enum=read|progress|admin"`
+	ExpiresIn *int64   // Seconds. Never expires if not set.
+}
+
+type scopeContextKey struct{}
+
+// noScope marks routes that cannot be accessed with personal access tokens, such as managing the tokens.
+const noScope db.Scope = ""
+
+// withScope sets the scope a personal access token needs to access the route.
+func withScope(scope db.Scope, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next(w, r.WithContext(context.WithValue(r.Context(), scopeContextKey{}, scope)))
+	}
+}
+
+// requiredScope returns the scope needed to access the route with a personal access token. Unless set with
+// withScope, admin routes need the admin scope and other GET requests the read scope.
+func requiredScope(r *http.Request, role db.Role) db.Scope {
+	if scope, ok := r.Context().Value(scopeContextKey{}).(db.Scope); ok {
+		return scope
+	}
+
+	if role >= db.Admin {
+		return db.ScopeAdmin
+	}
+
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return db.ScopeRead
+	}
+
+	return noScope
+}
+
+// verifyToken verifies a personal access token or a JWT.
+func verifyToken(r *http.Request, token string, role db.Role) (bool, *string) {
+	if strings.HasPrefix(token, db.APITokenPrefix) {
+		return verifyAPIToken(r, token, role)
+	}
+
+	return verifyJWT(token, role)
+}
+
+// verifyAPIToken verifies a personal access token against the role of its user and the scope of the route.
+func verifyAPIToken(r *http.Request, token string, role db.Role) (bool, *string) {
+	verified, err := db.VerifyAPIToken(token)
+	if err != nil {
+		return false, nil
+	}
+
+	if !tokenGrants(verified, role, requiredScope(r, role)) || adminTwoFactorMissing(role, verified.UserUUID) {
+		return false, nil
+	}
+
+	return true, &verified.UserUUID
+}
+
+// tokenGrants returns true if the token may access a route of the role that needs the scope.
+// Routes without a scope are never accessible with tokens.
+func tokenGrants(token *db.VerifiedAPIToken, role db.Role, scope db.Scope) bool {
+	return scope != noScope && token.Role >= role && slices.Contains(token.Scopes, scope)
+}
+
+// returnAPITokens returns the personal access tokens of the user.
+func returnAPITokens(w http.ResponseWriter, r *http.Request) {
+	access, userUUID := hasAccess(w, r, db.Viewer)
+	if !access {
+		return
+	}
+	if userUUID == nil {
+		errorHandler(w, http.StatusBadRequest, "", r.URL.Path)
+		return
+	}
+
+	tokens, err := db.GetAPITokens(*userUUID)
+	if handleResult(w, tokens, err, true, r.URL.Path) {
+		return
+	}
+
+	resultToJSON(w, struct {
+		Data  []model.APIToken
+		Count int
+	}{
+		Data:  tokens,
+		Count: len(tokens),
+	}, r.URL.Path)
+}
+
+// newAPIToken creates a personal access token for the user. The token is only shown in this response.
+func newAPIToken(w http.ResponseWriter, r *http.Request) {
+	access, userUUID := hasAccess(w, r, db.Viewer)
+	if !access {
+		return
+	}
+	if userUUID == nil {
+		errorHandler(w, http.StatusBadRequest, "", r.URL.Path)
+		return
+	}
+
+	formData := &APITokenForm{}
+	if err := json.NewDecoder(r.Body).Decode(formData); err != nil {
+		errorHandler(w, http.StatusBadRequest, err.Error(), r.URL.Path)
+		return
+	}
+
+	name := strings.TrimSpace(formData.Name)
+	if name == "" || !utils.IsValidSessionName(&name) {
+		errorHandler(w, http.StatusBadRequest, "name not valid", r.URL.Path)
+		return
+	}
+
+	scopes, err := db.ParseScopes(formData.Scopes)
+	if err != nil {
+		errorHandler(w, http.StatusBadRequest, err.Error(), r.URL.Path)
+		return
+	}
+
+	if slices.Contains(scopes, db.ScopeAdmin) {
+		if adminAccess, _ := verifyJWT(readJWT(r), db.Admin); !adminAccess {
+			errorHandler(w, http.StatusForbidden, "", r.URL.Path)
+			return
+		}
+	}
+
+	var expiresAt *time.Time
+	if formData.ExpiresIn != nil {
+		expiresIn := utils.Clamp(*formData.ExpiresIn, 60, 60*60*24*365*10)
+		expiration := time.Now().Add(time.Duration(expiresIn) * time.Second)
+		expiresAt = &expiration
+	}
+
+	plainToken, token, err := db.NewAPIToken(*userUUID, name, scopes, expiresAt)
+	if handleResult(w, token, err, false, r.URL.Path) {
+		return
+	}
+
+	resultToJSON(w, struct {
+		Token string
+		Data  model.APIToken
+	}{
+		Token: plainToken,
+		Data:  token,
+	}, r.URL.Path)
+}
+
+// deleteAPIToken revokes a personal access token of the user.
+func deleteAPIToken(w http.ResponseWriter, r *http.Request) {
+	access, userUUID := hasAccess(w, r, db.Viewer)
+	if !access {
+		return
+	}
+	if userUUID == nil {
+		errorHandler(w, http.StatusBadRequest, "", r.URL.Path)
+		return
+	}
+
+	err := db.DeleteAPIToken(mux.Vars(r)["id"], *userUUID)
+	if handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+
+	messageToJSON(w, "token revoked", r.URL.Path)
+}
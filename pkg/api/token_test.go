@@ -0,0 +1,87 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Mangatsu/server/pkg/db"
+)
+
+func TestRequiredScope(t *testing.T) {
+	tests := []struct {
+		method   string
+		role     db.Role
+		scoped   bool // the route is wrapped with withScope
+		scope    db.Scope
+		expected db.Scope
+	}{
+		// Without withScope, the scope follows the role and the method.
+		{http.MethodGet, db.NoRole, false, "", db.ScopeRead},
+		{http.MethodHead, db.Viewer, false, "", db.ScopeRead},
+		{http.MethodGet, db.Admin, false, "", db.ScopeAdmin},
+		{http.MethodDelete, db.SuperAdmin, false, "", db.ScopeAdmin},
+		{http.MethodPost, db.Viewer, false, "", noScope},
+		{http.MethodPut, db.Member, false, "", noScope},
+		{http.MethodPatch, db.NoRole, false, "", noScope},
+		{http.MethodDelete, db.Viewer, false, "", noScope},
+
+		// withScope overrides the role and the method.
+		{http.MethodPatch, db.Viewer, true, db.ScopeProgress, db.ScopeProgress},
+		{http.MethodPost, db.Admin, true, db.ScopeRead, db.ScopeRead},
+		{http.MethodGet, db.Viewer, true, noScope, noScope},
+		{http.MethodGet, db.Admin, true, noScope, noScope},
+		{http.MethodHead, db.SuperAdmin, true, noScope, noScope},
+	}
+
+	for _, test := range tests {
+		var got db.Scope
+		handler := func(_ http.ResponseWriter, r *http.Request) {
+			got = requiredScope(r, test.role)
+		}
+		if test.scoped {
+			handler = withScope(test.scope, handler)
+		}
+
+		handler(httptest.NewRecorder(), httptest.NewRequest(test.method, "/api/v1/users/me/tokens", nil))
+		if got != test.expected {
+			t.Errorf("%s as role %d with scope %q: expected %q, got %q",
+				test.method, test.role, test.scope, test.expected, got)
+		}
+	}
+}
+
+// TestTokenGrants checks that tokens only reach routes of their role and scopes, and never the routes without a
+// scope, such as managing the tokens themselves.
+func TestTokenGrants(t *testing.T) {
+	allScopes := []db.Scope{db.ScopeRead, db.ScopeProgress, db.ScopeAdmin, noScope}
+
+	tests := []struct {
+		tokenRole db.Role
+		scopes    []db.Scope
+		role      db.Role
+		scope     db.Scope
+		expected  bool
+	}{
+		{db.Viewer, []db.Scope{db.ScopeRead}, db.Viewer, db.ScopeRead, true},
+		{db.Viewer, []db.Scope{db.ScopeRead}, db.NoRole, db.ScopeRead, true},
+		{db.Viewer, []db.Scope{db.ScopeRead}, db.Viewer, db.ScopeProgress, false},
+		{db.Viewer, []db.Scope{db.ScopeAdmin}, db.Admin, db.ScopeAdmin, false},
+		{db.Admin, []db.Scope{db.ScopeRead}, db.Admin, db.ScopeAdmin, false},
+		{db.Admin, []db.Scope{db.ScopeAdmin}, db.Admin, db.ScopeAdmin, true},
+		{db.SuperAdmin, allScopes, db.Viewer, noScope, false},
+		{db.SuperAdmin, allScopes, db.SuperAdmin, noScope, false},
+	}
+
+	for _, test := range tests {
+		token := &db.VerifiedAPIToken{Role: test.tokenRole, Scopes: test.scopes}
+		if got := tokenGrants(token, test.role, test.scope); got != test.expected {
+			t.Errorf("token of role %d with %v on a route of role %d and scope %q: expected %v, got %v",
+				test.tokenRole, test.scopes, test.role, test.scope, test.expected, got)
+		}
+	}
+
+	if _, err := db.ParseScopes([]string{string(noScope)}); err == nil {
+		t.Error("expected tokens not to be given an empty scope")
+	}
+}
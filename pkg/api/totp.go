@@ -0,0 +1,269 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Mangatsu/server/internal/config"
+	"github.com/Mangatsu/server/pkg/db"
+	"github.com/Mangatsu/server/pkg/log"
+	"github.com/Mangatsu/server/pkg/totp"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// challengeTTL is how long the user has to enter the code after the password.
+const challengeTTL = 5 * time.Minute
+
+const challengePurpose = "2fa"
+
+type TwoFactorForm struct {
+	Code string `schema:"required"` // TOTP or recovery code
+}
+
+type TwoFactorLoginForm struct {
+	ChallengeToken string `schema:"required"`
+	Code           string `schema:"required"`
+}
+
+// ChallengeClaims are the claims of the token given after the password step of a login with two-factor
+// authentication. It carries the session options of the login to the second step.
+type ChallengeClaims struct {
+	jwt.RegisteredClaims
+	Purpose     string
+	Role        *int32
+	ExpiresIn   *int64
+	SessionName *string
+}
+
+// loginTwoFactor finishes a login with a TOTP or recovery code.
+func loginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	formData := &TwoFactorLoginForm{}
+	if err := json.NewDecoder(r.Body).Decode(formData); err != nil {
+		errorHandler(w, http.StatusBadRequest, err.Error(), r.URL.Path)
+		return
+	}
+
+	claims, err := parseChallengeToken(formData.ChallengeToken)
+	if err != nil {
+		errorHandler(w, http.StatusUnauthorized, "", r.URL.Path)
+		return
+	}
+
+	if !verifySecondFactor(w, r, claims.Subject, formData.Code) {
+		return
+	}
+
+	issueSession(w, r, claims.Subject, claims.Role, claims.ExpiresIn, claims.SessionName)
+}
+
+// startTOTPEnrollment generates a TOTP secret for the user. The URI is shown as a QR code for authenticator apps.
+func startTOTPEnrollment(w http.ResponseWriter, r *http.Request) {
+	access, userUUID := hasAccess(w, r, db.Viewer)
+	if !access {
+		return
+	}
+	if userUUID == nil {
+		errorHandler(w, http.StatusBadRequest, "", r.URL.Path)
+		return
+	}
+
+	secret, username, err := db.StartTOTPEnrollment(*userUUID)
+	if errors.Is(err, db.ErrTOTPEnabled) {
+		errorHandler(w, http.StatusConflict, err.Error(), r.URL.Path)
+		return
+	}
+	if handleResult(w, secret, err, false, r.URL.Path) {
+		return
+	}
+
+	resultToJSON(w, struct {
+		Secret string
+		URI    string
+	}{
+		Secret: secret,
+		URI:    totp.URI("Mangatsu", username, secret),
+	}, r.URL.Path)
+}
+
+// confirmTOTPEnrollment enables two-factor authentication with the first code from the authenticator app.
+// The recovery codes are only shown in this response.
+func confirmTOTPEnrollment(w http.ResponseWriter, r *http.Request) {
+	access, userUUID := hasAccess(w, r, db.Viewer)
+	if !access {
+		return
+	}
+	if userUUID == nil {
+		errorHandler(w, http.StatusBadRequest, "", r.URL.Path)
+		return
+	}
+
+	formData := &TwoFactorForm{}
+	if err := json.NewDecoder(r.Body).Decode(formData); err != nil {
+		errorHandler(w, http.StatusBadRequest, err.Error(), r.URL.Path)
+		return
+	}
+
+	codes, err := db.ConfirmTOTPEnrollment(*userUUID, formData.Code)
+	switch {
+	case errors.Is(err, db.ErrTOTPEnabled):
+		errorHandler(w, http.StatusConflict, err.Error(), r.URL.Path)
+		return
+	case errors.Is(err, db.ErrTOTPNotEnrolled), errors.Is(err, db.ErrInvalidCode):
+		errorHandler(w, http.StatusBadRequest, err.Error(), r.URL.Path)
+		return
+	}
+	if handleResult(w, codes, err, true, r.URL.Path) {
+		return
+	}
+
+	resultToJSON(w, struct{ RecoveryCodes []string }{RecoveryCodes: codes}, r.URL.Path)
+}
+
+// regenerateRecoveryCodes replaces the recovery codes of the user. Requires a current code.
+func regenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	access, userUUID := hasAccess(w, r, db.Viewer)
+	if !access {
+		return
+	}
+	if userUUID == nil {
+		errorHandler(w, http.StatusBadRequest, "", r.URL.Path)
+		return
+	}
+
+	formData := &TwoFactorForm{}
+	if err := json.NewDecoder(r.Body).Decode(formData); err != nil {
+		errorHandler(w, http.StatusBadRequest, err.Error(), r.URL.Path)
+		return
+	}
+
+	if !verifySecondFactor(w, r, *userUUID, formData.Code) {
+		return
+	}
+
+	codes, err := db.RegenerateRecoveryCodes(*userUUID)
+	if handleResult(w, codes, err, true, r.URL.Path) {
+		return
+	}
+
+	resultToJSON(w, struct{ RecoveryCodes []string }{RecoveryCodes: codes}, r.URL.Path)
+}
+
+// disableTOTP disables two-factor authentication of the user. Requires a current code.
+func disableTOTP(w http.ResponseWriter, r *http.Request) {
+	access, userUUID := hasAccess(w, r, db.Viewer)
+	if !access {
+		return
+	}
+	if userUUID == nil {
+		errorHandler(w, http.StatusBadRequest, "", r.URL.Path)
+		return
+	}
+
+	formData := &TwoFactorForm{}
+	if err := json.NewDecoder(r.Body).Decode(formData); err != nil {
+		errorHandler(w, http.StatusBadRequest, err.Error(), r.URL.Path)
+		return
+	}
+
+	if !verifySecondFactor(w, r, *userUUID, formData.Code) {
+		return
+	}
+
+	if err := db.DisableTOTP(*userUUID); handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+
+	messageToJSON(w, "two-factor authentication disabled", r.URL.Path)
+}
+
+// resetTOTP disables two-factor authentication of any user, e.g. when they have lost their device and
+// recovery codes. Only for admins.
+func resetTOTP(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	if err := db.DisableTOTP(mux.Vars(r)["uuid"]); handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+
+	messageToJSON(w, "two-factor authentication disabled", r.URL.Path)
+}
+
+// verifySecondFactor checks the TOTP or recovery code of the user. Responds with 401 if it is invalid.
+// Failed attempts are throttled like passwords.
+func verifySecondFactor(w http.ResponseWriter, r *http.Request, userUUID string, code string) bool {
+	keys := []string{ipKey(clientIP(r)), twoFactorKey(userUUID)}
+	if wait := throttle.retryAfter(time.Now(), keys...); wait > 0 {
+		tooManyRequests(w, r, wait)
+		return false
+	}
+
+	valid, err := db.VerifySecondFactor(userUUID, code)
+	if err != nil && !errors.Is(err, db.ErrTOTPNotEnrolled) {
+		errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
+		return false
+	}
+	if !valid {
+		loginFailed(r, keys, nil, &userUUID, "invalid code")
+		errorHandler(w, http.StatusUnauthorized, "", r.URL.Path)
+		return false
+	}
+
+	throttle.reset(twoFactorKey(userUUID))
+	return true
+}
+
+// adminTwoFactorMissing returns true if the role requires two-factor authentication that the user has not enabled.
+// Users can still log in and enroll, but cannot access admin routes before that.
+func adminTwoFactorMissing(role db.Role, userUUID string) bool {
+	if !config.Options.RequireAdmin2FA || role < db.Admin || db.TOTPEnabled(userUUID) {
+		return false
+	}
+
+	log.Z.Debug("admin access denied without two-factor authentication", zap.String("user", userUUID))
+	return true
+}
+
+func newChallengeToken(userUUID string, role *int32, expiresIn *int64, sessionName *string) (string, error) {
+	now := time.Now()
+	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, ChallengeClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userUUID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(challengeTTL)),
+		},
+		Purpose:     challengePurpose,
+		Role:        role,
+		ExpiresIn:   expiresIn,
+		SessionName: sessionName,
+	})
+
+	return claims.SignedString([]byte(config.Credentials.JWTSecret))
+}
+
+func parseChallengeToken(tokenString string) (*ChallengeClaims, error) {
+	claims := &ChallengeClaims{}
+	_, err := jwt.ParseWithClaims(
+		tokenString, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			return []byte(config.Credentials.JWTSecret), nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Purpose != challengePurpose || claims.Subject == "" {
+		return nil, errors.New("not a challenge token")
+	}
+
+	return claims, nil
+}
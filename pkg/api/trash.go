@@ -0,0 +1,80 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Mangatsu/server/pkg/db"
+	"github.com/Mangatsu/server/pkg/library"
+	"github.com/gorilla/mux"
+)
+
+// deleteGallery moves a gallery to the trash. It's hidden from all listings until restored or purged.
+func deleteGallery(w http.ResponseWriter, r *http.Request) {
+	access, userUUID := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	err := library.TrashGallery(mux.Vars(r)["uuid"], db.Author{Source: db.SourceAPI, UserUUID: userUUID})
+	if handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+
+	messageToJSON(w, "gallery moved to trash", r.URL.Path)
+}
+
+// returnTrash returns the galleries in the trash.
+func returnTrash(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	galleries, err := db.GetTrash()
+	if handleResult(w, galleries, err, true, r.URL.Path) {
+		return
+	}
+
+	resultToJSON(w, struct {
+		Data  []db.LocatedGallery
+		Count int
+	}{
+		Data:  galleries,
+		Count: len(galleries),
+	}, r.URL.Path)
+}
+
+// restoreGallery restores a gallery from the trash.
+func restoreGallery(w http.ResponseWriter, r *http.Request) {
+	access, userUUID := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	err := library.RestoreGallery(mux.Vars(r)["uuid"], db.Author{Source: db.SourceAPI, UserUUID: userUUID})
+	if errors.Is(err, library.ErrArchiveExists) || errors.Is(err, library.ErrLibraryRemoved) {
+		errorHandler(w, http.StatusConflict, err.Error(), r.URL.Path)
+		return
+	}
+	if handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+
+	messageToJSON(w, "gallery restored", r.URL.Path)
+}
+
+// purgeGallery permanently removes a gallery in the trash.
+func purgeGallery(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	err := library.PurgeGallery(mux.Vars(r)["uuid"])
+	if handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+
+	messageToJSON(w, "gallery purged", r.URL.Path)
+}
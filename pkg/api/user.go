@@ -2,7 +2,7 @@ package api
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"github.com/Mangatsu/server/internal/config"
 	"github.com/Mangatsu/server/pkg/db"
 	"github.com/Mangatsu/server/pkg/log"
@@ -22,12 +22,21 @@ type Credentials struct {
 	Role        *string `json:"role"`
 	ExpiresIn   *int64  `json:"expires_in"`
 	SessionName *string `json:"session_name"`
+	Code        string  `json:"code"` // TOTP or recovery code if two-factor authentication is enabled
+	InviteCode  string  `json:"invite_code"`
+}
+
+type SessionForm struct {
+	SessionID string `schema:"required"`
 }
 
 type LoginResponse struct {
 	UUID      *string
 	Role      *int32
 	ExpiresIn *int64
+	// TwoFactorRequired is set when the login has to be finished with a code at /login/2fa.
+	TwoFactorRequired bool   `json:",omitempty"`
+	ChallengeToken    string `json:",omitempty"`
 }
 
 func register(w http.ResponseWriter, r *http.Request) {
@@ -38,7 +47,18 @@ func register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !config.Options.Registrations || credentials.Role != nil {
+	// Invites work even if registrations are disabled. The role is set by the invite.
+	if credentials.InviteCode != "" {
+		if credentials.Role != nil {
+			errorHandler(w, http.StatusBadRequest, "role cannot be set with an invite", r.URL.Path)
+			return
+		}
+		registerWithInvite(w, r, *credentials)
+		return
+	}
+
+	adminRegistration := false
+	if !config.Options.Reloadable().Registrations || credentials.Role != nil {
 		token := readJWT(r)
 		if token == "" {
 			errorHandler(w, http.StatusBadRequest, "", r.URL.Path)
@@ -48,6 +68,9 @@ func register(w http.ResponseWriter, r *http.Request) {
 			errorHandler(w, http.StatusUnauthorized, "", r.URL.Path)
 			return
 		}
+		adminRegistration = true
+	} else if token := readJWT(r); token != "" {
+		adminRegistration, _ = verifyJWT(token, db.Admin)
 	}
 
 	role := int64(10)
@@ -59,32 +82,78 @@ func register(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if !utils.IsValidUsername(credentials.Username) {
-		errorHandler(w, http.StatusBadRequest, "username not valid", r.URL.Path)
+	if !validRegistration(w, r, *credentials) {
 		return
 	}
 
-	if !utils.IsValidPassword(credentials.Password) {
-		errorHandler(w, http.StatusBadRequest, "password not valid", r.URL.Path)
+	// Self-registered users wait for an admin to approve them if MTSU_REQUIRE_APPROVAL is set.
+	pending := config.Options.RequireApproval && !adminRegistration
+	clampedRole := db.Role(utils.Clamp(role, 0, int64(db.Admin)))
+	if pending {
+		err = db.RegisterPending(credentials.Username, credentials.Password, clampedRole)
+	} else {
+		err = db.Register(credentials.Username, credentials.Password, clampedRole)
+	}
+	if err != nil {
+		registrationFailed(w, r, err)
 		return
 	}
 
-	if !utils.IsValidSessionName(credentials.SessionName) {
-		errorHandler(w, http.StatusBadRequest, "session name not valid", r.URL.Path)
+	if pending {
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		w.WriteHeader(http.StatusAccepted)
+		messageToJSON(w, "successfully registered, waiting for approval", r.URL.Path)
+		return
+	}
+
+	messageToJSON(w, "successfully registered", r.URL.Path)
+}
+
+// registerWithInvite registers a user with the role of the invite code.
+func registerWithInvite(w http.ResponseWriter, r *http.Request, credentials Credentials) {
+	if !validRegistration(w, r, credentials) {
 		return
 	}
 
-	err = db.Register(credentials.Username, credentials.Password, db.Role(utils.Clamp(role, 0, int64(db.Admin))))
+	_, err := db.RegisterWithInvite(credentials.Username, credentials.Password, credentials.InviteCode)
+	if errors.Is(err, db.ErrInvalidInvite) {
+		errorHandler(w, http.StatusBadRequest, err.Error(), r.URL.Path)
+		return
+	}
 	if err != nil {
-		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
-			errorHandler(w, http.StatusConflict, "username already in use", r.URL.Path)
-		} else {
-			errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
-		}
+		registrationFailed(w, r, err)
 		return
 	}
 
-	fmt.Fprintf(w, `{ "Message": "successfully registered" }`)
+	messageToJSON(w, "successfully registered", r.URL.Path)
+}
+
+// validRegistration validates the username, password and session name. Responds with 400 if any is invalid.
+func validRegistration(w http.ResponseWriter, r *http.Request, credentials Credentials) bool {
+	if !utils.IsValidUsername(credentials.Username) {
+		errorHandler(w, http.StatusBadRequest, "username not valid", r.URL.Path)
+		return false
+	}
+
+	if !utils.IsValidPassword(credentials.Password) {
+		errorHandler(w, http.StatusBadRequest, "password not valid", r.URL.Path)
+		return false
+	}
+
+	if !utils.IsValidSessionName(credentials.SessionName) {
+		errorHandler(w, http.StatusBadRequest, "session name not valid", r.URL.Path)
+		return false
+	}
+
+	return true
+}
+
+func registrationFailed(w http.ResponseWriter, r *http.Request, err error) {
+	if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		errorWithCode(w, http.StatusConflict, CodeUsernameTaken, "username already in use", r.URL.Path)
+	} else {
+		errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
+	}
 }
 
 func login(w http.ResponseWriter, r *http.Request) {
@@ -98,53 +167,40 @@ func login(w http.ResponseWriter, r *http.Request) {
 	expiresIn := utils.ClampCookieAge(credentials.ExpiresIn)
 
 	if credentials.Username != "" && credentials.Password != "" {
-		access, userUUID, role := loginHelper(w, *credentials, db.Role(0))
+		access, userUUID, role := loginHelper(w, r, *credentials, db.Role(0))
 		if !access {
 			return
 		}
 
-		token, err := newJWT(*userUUID, credentials.ExpiresIn, credentials.SessionName, role)
-		if err != nil {
-			errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
-			return
-		}
+		// Second step with a TOTP or recovery code. Can also be given right away with the password.
+		if db.TOTPEnabled(*userUUID) {
+			if credentials.Code == "" {
+				challengeToken, err := newChallengeToken(*userUUID, role, credentials.ExpiresIn, credentials.SessionName)
+				if err != nil {
+					errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
+					return
+				}
+
+				resultToJSON(w, LoginResponse{
+					TwoFactorRequired: true,
+					ChallengeToken:    challengeToken,
+				}, r.URL.Path)
+				return
+			}
 
-		jwtCookie := http.Cookie{
-			Name:     "mtsu.jwt",
-			Value:    "Bearer " + token,
-			Domain:   config.Options.Domain,
-			Path:     "/",
-			MaxAge:   int(expiresIn),
-			Secure:   config.Options.Secure,
-			HttpOnly: true,
-			SameSite: config.Options.SameSiteMode,
+			if !verifySecondFactor(w, r, *userUUID, credentials.Code) {
+				return
+			}
 		}
 
-		http.SetCookie(w, &jwtCookie)
-
-		resultToJSON(w, LoginResponse{
-			UUID:      userUUID,
-			Role:      role,
-			ExpiresIn: credentials.ExpiresIn,
-		}, r.URL.Path)
+		issueSession(w, r, *userUUID, role, credentials.ExpiresIn, credentials.SessionName)
 		return
 	} else if credentials.Passphrase != "" {
-		if config.Credentials.Passphrase != credentials.Passphrase {
-			errorHandler(w, http.StatusUnauthorized, "", r.URL.Path)
+		if !checkPassphrase(w, r, credentials.Passphrase) {
 			return
 		}
 
-		passphraseCookie := http.Cookie{
-			Name:     "mtsu.jwt",
-			Value:    "Passphrase " + credentials.Passphrase,
-			Domain:   config.Options.Domain,
-			Path:     "/",
-			MaxAge:   int(expiresIn),
-			Secure:   config.Options.Secure,
-			HttpOnly: true,
-			SameSite: config.Options.SameSiteMode,
-		}
-		http.SetCookie(w, &passphraseCookie)
+		http.SetCookie(w, newJWTCookie("Passphrase "+credentials.Passphrase, expiresIn))
 
 		resultToJSON(w, LoginResponse{
 			UUID:      nil,
@@ -157,6 +213,40 @@ func login(w http.ResponseWriter, r *http.Request) {
 	errorHandler(w, http.StatusBadRequest, "", r.URL.Path)
 }
 
+// issueSession creates a session for the user, and sets the cookie and login response.
+func issueSession(w http.ResponseWriter, r *http.Request, userUUID string, role *int32, expiresIn *int64, sessionName *string) {
+	cookieAge := utils.ClampCookieAge(expiresIn)
+
+	token, err := newJWT(userUUID, expiresIn, sessionName, role)
+	if err != nil {
+		errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
+		return
+	}
+
+	http.SetCookie(w, newJWTCookie("Bearer "+token, cookieAge))
+	db.NewLoginAttempt(nil, &userUUID, clientIP(r), true, "login")
+
+	resultToJSON(w, LoginResponse{
+		UUID:      &userUUID,
+		Role:      role,
+		ExpiresIn: expiresIn,
+	}, r.URL.Path)
+}
+
+// newJWTCookie returns the cookie used by web browsers to send the JWT or passphrase.
+func newJWTCookie(value string, maxAge int64) *http.Cookie {
+	return &http.Cookie{
+		Name:     "mtsu.jwt",
+		Value:    value,
+		Domain:   config.Options.Domain,
+		Path:     "/",
+		MaxAge:   int(maxAge),
+		Secure:   config.Options.Secure,
+		HttpOnly: true,
+		SameSite: config.Options.SameSiteMode,
+	}
+}
+
 func logout(w http.ResponseWriter, r *http.Request) {
 	token := readJWT(r)
 	if token != "" {
@@ -169,22 +259,13 @@ func logout(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	cookie := http.Cookie{
-		Name:     "mtsu.jwt",
-		Value:    "",
-		Domain:   config.Options.Domain,
-		Path:     "/",
-		MaxAge:   0,
-		Secure:   config.Options.Secure,
-		HttpOnly: true,
-		SameSite: config.Options.SameSiteMode,
-	}
-	http.SetCookie(w, &cookie)
+	http.SetCookie(w, newJWTCookie("", 0))
 
-	fmt.Fprint(w, `{ "Message": "successfully logged out" }`)
+	messageToJSON(w, "successfully logged out", r.URL.Path)
 }
 
-// updateUser can be used to update role, password or username of users. Role can only be changed by admins.
+// updateUser can be used to update role, password or username of users.
+// Role and NSFW visibility can only be changed by admins.
 func updateUser(w http.ResponseWriter, r *http.Request) {
 	userForm := &db.UserForm{}
 	if err := json.NewDecoder(r.Body).Decode(userForm); err != nil {
@@ -199,7 +280,7 @@ func updateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if userForm.Role != nil {
+	if userForm.Role != nil || userForm.HideNsfw != nil {
 		access, _ := hasAccess(w, r, db.Admin)
 		if !access {
 			return
@@ -220,7 +301,7 @@ func updateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fmt.Fprint(w, `{ "Message": "successfully updated user" }`)
+	messageToJSON(w, "successfully updated user", r.URL.Path)
 }
 
 // returnUsers returns all users in the database. Only for admins. Never returns the hashed password.
@@ -304,7 +385,7 @@ func deleteSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	credentials := &struct{ SessionID string }{}
+	credentials := &SessionForm{}
 	if err := json.NewDecoder(r.Body).Decode(credentials); err != nil {
 		errorHandler(w, http.StatusBadRequest, "", r.URL.Path)
 		return
@@ -346,12 +427,16 @@ func setFavorite(w http.ResponseWriter, r *http.Request) {
 		errorHandler(w, http.StatusBadRequest, "uuid is required", r.URL.Path)
 		return
 	}
+	if !db.CanAccessGallery(params["uuid"], userUUID) {
+		errorHandler(w, http.StatusNotFound, "", r.URL.Path)
+		return
+	}
 
 	if err := db.SetFavoriteGroup(params["name"], params["uuid"], *userUUID); err != nil {
 		errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
 		return
 	}
-	fmt.Fprintf(w, `{ "Message": "favorite group updated" }`)
+	messageToJSON(w, "favorite group updated", r.URL.Path)
 }
 
 func updateProgress(w http.ResponseWriter, r *http.Request) {
@@ -366,10 +451,14 @@ func updateProgress(w http.ResponseWriter, r *http.Request) {
 		errorHandler(w, http.StatusBadRequest, err.Error(), r.URL.Path)
 		return
 	}
+	if !db.CanAccessGallery(params["uuid"], userUUID) {
+		errorHandler(w, http.StatusNotFound, "", r.URL.Path)
+		return
+	}
 
 	if err = db.UpdateProgress(int32(progress), params["uuid"], *userUUID); err != nil {
 		errorHandler(w, http.StatusInternalServerError, err.Error(), r.URL.Path)
 		return
 	}
-	fmt.Fprintf(w, `{ "Message": "progress updated" }`)
+	messageToJSON(w, "progress updated", r.URL.Path)
 }
@@ -0,0 +1,261 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Mangatsu/server/pkg/db"
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	"github.com/Mangatsu/server/pkg/webhook"
+	"github.com/gorilla/mux"
+)
+
+type WebhookForm struct {
+	Name    *string  `schema:"min=1"`
+	URL     *string  `schema:"min=1"`
+	Events  []string `schema:"enum=gallery_added|thumbnail_generated|metadata_parsed|error|task_started|task_finished"` // Empty for all events
+	Enabled *bool
+}
+
+// WebhookResult is a webhook without its secret. The secret is only returned when the webhook is created.
+type WebhookResult struct {
+	model.Webhook
+	Secret string `json:",omitempty"`
+}
+
+// returnWebhooks returns all webhooks. Only for admins.
+func returnWebhooks(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	webhooks, err := db.GetWebhooks()
+	if handleResult(w, webhooks, err, true, r.URL.Path) {
+		return
+	}
+
+	results := make([]WebhookResult, 0, len(webhooks))
+	for _, hook := range webhooks {
+		results = append(results, WebhookResult{Webhook: hook})
+	}
+
+	resultToJSON(w, struct {
+		Data  []WebhookResult
+		Count int
+	}{
+		Data:  results,
+		Count: len(results),
+	}, r.URL.Path)
+}
+
+// returnWebhook returns a webhook. Only for admins.
+func returnWebhook(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	webhookID, ok := webhookIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	hook, err := db.GetWebhook(webhookID)
+	if handleResult(w, hook, err, false, r.URL.Path) {
+		return
+	}
+
+	resultToJSON(w, WebhookResult{Webhook: hook}, r.URL.Path)
+}
+
+// newWebhook registers a webhook. Responds with the secret used to sign the payloads. Only for admins.
+func newWebhook(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	edit, ok := webhookEditFromRequest(w, r)
+	if !ok {
+		return
+	}
+	if edit.Name == nil || edit.URL == nil {
+		errorHandler(w, http.StatusBadRequest, "name and URL are required", r.URL.Path)
+		return
+	}
+
+	enabled := edit.Enabled == nil || *edit.Enabled
+	hook, err := db.NewWebhook(*edit.Name, *edit.URL, edit.Events, enabled)
+	if handleResult(w, hook, err, false, r.URL.Path) {
+		return
+	}
+	webhook.Refresh()
+
+	resultToJSON(w, WebhookResult{Webhook: hook, Secret: hook.Secret}, r.URL.Path)
+}
+
+// updateWebhook changes the name, URL, events or state of a webhook. Only for admins.
+func updateWebhook(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	webhookID, ok := webhookIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	edit, ok := webhookEditFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	hook, err := db.UpdateWebhook(webhookID, edit)
+	if handleResult(w, hook, err, false, r.URL.Path) {
+		return
+	}
+	webhook.Refresh()
+
+	resultToJSON(w, WebhookResult{Webhook: hook}, r.URL.Path)
+}
+
+// deleteWebhook removes a webhook and its deliveries. Only for admins.
+func deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	webhookID, ok := webhookIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := db.DeleteWebhook(webhookID)
+	if handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+	webhook.Refresh()
+
+	messageToJSON(w, "webhook removed", r.URL.Path)
+}
+
+// returnWebhookDeliveries returns the latest deliveries of a webhook and the results of their attempts.
+// Only for admins.
+func returnWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	webhookID, ok := webhookIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	status := db.DeliveryStatus(query.Get("status"))
+	switch status {
+	case "", db.DeliveryPending, db.DeliveryDelivered, db.DeliveryFailed:
+	default:
+		errorHandler(w, http.StatusBadRequest, "status must be pending, delivered or failed", r.URL.Path)
+		return
+	}
+
+	limit, err := strconv.ParseInt(query.Get("limit"), 10, 64)
+	if err != nil || limit < 1 || limit > 1000 {
+		limit = 100
+	}
+	offset, err := strconv.ParseInt(query.Get("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	deliveries, err := db.GetWebhookDeliveries(webhookID, db.WebhookDeliveryFilters{
+		Status: status,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if handleResult(w, deliveries, err, true, r.URL.Path) {
+		return
+	}
+
+	resultToJSON(w, struct {
+		Data  []model.WebhookDelivery
+		Count int
+	}{
+		Data:  deliveries,
+		Count: len(deliveries),
+	}, r.URL.Path)
+}
+
+// retryWebhookDelivery queues a delivered or failed delivery again. Only for admins.
+func retryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	access, _ := hasAccess(w, r, db.Admin)
+	if !access {
+		return
+	}
+
+	webhookID, ok := webhookIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+	deliveryID, err := strconv.ParseInt(mux.Vars(r)["delivery"], 10, 32)
+	if err != nil {
+		errorHandler(w, http.StatusBadRequest, "invalid delivery id", r.URL.Path)
+		return
+	}
+
+	err = db.RetryWebhookDelivery(webhookID, int32(deliveryID))
+	if handleResult(w, struct{}{}, err, false, r.URL.Path) {
+		return
+	}
+
+	messageToJSON(w, "delivery queued", r.URL.Path)
+}
+
+// webhookEditFromRequest parses and validates the webhook form.
+func webhookEditFromRequest(w http.ResponseWriter, r *http.Request) (db.WebhookEdit, bool) {
+	formData := &WebhookForm{}
+	if err := json.NewDecoder(r.Body).Decode(formData); err != nil {
+		errorHandler(w, http.StatusBadRequest, err.Error(), r.URL.Path)
+		return db.WebhookEdit{}, false
+	}
+
+	edit := db.WebhookEdit{Enabled: formData.Enabled, Events: formData.Events}
+
+	if formData.Name != nil {
+		name := strings.TrimSpace(*formData.Name)
+		if name == "" {
+			errorHandler(w, http.StatusBadRequest, "name is required", r.URL.Path)
+			return db.WebhookEdit{}, false
+		}
+		edit.Name = &name
+	}
+
+	if formData.URL != nil {
+		target, err := url.Parse(*formData.URL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			errorHandler(w, http.StatusBadRequest, "URL must be an absolute http or https URL", r.URL.Path)
+			return db.WebhookEdit{}, false
+		}
+		edit.URL = formData.URL
+	}
+
+	return edit, true
+}
+
+func webhookIDFromRequest(w http.ResponseWriter, r *http.Request) (int32, bool) {
+	webhookID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		errorHandler(w, http.StatusBadRequest, "invalid webhook id", r.URL.Path)
+		return 0, false
+	}
+
+	return int32(webhookID), true
+}
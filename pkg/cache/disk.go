@@ -9,6 +9,7 @@ import (
 
 	"github.com/Mangatsu/server/internal/config"
 	"github.com/Mangatsu/server/pkg/log"
+	"github.com/Mangatsu/server/pkg/metrics"
 	"github.com/Mangatsu/server/pkg/utils"
 	"github.com/facette/natsort"
 	"go.uber.org/zap"
@@ -74,6 +75,7 @@ func readPhysicalCache(dst string, uuid string) ([]string, int) {
 func extractGallery(archivePath string, uuid string) ([]string, int) {
 	dst := config.BuildCachePath(uuid)
 	if _, err := os.Stat(dst); errors.Is(err, fs.ErrNotExist) {
+		metrics.CacheRequests.Inc("miss")
 		return utils.UniversalExtract(dst, archivePath)
 	}
 
@@ -87,9 +89,11 @@ func extractGallery(archivePath string, uuid string) ([]string, int) {
 			return nil, 0
 		}
 
+		metrics.CacheRequests.Inc("miss")
 		return utils.UniversalExtract(dst, archivePath)
 	}
 	natsort.Sort(files)
+	metrics.CacheRequests.Inc("hit")
 
 	return files, count
 }
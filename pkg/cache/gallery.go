@@ -52,8 +52,8 @@ func PruneCache() {
 	now := time.Now()
 	for galleryUUID, value := range galleryCache.Store {
 		value.Mu.Lock()
-		if value.Accessed.Add(config.Options.Cache.TTL).Before(now) {
-			if err := remove(galleryUUID); err != nil {
+		if value.Accessed.Add(config.Options.Reloadable().CacheTTL).Before(now) {
+			if err := Remove(galleryUUID); err != nil {
 				log.Z.Error("failed to delete a cache entry",
 					zap.Bool("thread-safe", true),
 					zap.String("uuid", galleryUUID),
@@ -65,18 +65,29 @@ func PruneCache() {
 }
 
 // PruneCacheFS removes entries not accessed (filesystem timestamp) in the last x time. Not thread-safe.
-func PruneCacheFS() {
+// Returns the number of removed entries.
+func PruneCacheFS() int {
 	now := time.Now()
+	removed := 0
 	iterateCacheEntries(func(pathToEntry string, accessTime time.Time) {
-		if accessTime.Add(config.Options.Cache.TTL).Before(now) {
-			if err := os.Remove(pathToEntry); err != nil {
+		// Only extracted galleries are pruned, never the thumbnails dir.
+		if _, err := uuid.Parse(path.Base(pathToEntry)); err != nil {
+			return
+		}
+
+		if accessTime.Add(config.Options.Reloadable().CacheTTL).Before(now) {
+			if err := os.RemoveAll(pathToEntry); err != nil {
 				log.Z.Error("failed to delete a cache entry",
 					zap.Bool("thread-safe", false),
 					zap.String("path", pathToEntry),
 					zap.String("err", err.Error()))
+				return
 			}
+			removed++
 		}
 	})
+
+	return removed
 }
 
 // Read reads the cached gallery from the disk. If it doesn't exist, it will be created and then read.
@@ -97,8 +108,8 @@ func Read(archivePath string, galleryUUID string) ([]string, int) {
 	return files, count
 }
 
-// remove wipes the cached gallery from the disk.
-func remove(galleryUUID string) error {
+// Remove wipes the cached gallery from the disk.
+func Remove(galleryUUID string) error {
 	// Paranoid check to make sure that the base is a real UUID, since we don't want to delete anything else.
 	maybeUUID := path.Base(galleryUUID)
 	if _, err := uuid.Parse(maybeUUID); err != nil {
@@ -119,6 +130,18 @@ func remove(galleryUUID string) error {
 	return nil
 }
 
+// Size returns the number of galleries extracted to the cache.
+func Size() int {
+	size := 0
+	iterateCacheEntries(func(pathToEntry string, _ time.Time) {
+		if _, err := uuid.Parse(path.Base(pathToEntry)); err == nil {
+			size++
+		}
+	})
+
+	return size
+}
+
 // iterateCacheEntries iterates over all cache entries and calls the callback function for each entry.
 func iterateCacheEntries(callback func(pathToEntry string, accessTime time.Time)) {
 	cachePath := config.BuildCachePath()
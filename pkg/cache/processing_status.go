@@ -1,15 +1,36 @@
 package cache
 
+import (
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/events"
+	"github.com/Mangatsu/server/pkg/metrics"
+)
+
+// Limits of the lists in the status. The counts include the items dropped from the lists.
+const (
+	maxListedGalleries = 100
+	maxListedErrors    = 100
+	maxRunHistory      = 20
+)
+
 type processingError struct {
 	UUIDOrPath string
 	Error      string
 	Details    map[string]string
+	Time       time.Time
 }
 
 type scanResult struct {
 	Running          bool
-	FoundGalleries   []string
-	SkippedGalleries []string
+	FoundCount       int
+	SkippedCount     int
+	ErrorCount       int
+	FoundGalleries   []string // Latest found galleries
+	SkippedGalleries []string // Latest skipped galleries
 	Errors           []processingError
 }
 
@@ -19,119 +40,383 @@ type thumbnailResult struct {
 	TotalPages      int
 	GeneratedCovers int
 	GeneratedPages  int
+	ErrorCount      int
 	Errors          []processingError
 }
 
 type metadataResult struct {
-	// TODO: more information like progress and which sources are being used
-	Running bool
-	Errors  []processingError
+	Running    bool
+	Sources    []string // Metadata types and providers of the running tasks
+	Total      int      // Galleries to process, counted separately for each source
+	Processed  int
+	Parsed     int
+	ErrorCount int
+	Errors     []processingError
 }
 
-type ProcessingStatus struct {
+// TaskRun is a record of a single run of a task.
+type TaskRun struct {
+	Task       events.Task
+	StartedAt  time.Time
+	FinishedAt *time.Time
+	Duration   float64        // Seconds
+	Totals     map[string]int // Such as found galleries or generated thumbnails, and errors
+}
+
+// StatusSnapshot is a copy of the processing status, safe to read while the tasks are running.
+type StatusSnapshot struct {
 	Scan       scanResult
 	Thumbnails thumbnailResult
 	Metadata   metadataResult
+	History    []TaskRun // Latest runs, the newest first
+}
+
+// ProcessingStatus tracks the scan, thumbnail and metadata tasks. Safe for concurrent use.
+type ProcessingStatus struct {
+	mu         sync.Mutex
+	scan       scanResult
+	thumbnails thumbnailResult
+	metadata   metadataResult
+	history    []TaskRun
+	// Runs in progress by their task. The same task can be started multiple times concurrently, for example
+	// metadata parsing with different sources, in which case they are recorded as one run.
+	runs   map[events.Task]*TaskRun
+	active map[events.Task]int
 }
 
 var ProcessingStatusCache *ProcessingStatus
 
 func InitProcessingStatusCache() {
 	ProcessingStatusCache = &ProcessingStatus{
-		Scan: scanResult{
-			Running:          false,
-			FoundGalleries:   make([]string, 0),
-			SkippedGalleries: make([]string, 0),
-			Errors:           make([]processingError, 0),
-		},
-		Thumbnails: thumbnailResult{
-			Running:         false,
-			GeneratedCovers: 0,
-			GeneratedPages:  0,
-			Errors:          make([]processingError, 0),
-		},
-		Metadata: metadataResult{
-			Running: false,
-			Errors:  make([]processingError, 0),
-		},
+		scan:       newScanResult(),
+		thumbnails: newThumbnailResult(),
+		metadata:   newMetadataResult(),
+		history:    make([]TaskRun, 0),
+		runs:       make(map[events.Task]*TaskRun),
+		active:     make(map[events.Task]int),
 	}
 }
 
+// Snapshot returns a copy of the status.
+func (s *ProcessingStatus) Snapshot() StatusSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := StatusSnapshot{
+		Scan:       s.scan,
+		Thumbnails: s.thumbnails,
+		Metadata:   s.metadata,
+		History:    make([]TaskRun, 0, len(s.history)+len(s.runs)),
+	}
+	snapshot.Scan.FoundGalleries = slices.Clone(s.scan.FoundGalleries)
+	snapshot.Scan.SkippedGalleries = slices.Clone(s.scan.SkippedGalleries)
+	snapshot.Scan.Errors = slices.Clone(s.scan.Errors)
+	snapshot.Thumbnails.Errors = slices.Clone(s.thumbnails.Errors)
+	snapshot.Metadata.Sources = slices.Clone(s.metadata.Sources)
+	snapshot.Metadata.Errors = slices.Clone(s.metadata.Errors)
+
+	now := time.Now()
+	for _, run := range s.runs {
+		current := *run
+		current.Duration = now.Sub(run.StartedAt).Seconds()
+		current.Totals = s.totals(run.Task)
+		snapshot.History = append(snapshot.History, current)
+	}
+	slices.SortFunc(snapshot.History, func(a, b TaskRun) int {
+		return b.StartedAt.Compare(a.StartedAt)
+	})
+	for i := len(s.history) - 1; i >= 0; i-- {
+		snapshot.History = append(snapshot.History, s.history[i])
+	}
+
+	return snapshot
+}
+
 func (s *ProcessingStatus) SetScanRunning(running bool) {
-	s.Scan.Running = running
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.setRunning(events.TaskScan, running) && running {
+		s.scan = newScanResult()
+	}
+	s.scan.Running = s.active[events.TaskScan] > 0
 }
 
 func (s *ProcessingStatus) AddScanFoundGallery(galleryUUID string) {
-	s.Scan.FoundGalleries = append(s.Scan.FoundGalleries, galleryUUID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scan.FoundCount++
+	metrics.TaskItems.Inc(string(events.TaskScan), "found")
+	s.scan.FoundGalleries = appendBounded(s.scan.FoundGalleries, galleryUUID, maxListedGalleries)
+	events.Publish(events.Event{Type: events.GalleryAdded, Task: events.TaskScan, GalleryUUID: galleryUUID})
 }
 
 func (s *ProcessingStatus) AddScanSkippedGallery(galleryUUID string) {
-	s.Scan.SkippedGalleries = append(s.Scan.SkippedGalleries, galleryUUID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scan.SkippedCount++
+	metrics.TaskItems.Inc(string(events.TaskScan), "skipped")
+	s.scan.SkippedGalleries = appendBounded(s.scan.SkippedGalleries, galleryUUID, maxListedGalleries)
 }
 
 func (s *ProcessingStatus) AddScanError(uuidOrPath string, err string, details map[string]string) {
-	s.Scan.Errors = append(s.Scan.Errors, processingError{
-		UUIDOrPath: uuidOrPath,
-		Error:      err,
-		Details:    details,
-	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scan.ErrorCount++
+	metrics.TaskErrors.Inc(string(events.TaskScan))
+	s.scan.Errors = appendBounded(s.scan.Errors, newProcessingError(uuidOrPath, err, details), maxListedErrors)
+	publishError(events.TaskScan, uuidOrPath, err, details)
 }
 
 func (s *ProcessingStatus) SetThumbnailsRunning(running bool) {
-	s.Thumbnails.Running = running
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.setRunning(events.TaskThumbnails, running) && running {
+		s.thumbnails = newThumbnailResult()
+	}
+	s.thumbnails.Running = s.active[events.TaskThumbnails] > 0
 }
 
 func (s *ProcessingStatus) SetTotalCoversAndPages(coverCount int, pageCount int) {
-	ProcessingStatusCache.Thumbnails.TotalCovers = coverCount
-	ProcessingStatusCache.Thumbnails.TotalPages = pageCount
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.thumbnails.TotalCovers = coverCount
+	s.thumbnails.TotalPages = pageCount
 }
 
-func (s *ProcessingStatus) AddThumbnailGeneratedCover() {
-	s.Thumbnails.GeneratedCovers++
+func (s *ProcessingStatus) AddThumbnailGeneratedCover(galleryUUID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.thumbnails.GeneratedCovers++
+	metrics.TaskItems.Inc(string(events.TaskThumbnails), "covers")
+	events.Publish(events.Event{
+		Type:        events.ThumbnailGenerated,
+		Task:        events.TaskThumbnails,
+		GalleryUUID: galleryUUID,
+		Details:     map[string]string{"kind": "cover"},
+	})
 }
 
 func (s *ProcessingStatus) AddThumbnailGeneratedPage() {
-	s.Thumbnails.GeneratedPages++
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.thumbnails.GeneratedPages++
+	metrics.TaskItems.Inc(string(events.TaskThumbnails), "pages")
 }
 
-func (s *ProcessingStatus) AddThumbnailError(uuidOrPath string, err string, details map[string]string) {
-	s.Thumbnails.Errors = append(s.Thumbnails.Errors, processingError{
-		UUIDOrPath: uuidOrPath,
-		Error:      err,
-		Details:    details,
+// FinishThumbnailPages publishes an event once the page thumbnails of a gallery are generated.
+// The pages are counted with AddThumbnailGeneratedPage.
+func (s *ProcessingStatus) FinishThumbnailPages(galleryUUID string, count int) {
+	events.Publish(events.Event{
+		Type:        events.ThumbnailGenerated,
+		Task:        events.TaskThumbnails,
+		GalleryUUID: galleryUUID,
+		Details:     map[string]string{"kind": "pages", "count": strconv.Itoa(count)},
 	})
 }
 
+func (s *ProcessingStatus) AddThumbnailError(uuidOrPath string, err string, details map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.thumbnails.ErrorCount++
+	metrics.TaskErrors.Inc(string(events.TaskThumbnails))
+	s.thumbnails.Errors = appendBounded(s.thumbnails.Errors, newProcessingError(uuidOrPath, err, details), maxListedErrors)
+	publishError(events.TaskThumbnails, uuidOrPath, err, details)
+}
+
 func (s *ProcessingStatus) SetMetadataRunning(running bool) {
-	s.Metadata.Running = running
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
+	if s.setRunning(events.TaskMetadata, running) && running {
+		s.metadata = newMetadataResult()
+	}
+	s.metadata.Running = s.active[events.TaskMetadata] > 0
 }
 
-func (s *ProcessingStatus) AddMetadataError(uuidOrPath string, err string, details map[string]string) {
-	s.Metadata.Errors = append(s.Metadata.Errors, processingError{
-		UUIDOrPath: uuidOrPath,
-		Error:      err,
-		Details:    details,
+// AddMetadataTotal adds the galleries a metadata source is going to process to the total.
+func (s *ProcessingStatus) AddMetadataTotal(source string, galleryCount int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.metadata.Total += galleryCount
+	if !slices.Contains(s.metadata.Sources, source) {
+		s.metadata.Sources = append(s.metadata.Sources, source)
+	}
+}
+
+// AddMetadataProcessed counts a gallery processed by a metadata source, whether metadata was found or not.
+func (s *ProcessingStatus) AddMetadataProcessed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.metadata.Processed++
+	metrics.TaskItems.Inc(string(events.TaskMetadata), "processed")
+}
+
+func (s *ProcessingStatus) AddMetadataParsed(galleryUUID string, source string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.metadata.Parsed++
+	metrics.TaskItems.Inc(string(events.TaskMetadata), "parsed")
+	events.Publish(events.Event{
+		Type:        events.MetadataParsed,
+		Task:        events.TaskMetadata,
+		GalleryUUID: galleryUUID,
+		Details:     map[string]string{"source": source},
 	})
 }
 
+func (s *ProcessingStatus) AddMetadataError(uuidOrPath string, err string, details map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.metadata.ErrorCount++
+	metrics.TaskErrors.Inc(string(events.TaskMetadata))
+	s.metadata.Errors = appendBounded(s.metadata.Errors, newProcessingError(uuidOrPath, err, details), maxListedErrors)
+	publishError(events.TaskMetadata, uuidOrPath, err, details)
+}
+
+// Reset clears the results and the history. Running tasks keep running and are recorded when they finish.
 func (s *ProcessingStatus) Reset() {
-	s.Scan = scanResult{
-		Running:          false,
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scanRunning, thumbnailsRunning, metadataRunning := s.scan.Running, s.thumbnails.Running, s.metadata.Running
+	s.scan = newScanResult()
+	s.thumbnails = newThumbnailResult()
+	s.metadata = newMetadataResult()
+	s.scan.Running, s.thumbnails.Running, s.metadata.Running = scanRunning, thumbnailsRunning, metadataRunning
+	s.history = make([]TaskRun, 0)
+}
+
+// setRunning counts the running instances of the task, and starts or finishes its run. Returns true if a run
+// was started or finished. Must be called with the lock held.
+func (s *ProcessingStatus) setRunning(task events.Task, running bool) bool {
+	if running {
+		s.active[task]++
+		if s.active[task] > 1 {
+			return false
+		}
+
+		s.runs[task] = &TaskRun{Task: task, StartedAt: time.Now()}
+		publishRunning(task, true)
+		return true
+	}
+
+	if s.active[task] == 0 {
+		return false
+	}
+	s.active[task]--
+	if s.active[task] > 0 {
+		return false
+	}
+
+	run := s.runs[task]
+	delete(s.runs, task)
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Duration = finishedAt.Sub(run.StartedAt).Seconds()
+	run.Totals = s.totals(task)
+	s.history = appendBounded(s.history, *run, maxRunHistory)
+	metrics.TaskRuns.Inc(string(task))
+	metrics.TaskDuration.Observe(run.Duration, string(task))
+
+	publishRunning(task, false)
+	return true
+}
+
+// totals returns the totals of the current results of the task. Must be called with the lock held.
+func (s *ProcessingStatus) totals(task events.Task) map[string]int {
+	switch task {
+	case events.TaskScan:
+		return map[string]int{
+			"found":   s.scan.FoundCount,
+			"skipped": s.scan.SkippedCount,
+			"errors":  s.scan.ErrorCount,
+		}
+	case events.TaskThumbnails:
+		return map[string]int{
+			"covers": s.thumbnails.GeneratedCovers,
+			"pages":  s.thumbnails.GeneratedPages,
+			"errors": s.thumbnails.ErrorCount,
+		}
+	case events.TaskMetadata:
+		return map[string]int{
+			"processed": s.metadata.Processed,
+			"parsed":    s.metadata.Parsed,
+			"errors":    s.metadata.ErrorCount,
+		}
+	default:
+		return nil
+	}
+}
+
+func newScanResult() scanResult {
+	return scanResult{
 		FoundGalleries:   make([]string, 0),
 		SkippedGalleries: make([]string, 0),
 		Errors:           make([]processingError, 0),
 	}
-	s.Thumbnails = thumbnailResult{
-		Running:         false,
-		TotalCovers:     0,
-		TotalPages:      0,
-		GeneratedCovers: 0,
-		GeneratedPages:  0,
-		Errors:          make([]processingError, 0),
+}
+
+func newThumbnailResult() thumbnailResult {
+	return thumbnailResult{
+		Errors: make([]processingError, 0),
 	}
-	s.Metadata = metadataResult{
-		Running: false,
+}
+
+func newMetadataResult() metadataResult {
+	return metadataResult{
+		Sources: make([]string, 0),
 		Errors:  make([]processingError, 0),
 	}
 }
+
+func newProcessingError(uuidOrPath string, err string, details map[string]string) processingError {
+	return processingError{
+		UUIDOrPath: uuidOrPath,
+		Error:      err,
+		Details:    details,
+		Time:       time.Now(),
+	}
+}
+
+// appendBounded appends the item and drops the oldest items over the limit.
+func appendBounded[T any](items []T, item T, limit int) []T {
+	items = append(items, item)
+	if len(items) > limit {
+		items = slices.Delete(items, 0, len(items)-limit)
+	}
+
+	return items
+}
+
+func publishRunning(task events.Task, running bool) {
+	eventType := events.TaskFinished
+	if running {
+		eventType = events.TaskStarted
+	}
+
+	events.Publish(events.Event{Type: eventType, Task: task})
+}
+
+func publishError(task events.Task, uuidOrPath string, err string, details map[string]string) {
+	events.Publish(events.Event{
+		Type:       events.Error,
+		Task:       task,
+		UUIDOrPath: uuidOrPath,
+		Error:      err,
+		Details:    details,
+	})
+}
@@ -0,0 +1,106 @@
+package certs
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/log"
+	"go.uber.org/zap"
+)
+
+// checkInterval is how often the files are checked for changes at most. Checked on TLS handshakes.
+const checkInterval = 10 * time.Second
+
+// Reloader serves a certificate and key pair from files, and reloads them when the files change. Renewed
+// certificates, for example by certbot, are used without restarting the server.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+	checked time.Time
+}
+
+// NewReloader loads the certificate and key pair. Returns an error if they cannot be loaded.
+func NewReloader(certFile string, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, interval: checkInterval}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	r.checked = time.Now()
+	return r, nil
+}
+
+// GetCertificate returns the current certificate. Used as tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(r.checked) >= r.interval {
+		r.checked = now
+		if r.changed() {
+			// The previous certificate is kept if the new files are invalid, for example while the certificate has
+			// been written but the key not yet. Retried on the next check.
+			if err := r.reload(); err != nil {
+				log.Z.Warn("failed to reload the TLS certificate",
+					zap.String("cert", r.certFile),
+					zap.String("key", r.keyFile),
+					zap.String("err", err.Error()))
+			} else {
+				log.Z.Info("TLS certificate reloaded", zap.String("cert", r.certFile))
+			}
+		}
+	}
+
+	return r.cert, nil
+}
+
+// changed returns true if either file has been modified since it was loaded. Must be called with the lock held.
+func (r *Reloader) changed() bool {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		return false
+	}
+
+	return !certMod.Equal(r.certMod) || !keyMod.Equal(r.keyMod)
+}
+
+// reload loads the certificate and key pair. Must be called with the lock held, or before the Reloader is used.
+func (r *Reloader) reload() error {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.cert = &cert
+	r.certMod = certMod
+	r.keyMod = keyMod
+	return nil
+}
+
+func (r *Reloader) modTimes() (time.Time, time.Time, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
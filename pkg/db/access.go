@@ -0,0 +1,349 @@
+package db
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/log"
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	. "github.com/Mangatsu/server/pkg/types/sqlite/table"
+	. "github.com/go-jet/jet/v2/sqlite"
+	"go.uber.org/zap"
+)
+
+type PrincipalType string
+
+// Access rules are granted to either a single user or a group of users.
+const (
+	PrincipalUser  PrincipalType = "user"
+	PrincipalGroup PrincipalType = "group"
+)
+
+// Group is a named group of users used in the library access rules.
+type Group struct {
+	model.UserGroup
+	Members []string
+}
+
+// viewer is the user whose access is being checked.
+type viewer struct {
+	isAdmin  bool
+	hideNsfw bool
+	userUUID string
+	groupIDs map[string]bool
+}
+
+// GetGroups returns all groups and the UUIDs of their members.
+func GetGroups() ([]Group, error) {
+	stmt := SELECT(UserGroup.AllColumns, UserGroupMember.UserUUID).
+		FROM(UserGroup.LEFT_JOIN(UserGroupMember, UserGroupMember.GroupID.EQ(UserGroup.ID))).
+		ORDER_BY(UserGroup.Name.ASC())
+
+	var rows []struct {
+		model.UserGroup
+		Members []model.UserGroupMember
+	}
+	if err := stmt.Query(db(), &rows); err != nil {
+		return nil, err
+	}
+
+	groups := make([]Group, 0, len(rows))
+	for _, row := range rows {
+		members := make([]string, 0, len(row.Members))
+		for _, member := range row.Members {
+			members = append(members, member.UserUUID)
+		}
+		groups = append(groups, Group{UserGroup: row.UserGroup, Members: members})
+	}
+
+	return groups, nil
+}
+
+// NewGroup creates a new group.
+func NewGroup(name string) (model.UserGroup, error) {
+	stmt := UserGroup.INSERT(UserGroup.Name, UserGroup.CreatedAt).
+		VALUES(name, time.Now()).
+		RETURNING(UserGroup.AllColumns)
+
+	var groups []model.UserGroup
+	if err := stmt.Query(db(), &groups); err != nil {
+		return model.UserGroup{}, err
+	}
+	if len(groups) == 0 {
+		return model.UserGroup{}, sql.ErrNoRows
+	}
+
+	return groups[0], nil
+}
+
+// DeleteGroup removes the group, its memberships and its access rules.
+func DeleteGroup(groupID int32) error {
+	tx, err := db().Begin()
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			rollbackTx(tx)
+		}
+	}()
+
+	res, err := UserGroup.DELETE().WHERE(UserGroup.ID.EQ(Int32(groupID))).Exec(tx)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err = UserGroupMember.DELETE().WHERE(UserGroupMember.GroupID.EQ(Int32(groupID))).Exec(tx); err != nil {
+		return err
+	}
+
+	deleteRulesStmt := LibraryAccess.DELETE().WHERE(
+		LibraryAccess.PrincipalType.EQ(String(string(PrincipalGroup))).
+			AND(LibraryAccess.PrincipalID.EQ(String(groupPrincipalID(groupID)))),
+	)
+	if _, err = deleteRulesStmt.Exec(tx); err != nil {
+		return err
+	}
+
+	committed = true
+	return tx.Commit()
+}
+
+// AddGroupMember adds the user to the group.
+func AddGroupMember(groupID int32, userUUID string) error {
+	stmt := UserGroupMember.INSERT(UserGroupMember.GroupID, UserGroupMember.UserUUID).
+		VALUES(groupID, userUUID).
+		ON_CONFLICT(UserGroupMember.GroupID, UserGroupMember.UserUUID).
+		DO_NOTHING()
+
+	_, err := stmt.Exec(db())
+	return err
+}
+
+// RemoveGroupMember removes the user from the group.
+func RemoveGroupMember(groupID int32, userUUID string) error {
+	stmt := UserGroupMember.DELETE().WHERE(
+		UserGroupMember.GroupID.EQ(Int32(groupID)).AND(UserGroupMember.UserUUID.EQ(String(userUUID))),
+	)
+
+	_, err := stmt.Exec(db())
+	return err
+}
+
+// GetLibraryAccess returns the access rules of the library.
+func GetLibraryAccess(libraryID int32) ([]model.LibraryAccess, error) {
+	stmt := SELECT(LibraryAccess.AllColumns).
+		FROM(LibraryAccess).
+		WHERE(LibraryAccess.LibraryID.EQ(Int32(libraryID))).
+		ORDER_BY(LibraryAccess.PrincipalType.ASC(), LibraryAccess.PrincipalID.ASC())
+
+	var rules []model.LibraryAccess
+	err := stmt.Query(db(), &rules)
+	return rules, err
+}
+
+// SetLibraryAccess allows or denies the user or group access to the library.
+// A library with at least one allowing rule is only visible to the allowed users and admins.
+func SetLibraryAccess(libraryID int32, principalType PrincipalType, principalID string, allow bool) error {
+	stmt := LibraryAccess.
+		INSERT(LibraryAccess.LibraryID, LibraryAccess.PrincipalType, LibraryAccess.PrincipalID, LibraryAccess.Allow, LibraryAccess.CreatedAt).
+		VALUES(libraryID, string(principalType), principalID, allow, time.Now()).
+		ON_CONFLICT(LibraryAccess.LibraryID, LibraryAccess.PrincipalType, LibraryAccess.PrincipalID).
+		DO_UPDATE(SET(LibraryAccess.Allow.SET(LibraryAccess.EXCLUDED.Allow)))
+
+	_, err := stmt.Exec(db())
+	return err
+}
+
+// RemoveLibraryAccess removes the access rule of the user or group from the library.
+func RemoveLibraryAccess(libraryID int32, principalType PrincipalType, principalID string) error {
+	stmt := LibraryAccess.DELETE().WHERE(
+		LibraryAccess.LibraryID.EQ(Int32(libraryID)).
+			AND(LibraryAccess.PrincipalType.EQ(String(string(principalType)))).
+			AND(LibraryAccess.PrincipalID.EQ(String(principalID))),
+	)
+
+	res, err := stmt.Exec(db())
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// CanAccessGallery returns true if the user, or an anonymous user if nil, may view the files of the gallery.
+// Hidden galleries are only accessible to admins.
+func CanAccessGallery(galleryUUID string, userUUID *string) bool {
+	condition := Gallery.UUID.EQ(String(galleryUUID)).
+		AND(Gallery.Deleted.IS_NOT_TRUE()).
+		AND(accessCondition(userUUID))
+
+	var galleries []model.Gallery
+	if err := SELECT(Gallery.UUID).FROM(Gallery).WHERE(condition).Query(db(), &galleries); err != nil {
+		log.Z.Debug("failed to check gallery access",
+			zap.String("uuid", galleryUUID),
+			zap.String("err", err.Error()))
+		return false
+	}
+
+	return len(galleries) > 0
+}
+
+// accessCondition limits the galleries to the libraries the user may view, and hides NSFW galleries from users
+// with that option set. Admins may view all libraries and hidden galleries. On error, nothing is accessible.
+func accessCondition(userUUID *string) BoolExpression {
+	currentViewer, err := getViewer(userUUID)
+	if err != nil {
+		log.Z.Error("failed to resolve library access", zap.String("err", err.Error()))
+		return Bool(false)
+	}
+
+	libraryIDs, all, err := accessibleLibraries(currentViewer)
+	if err != nil {
+		log.Z.Error("failed to resolve library access", zap.String("err", err.Error()))
+		return Bool(false)
+	}
+
+	condition := Bool(true)
+	if !currentViewer.isAdmin {
+		condition = Gallery.Hidden.IS_NOT_TRUE()
+	}
+	if !all {
+		if len(libraryIDs) == 0 {
+			return Bool(false)
+		}
+
+		ids := make([]Expression, 0, len(libraryIDs))
+		for _, libraryID := range libraryIDs {
+			ids = append(ids, Int32(libraryID))
+		}
+		condition = condition.AND(Gallery.LibraryID.IN(ids...))
+	}
+
+	if currentViewer.hideNsfw {
+		condition = condition.AND(Gallery.Nsfw.IS_NOT_TRUE())
+	}
+
+	return condition
+}
+
+// accessibleLibraries returns the IDs of the libraries the user may view. All is true if there are no restrictions.
+//
+// A rule of the user overrides the rules of their groups, and a denying group rule overrides an allowing one.
+// Without any matching rules, libraries that have no allowing rules are accessible to everyone.
+func accessibleLibraries(currentViewer viewer) ([]int32, bool, error) {
+	if currentViewer.isAdmin {
+		return nil, true, nil
+	}
+
+	var rules []model.LibraryAccess
+	if err := SELECT(LibraryAccess.AllColumns).FROM(LibraryAccess).Query(db(), &rules); err != nil {
+		return nil, false, err
+	}
+	if len(rules) == 0 {
+		return nil, true, nil
+	}
+
+	libraries, err := GetOnlyLibraries()
+	if err != nil {
+		return nil, false, err
+	}
+
+	restricted := map[int32]bool{}
+	userRules := map[int32]bool{}
+	groupAllows := map[int32]bool{}
+	groupDenies := map[int32]bool{}
+	for _, rule := range rules {
+		if rule.Allow {
+			restricted[rule.LibraryID] = true
+		}
+
+		switch PrincipalType(rule.PrincipalType) {
+		case PrincipalUser:
+			if rule.PrincipalID == currentViewer.userUUID {
+				userRules[rule.LibraryID] = rule.Allow
+			}
+		case PrincipalGroup:
+			if currentViewer.groupIDs[rule.PrincipalID] {
+				if rule.Allow {
+					groupAllows[rule.LibraryID] = true
+				} else {
+					groupDenies[rule.LibraryID] = true
+				}
+			}
+		}
+	}
+
+	all := true
+	libraryIDs := make([]int32, 0, len(libraries))
+	for _, library := range libraries {
+		var allowed bool
+		if allow, found := userRules[library.ID]; found {
+			allowed = allow
+		} else if groupDenies[library.ID] {
+			allowed = false
+		} else if groupAllows[library.ID] {
+			allowed = true
+		} else {
+			allowed = !restricted[library.ID]
+		}
+
+		if allowed {
+			libraryIDs = append(libraryIDs, library.ID)
+		} else {
+			all = false
+		}
+	}
+
+	return libraryIDs, all, nil
+}
+
+// getViewer returns the role, options and groups of the user. Anonymous or unknown users have no groups.
+func getViewer(userUUID *string) (viewer, error) {
+	if userUUID == nil {
+		return viewer{}, nil
+	}
+
+	stmt := SELECT(User.Role, User.HideNsfw).FROM(User).WHERE(User.UUID.EQ(String(*userUUID)))
+	var users []model.User
+	if err := stmt.Query(db(), &users); err != nil {
+		return viewer{}, err
+	}
+	if len(users) == 0 {
+		return viewer{}, nil
+	}
+
+	membershipStmt := SELECT(UserGroupMember.AllColumns).
+		FROM(UserGroupMember).
+		WHERE(UserGroupMember.UserUUID.EQ(String(*userUUID)))
+	var memberships []model.UserGroupMember
+	if err := membershipStmt.Query(db(), &memberships); err != nil {
+		return viewer{}, err
+	}
+
+	groupIDs := make(map[string]bool, len(memberships))
+	for _, membership := range memberships {
+		groupIDs[groupPrincipalID(membership.GroupID)] = true
+	}
+
+	return viewer{
+		isAdmin:  Role(users[0].Role) >= Admin,
+		hideNsfw: users[0].HideNsfw,
+		userUUID: *userUUID,
+		groupIDs: groupIDs,
+	}, nil
+}
+
+// groupPrincipalID returns the principal ID of the group used in the access rules.
+func groupPrincipalID(groupID int32) string {
+	return strconv.FormatInt(int64(groupID), 10)
+}
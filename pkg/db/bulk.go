@@ -0,0 +1,225 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	. "github.com/Mangatsu/server/pkg/types/sqlite/table"
+	. "github.com/go-jet/jet/v2/sqlite"
+)
+
+// BulkEdit describes changes applied to many galleries at once. Nil fields are left untouched.
+// Empty strings clear the field.
+type BulkEdit struct {
+	AddTags    []model.Tag
+	RemoveTags []model.Tag
+	Series     *string
+	Category   *string
+	Language   *string
+	Nsfw       *bool
+	Hidden     *bool
+}
+
+// BulkResult is the outcome of a bulk edit for a single gallery.
+type BulkResult struct {
+	UUID    string
+	Changes []FieldChange
+	Error   string `json:",omitempty"`
+}
+
+// Empty returns true if the edit doesn't change anything.
+func (edit BulkEdit) Empty() bool {
+	return len(edit.AddTags) == 0 && len(edit.RemoveTags) == 0 &&
+		edit.Series == nil && edit.Category == nil && edit.Language == nil && edit.Nsfw == nil && edit.Hidden == nil
+}
+
+// GetGalleryUUIDs returns the UUIDs of all galleries matching the filters, including hidden ones.
+// Limit and offset of the filters are ignored.
+func GetGalleryUUIDs(filters Filters, userUUID *string) ([]string, error) {
+	stmt := SELECT(Gallery.UUID).
+		FROM(Gallery).
+		WHERE(constructGalleryFilters(filters, true, userUUID)).
+		ORDER_BY(Gallery.Title.ASC())
+
+	var galleries []model.Gallery
+	if err := stmt.Query(db(), &galleries); err != nil {
+		return nil, err
+	}
+
+	uuids := make([]string, 0, len(galleries))
+	for _, gallery := range galleries {
+		uuids = append(uuids, gallery.UUID)
+	}
+
+	return uuids, nil
+}
+
+// BulkUpdateGalleries applies the edit to all given galleries in a single transaction.
+// Changes are stored as revisions and the changed fields are locked like in any other manual edit.
+// If any of the galleries fails, or dryRun is true, nothing is committed.
+// Returns the results of each gallery and whether the changes were committed.
+func BulkUpdateGalleries(galleryUUIDs []string, edit BulkEdit, dryRun bool, author Author) ([]BulkResult, bool, error) {
+	removeTagIDs, err := getTagIDs(edit.RemoveTags)
+	if err != nil {
+		return nil, false, err
+	}
+
+	tx, err := db().Begin()
+	if err != nil {
+		return nil, false, err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			rollbackTx(tx)
+		}
+	}()
+
+	addTagIDs, err := newTags(tx, edit.AddTags)
+	if err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	if edit.Series != nil {
+		if err = addSeries(tx, SanitizeString(edit.Series), now); err != nil {
+			return nil, false, err
+		}
+	}
+	failed := false
+	results := make([]BulkResult, 0, len(galleryUUIDs))
+
+	for _, galleryUUID := range galleryUUIDs {
+		changes, err := bulkUpdateGallery(tx, galleryUUID, edit, addTagIDs, removeTagIDs, false, author, now)
+		result := BulkResult{UUID: galleryUUID, Changes: changes}
+
+		if err != nil {
+			failed = true
+			if errors.Is(err, sql.ErrNoRows) {
+				result.Error = "gallery not found"
+			} else {
+				result.Error = err.Error()
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	if dryRun || failed {
+		return results, false, nil
+	}
+
+	committed = true
+	if err = tx.Commit(); err != nil {
+		return results, false, err
+	}
+
+	return results, true, nil
+}
+
+// bulkUpdateGallery applies the edit to a single gallery within the transaction. Galleries in the trash are not found
+// unless trashed is true.
+func bulkUpdateGallery(
+	tx *sql.Tx,
+	galleryUUID string,
+	edit BulkEdit,
+	addTagIDs []int32,
+	removeTagIDs []int32,
+	trashed bool,
+	author Author,
+	now time.Time,
+) ([]FieldChange, error) {
+	prevState, err := getGalleryState(tx, galleryUUID)
+	if err != nil {
+		return nil, err
+	}
+	if prevState.Gallery.Deleted && !trashed {
+		return nil, sql.ErrNoRows
+	}
+
+	galleryModel := model.Gallery{UpdatedAt: now}
+	columns := ColumnList{Gallery.UpdatedAt}
+
+	if edit.Series != nil {
+		galleryModel.Series = SanitizeString(edit.Series)
+		columns = append(columns, Gallery.Series)
+	}
+	if edit.Category != nil {
+		galleryModel.Category = SanitizeString(edit.Category)
+		columns = append(columns, Gallery.Category)
+	}
+	if edit.Language != nil {
+		galleryModel.Language = SanitizeString(edit.Language)
+		columns = append(columns, Gallery.Language)
+	}
+	if edit.Nsfw != nil {
+		galleryModel.Nsfw = *edit.Nsfw
+		columns = append(columns, Gallery.Nsfw)
+	}
+	if edit.Hidden != nil {
+		galleryModel.Hidden = *edit.Hidden
+		columns = append(columns, Gallery.Hidden)
+	}
+
+	updateStmt := Gallery.UPDATE(columns).MODEL(galleryModel).WHERE(Gallery.UUID.EQ(String(galleryUUID)))
+	if _, err = updateStmt.Exec(tx); err != nil {
+		return nil, err
+	}
+
+	if len(removeTagIDs) > 0 {
+		tagIDs := make([]Expression, 0, len(removeTagIDs))
+		for _, tagID := range removeTagIDs {
+			tagIDs = append(tagIDs, Int32(tagID))
+		}
+
+		deleteStmt := GalleryTag.DELETE().WHERE(
+			GalleryTag.GalleryUUID.EQ(String(galleryUUID)).AND(GalleryTag.TagID.IN(tagIDs...)),
+		)
+		if _, err = deleteStmt.Exec(tx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = insertGalleryTags(tx, galleryUUID, addTagIDs); err != nil {
+		return nil, err
+	}
+
+	newState, err := getGalleryState(tx, galleryUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := diffStates(prevState, newState)
+	if err = insertRevision(tx, galleryUUID, author, changes, now); err != nil {
+		return nil, err
+	}
+	if err = lockFields(tx, galleryUUID, changedFields(changes), now); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// getTagIDs returns the IDs of the given tags. Tags that don't exist are skipped.
+func getTagIDs(tags []model.Tag) ([]int32, error) {
+	var tagIDs []int32
+	for _, tag := range tags {
+		stmt := SELECT(Tag.ID).
+			FROM(Tag).
+			WHERE(Tag.Namespace.EQ(String(tag.Namespace)).AND(Tag.Name.EQ(String(tag.Name))))
+
+		var existingTags []model.Tag
+		if err := stmt.Query(db(), &existingTags); err != nil {
+			return nil, err
+		}
+
+		for _, existingTag := range existingTags {
+			tagIDs = append(tagIDs, existingTag.ID)
+		}
+	}
+
+	return tagIDs, nil
+}
@@ -30,6 +30,17 @@ func db() *sql.DB {
 	return database
 }
 
+// Close closes the database. Called on shutdown once the background jobs have finished.
+func Close() {
+	if database == nil {
+		return
+	}
+
+	if err := database.Close(); err != nil {
+		log.Z.Error("failed to close the database", zap.String("err", err.Error()))
+	}
+}
+
 // EnsureLatestVersion ensures that the database is at the latest version by running all migrations.
 func EnsureLatestVersion() {
 	if !config.Options.DB.Migrations {
@@ -37,19 +48,47 @@ func EnsureLatestVersion() {
 		return
 	}
 
-	// For embedding the migrations in the binary.
+	err := MigrateUp()
+	fmt.Println("")
+	if err != nil {
+		log.Z.Fatal("failed to apply new migrations", zap.String("err", err.Error()))
+	}
+}
+
+// setupMigrations points goose to the migrations embedded in the binary.
+func setupMigrations() error {
 	goose.SetBaseFS(embedMigrations)
+	return goose.SetDialect("sqlite3")
+}
 
-	err := goose.SetDialect("sqlite3")
-	if err != nil {
-		log.Z.Fatal("failed setting DB dialect", zap.String("err", err.Error()))
+// MigrateUp applies all pending migrations.
+func MigrateUp() error {
+	if err := setupMigrations(); err != nil {
+		return err
 	}
+	return goose.Up(db(), "migrations")
+}
 
-	err = goose.Up(db(), "migrations")
-	fmt.Println("")
-	if err != nil {
-		log.Z.Fatal("failed to apply new migrations", zap.String("err", err.Error()))
+// MigrateDown rolls back the latest applied migration.
+func MigrateDown() error {
+	if err := setupMigrations(); err != nil {
+		return err
 	}
+	return goose.Down(db(), "migrations")
+}
+
+// MigrationStatus prints the status of all migrations.
+func MigrationStatus() error {
+	if err := setupMigrations(); err != nil {
+		return err
+	}
+	return goose.Status(db(), "migrations")
+}
+
+// Backup writes a consistent copy of the database into the given file. The file must not exist beforehand.
+func Backup(dst string) error {
+	_, err := db().Exec("VACUUM INTO ?", dst)
+	return err
 }
 
 func rollbackTx(tx *sql.Tx) {
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"cmp"
 	"database/sql"
 	"errors"
 	"github.com/Mangatsu/server/internal/config"
@@ -15,6 +16,7 @@ import (
 	"github.com/Mangatsu/server/pkg/log"
 	"github.com/Mangatsu/server/pkg/types/sqlite/model"
 	. "github.com/Mangatsu/server/pkg/types/sqlite/table"
+	"github.com/go-jet/jet/v2/qrm"
 	. "github.com/go-jet/jet/v2/sqlite"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -26,9 +28,10 @@ type CombinedMetadata struct {
 	Tags []model.Tag
 
 	Reference struct {
-		ExhToken *string
-		ExhGid   *int32
-		Urls     *string
+		ExhToken  *string
+		ExhGid    *int32
+		AnilistID *int32
+		Urls      *string
 	} `alias:"reference.*"`
 
 	GalleryPref *struct {
@@ -71,6 +74,7 @@ const (
 	TitleNative        = "native"
 	UpdatedAt          = "updated"
 	Progress           = "progress"
+	Reading            = "reading" // Order of the galleries in their series
 )
 
 type Order string
@@ -81,26 +85,68 @@ const (
 )
 
 // NewGallery creates a new gallery
-func NewGallery(archivePath string, libraryID int32, title string, series string, size int64, imageCount uint64) (string, error) {
+func NewGallery(
+	archivePath string,
+	libraryID int32,
+	title string,
+	series string,
+	volume *float64,
+	chapter *float64,
+	size int64,
+	imageCount uint64,
+) (string, error) {
 	galleryUUID, err := uuid.NewRandom()
 	if err != nil {
 		return "", err
 	}
 
+	// New galleries get the defaults of their library.
+	libraries, err := getLibrary(libraryID)
+	if err != nil {
+		return "", err
+	}
+	if len(libraries) == 0 {
+		return "", errors.New("library not found")
+	}
+
 	now := time.Now()
-	var stmt InsertStatement
+	archiveSize := int32(size)
+	images := int32(imageCount)
+	galleryModel := model.Gallery{
+		UUID:        galleryUUID.String(),
+		LibraryID:   libraryID,
+		ArchivePath: archivePath,
+		Title:       title,
+		Nsfw:        libraries[0].Nsfw,
+		Language:    libraries[0].Language,
+		ArchiveSize: &archiveSize,
+		ImageCount:  &images,
+		Volume:      volume,
+		Chapter:     chapter,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	columns := ColumnList{
+		Gallery.UUID,
+		Gallery.ArchivePath,
+		Gallery.Title,
+		Gallery.LibraryID,
+		Gallery.Nsfw,
+		Gallery.Language,
+		Gallery.ArchiveSize,
+		Gallery.ImageCount,
+		Gallery.Volume,
+		Gallery.Chapter,
+		Gallery.CreatedAt,
+		Gallery.UpdatedAt,
+	}
 	if series != "" {
-		stmt = Gallery.
-			INSERT(Gallery.UUID, Gallery.ArchivePath, Gallery.Title, Gallery.LibraryID, Gallery.Series, Gallery.ArchiveSize, Gallery.ImageCount, Gallery.CreatedAt, Gallery.UpdatedAt).
-			VALUES(galleryUUID.String(), archivePath, title, libraryID, series, size, imageCount, now, now).
-			RETURNING(Gallery.UUID)
-	} else {
-		stmt = Gallery.
-			INSERT(Gallery.UUID, Gallery.ArchivePath, Gallery.Title, Gallery.LibraryID, Gallery.ArchiveSize, Gallery.ImageCount, Gallery.CreatedAt, Gallery.UpdatedAt).
-			VALUES(galleryUUID.String(), archivePath, title, libraryID, size, imageCount, now, now).
-			RETURNING(Gallery.UUID)
+		galleryModel.Series = &series
+		columns = append(columns, Gallery.Series)
 	}
 
+	stmt := Gallery.INSERT(columns).MODEL(galleryModel).RETURNING(Gallery.UUID)
+
 	var galleries []model.Gallery
 	err = stmt.Query(db(), &galleries)
 	if err != nil {
@@ -116,25 +162,11 @@ func NewGallery(archivePath string, libraryID int32, title string, series string
 
 // UpdateGallery updates a gallery. It also adds tags and references if any.
 // If internalScan is true, the gallery is matched by its archive path, not UUID.
-func UpdateGallery(gallery model.Gallery, tags []model.Tag, reference model.Reference, internalScan bool) error {
+// The changes are stored as a revision of the given author.
+func UpdateGallery(gallery model.Gallery, tags []model.Tag, reference model.Reference, internalScan bool, author Author) error {
 	now := time.Now()
 
-	var tagIDs []int32
-	if tags != nil {
-		deleteStmt := GalleryTag.DELETE().WHERE(GalleryTag.GalleryUUID.EQ(String(gallery.UUID)))
-		_, err := deleteStmt.Exec(db())
-		if err != nil {
-			return err
-		}
-
-		if len(tags) > 0 {
-			if tagIDs, err = NewTags(tags); err != nil {
-				return err
-			}
-		}
-	}
-
-	prevGallery, err := GetGallery(&gallery.UUID, nil, &gallery.ArchivePath)
+	prevGallery, err := getGallery(&gallery.UUID, nil, &gallery.ArchivePath, false)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return errors.New("gallery not found")
@@ -154,10 +186,38 @@ func UpdateGallery(gallery model.Gallery, tags []model.Tag, reference model.Refe
 		}
 	}()
 
+	prevState, err := getGalleryState(tx, prevGallery.UUID)
+	if err != nil {
+		return err
+	}
+
+	// Fields locked by manual edits are never overwritten by the scans.
+	if internalScan {
+		locks, err := getLocks(tx, prevGallery.UUID)
+		if err != nil {
+			return err
+		}
+		skipLockedFields(locks, prevGallery.Gallery, &gallery, &tags, &reference)
+	}
+
+	var tagIDs []int32
+	if tags != nil {
+		deleteStmt := GalleryTag.DELETE().WHERE(GalleryTag.GalleryUUID.EQ(String(prevGallery.UUID)))
+		if _, err = deleteStmt.Exec(tx); err != nil {
+			return err
+		}
+
+		if len(tags) > 0 {
+			if tagIDs, err = newTags(tx, tags); err != nil {
+				return err
+			}
+		}
+	}
+
 	var updateGalleryStmt UpdateStatement
 
 	if internalScan {
-		galleryModel, galleryColumnList := ValidateGalleryInternal(prevGallery.Gallery, now)
+		galleryModel, galleryColumnList := ValidateGalleryInternal(prevGallery.Gallery, gallery, now)
 
 		updateGalleryStmt = Gallery.
 			UPDATE(galleryColumnList).
@@ -183,22 +243,12 @@ func UpdateGallery(gallery model.Gallery, tags []model.Tag, reference model.Refe
 	}
 
 	// Inserts gallery tag junctions in a loop if any
-	if len(tagIDs) > 0 {
-		for _, tagID := range tagIDs {
-			insertTagGalleryStmt := GalleryTag.
-				INSERT(GalleryTag.TagID, GalleryTag.GalleryUUID).
-				VALUES(tagID, galleries[0].UUID).
-				ON_CONFLICT(GalleryTag.TagID, GalleryTag.GalleryUUID).
-				DO_NOTHING()
-
-			if _, err = insertTagGalleryStmt.Exec(tx); err != nil {
-				return err
-			}
-		}
+	if err = insertGalleryTags(tx, galleries[0].UUID, tagIDs); err != nil {
+		return err
 	}
 
 	// Used for skipping title parsing if it's already done
-	titleHash := utils.HashStringSHA1(gallery.Title)
+	titleHash := utils.HashStringSHA1(cmp.Or(gallery.Title, prevGallery.Title))
 	reference.MetaTitleHash = &titleHash
 
 	// Inserts or updates reference
@@ -231,6 +281,26 @@ func UpdateGallery(gallery model.Gallery, tags []model.Tag, reference model.Refe
 		return err
 	}
 
+	newState, err := getGalleryState(tx, galleries[0].UUID)
+	if err != nil {
+		return err
+	}
+
+	changes := diffStates(prevState, newState)
+	if err = insertRevision(tx, galleries[0].UUID, author, changes, now); err != nil {
+		return err
+	}
+	if err = addChangedSeries(tx, changes, newState, now); err != nil {
+		return err
+	}
+
+	// Manually edited fields are locked.
+	if !internalScan {
+		if err = lockFields(tx, galleries[0].UUID, changedFields(changes), now); err != nil {
+			return err
+		}
+	}
+
 	// Commits transaction. Rollbacks on error.
 	committed = true
 	err = tx.Commit()
@@ -239,6 +309,11 @@ func UpdateGallery(gallery model.Gallery, tags []model.Tag, reference model.Refe
 
 // NewTags creates tags from the given list.
 func NewTags(tags []model.Tag) ([]int32, error) {
+	return newTags(db(), tags)
+}
+
+// newTags creates tags from the given list and returns their IDs. Existing tags are reused.
+func newTags(q qrm.DB, tags []model.Tag) ([]int32, error) {
 	var tagIDs []int32
 	for _, tag := range tags {
 		if tag.Namespace == "" || tag.Name == "" {
@@ -250,7 +325,7 @@ func NewTags(tags []model.Tag) ([]int32, error) {
 			WHERE(Tag.Namespace.EQ(String(tag.Namespace)).AND(Tag.Name.EQ(String(tag.Name))))
 
 		var existingTags []model.Tag
-		if err := selectStmt.Query(db(), &existingTags); err != nil {
+		if err := selectStmt.Query(q, &existingTags); err != nil {
 			log.Z.Debug("could not select tags, aborting", zap.String("err", err.Error()))
 			return nil, err
 		}
@@ -262,7 +337,7 @@ func NewTags(tags []model.Tag) ([]int32, error) {
 
 		insertStmt := Tag.INSERT(Tag.Namespace, Tag.Name).VALUES(tag.Namespace, tag.Name).RETURNING(Tag.ID)
 		var insertedTags []model.Tag
-		if err := insertStmt.Query(db(), &insertedTags); err != nil {
+		if err := insertStmt.Query(q, &insertedTags); err != nil {
 			log.Z.Debug("could not insert tags, aborting", zap.String("err", err.Error()))
 			return nil, err
 		}
@@ -273,6 +348,23 @@ func NewTags(tags []model.Tag) ([]int32, error) {
 	return tagIDs, nil
 }
 
+// insertGalleryTags links the tags to the gallery.
+func insertGalleryTags(q qrm.Executable, galleryUUID string, tagIDs []int32) error {
+	for _, tagID := range tagIDs {
+		insertTagGalleryStmt := GalleryTag.
+			INSERT(GalleryTag.TagID, GalleryTag.GalleryUUID).
+			VALUES(tagID, galleryUUID).
+			ON_CONFLICT(GalleryTag.TagID, GalleryTag.GalleryUUID).
+			DO_NOTHING()
+
+		if _, err := insertTagGalleryStmt.Exec(q); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // NewGalleryPref creates initializes user preferences for a gallery.
 func NewGalleryPref(galleryUUID string, userUUID string) error {
 	stmt := GalleryPref.
@@ -332,9 +424,20 @@ func SetPageThumbnails(uuid string, pageThumbnails int32) error {
 	return err
 }
 
+// SetNumbering saves the volume and chapter numbers of the gallery.
+func SetNumbering(uuid string, volume *float64, chapter *float64) error {
+	galleryModel := model.Gallery{Volume: volume, Chapter: chapter, UpdatedAt: time.Now()}
+	stmt := Gallery.UPDATE(Gallery.Volume, Gallery.Chapter, Gallery.UpdatedAt).
+		MODEL(galleryModel).
+		WHERE(Gallery.UUID.EQ(String(uuid)))
+
+	_, err := stmt.Exec(db())
+	return err
+}
+
 func constructGalleryFilters(filters Filters, hidden bool, userUUID *string) BoolExpression {
-	// Constructing conditions
-	conditions := Bool(true)
+	// Constructing conditions. Galleries in the trash and in libraries the user may not view are never listed.
+	conditions := Gallery.Deleted.IS_NOT_TRUE().AND(accessCondition(userUUID))
 
 	if filters.Tags != nil {
 		namespaces := make([]Expression, len(filters.Tags))
@@ -458,6 +561,8 @@ func GetGalleries(filters Filters, hidden bool, userUUID *string) ([]CombinedMet
 			filtersStmt = filtersStmt.ORDER_BY(Gallery.TitleNative.DESC())
 		case UpdatedAt:
 			filtersStmt = filtersStmt.ORDER_BY(Gallery.UpdatedAt.DESC())
+		case Reading:
+			filtersStmt = filtersStmt.ORDER_BY(readingOrder(true, nil)...)
 		default:
 			filtersStmt = filtersStmt.ORDER_BY(Gallery.Title.DESC())
 		}
@@ -467,6 +572,8 @@ func GetGalleries(filters Filters, hidden bool, userUUID *string) ([]CombinedMet
 			filtersStmt = filtersStmt.ORDER_BY(Gallery.TitleNative.ASC())
 		case UpdatedAt:
 			filtersStmt = filtersStmt.ORDER_BY(Gallery.UpdatedAt.ASC())
+		case Reading:
+			filtersStmt = filtersStmt.ORDER_BY(readingOrder(false, nil)...)
 		default:
 			filtersStmt = filtersStmt.ORDER_BY(Gallery.Title.ASC())
 
@@ -522,6 +629,7 @@ func GetGalleries(filters Filters, hidden bool, userUUID *string) ([]CombinedMet
 			Tag.Name,
 			Reference.ExhGid,
 			Reference.ExhToken,
+			Reference.AnilistID,
 			Reference.Urls,
 			GalleryPref.FavoriteGroup,
 			GalleryPref.Progress,
@@ -536,10 +644,16 @@ func GetGalleries(filters Filters, hidden bool, userUUID *string) ([]CombinedMet
 			Tag.Name,
 			Reference.ExhGid,
 			Reference.ExhToken,
+			Reference.AnilistID,
 			Reference.Urls,
 		).FROM(joins)
 	}
 
+	// The order of the subquery is not kept by the joins.
+	if filters.SortBy == Reading {
+		galleriesStmt = galleriesStmt.ORDER_BY(readingOrder(filters.Order == Desc, filtersStmt.AsTable("galleries"))...)
+	}
+
 	if userUUID != nil && filters.SortBy == Progress {
 		if filters.Order == Desc {
 			galleriesStmt = galleriesStmt.ORDER_BY(GalleryPref.Progress.DESC())
@@ -565,8 +679,44 @@ func GetGalleries(filters Filters, hidden bool, userUUID *string) ([]CombinedMet
 	return galleries, totalGalleryCount, nil
 }
 
+// readingOrder orders the galleries by their order in the series, then by their volume and chapter numbers and
+// lastly by title. Galleries without an order or numbers come last either way. If given, the columns are taken from
+// the galleries subquery.
+func readingOrder(desc bool, galleries SelectTable) []OrderByClause {
+	columns := []Expression{Gallery.SeriesOrder, Gallery.Volume, Gallery.Chapter}
+	title := Expression(Gallery.Title)
+	if galleries != nil {
+		columns = []Expression{
+			Gallery.SeriesOrder.From(galleries),
+			Gallery.Volume.From(galleries),
+			Gallery.Chapter.From(galleries),
+		}
+		title = Gallery.Title.From(galleries)
+	}
+
+	direction := func(column Expression) OrderByClause {
+		if desc {
+			return column.DESC()
+		}
+		return column.ASC()
+	}
+
+	var clauses []OrderByClause
+	for _, column := range columns {
+		clauses = append(clauses, column.IS_NULL().ASC(), direction(column))
+	}
+
+	return append(clauses, direction(title))
+}
+
 // GetGallery returns a gallery based on the given UUID. If no UUID is given, a random gallery is returned.
+// Only galleries the user, or an anonymous user if nil, may view are returned.
 func GetGallery(galleryUUID *string, userUUID *string, archivePath *string) (CombinedMetadata, error) {
+	return getGallery(galleryUUID, userUUID, archivePath, true)
+}
+
+// getGallery returns a gallery like GetGallery. Access of the user is checked only if checkAccess is true.
+func getGallery(galleryUUID *string, userUUID *string, archivePath *string, checkAccess bool) (CombinedMetadata, error) {
 	if (galleryUUID == nil || *galleryUUID == "") && (archivePath == nil || *archivePath == "") {
 		return CombinedMetadata{}, errors.New("either galleryUUID or archivePath must be provided")
 	}
@@ -590,8 +740,27 @@ func GetGallery(galleryUUID *string, userUUID *string, archivePath *string) (Com
 		galleryUUID = &galleries[0].UUID
 	}
 
-	// If userUUID is provided, a new user gallery entry is created.
+	conditions := Gallery.Deleted.IS_NOT_TRUE()
+	if checkAccess {
+		conditions = conditions.AND(accessCondition(userUUID))
+	}
+
+	// If userUUID is provided, a new user gallery entry is created. The access is checked first to not leave entries
+	// for galleries the user may not view.
 	if userUUID != nil && galleryUUID != nil {
+		var galleries []model.Gallery
+		accessStmt := SELECT(Gallery.UUID).FROM(Gallery).WHERE(Gallery.UUID.EQ(String(*galleryUUID)).AND(conditions))
+		if err := accessStmt.Query(db(), &galleries); err != nil {
+			log.Z.Debug("could not check gallery access",
+				zap.Stringp("gUUID", galleryUUID),
+				zap.String("err", err.Error()))
+			return CombinedMetadata{}, err
+		}
+		if len(galleries) == 0 {
+			log.Z.Debug("no gallery found", zap.Stringp("uuid", galleryUUID))
+			return CombinedMetadata{}, sql.ErrNoRows
+		}
+
 		if err := NewGalleryPref(*galleryUUID, *userUUID); err != nil {
 			log.Z.Debug("could not add user gallery entry",
 				zap.Stringp("gUUID", galleryUUID),
@@ -621,6 +790,7 @@ func GetGallery(galleryUUID *string, userUUID *string, archivePath *string) (Com
 			Reference.MetaInternal,
 			Reference.ExhGid,
 			Reference.ExhToken,
+			Reference.AnilistID,
 			Reference.Urls,
 			GalleryPref.FavoriteGroup,
 			GalleryPref.Progress,
@@ -636,15 +806,18 @@ func GetGallery(galleryUUID *string, userUUID *string, archivePath *string) (Com
 			Reference.MetaInternal,
 			Reference.ExhGid,
 			Reference.ExhToken,
+			Reference.AnilistID,
 			Reference.Urls,
 			Library.Path,
 		).FROM(joins)
 	}
 
 	if galleryUUID != nil {
-		stmt = stmt.WHERE(Gallery.UUID.EQ(String(*galleryUUID)))
+		stmt = stmt.WHERE(Gallery.UUID.EQ(String(*galleryUUID)).AND(conditions))
 	} else {
-		stmt = stmt.WHERE(Gallery.UUID.IN(Raw("(SELECT gallery.uuid FROM gallery ORDER BY RANDOM() LIMIT 1)")))
+		stmt = stmt.WHERE(Gallery.UUID.IN(
+			SELECT(Gallery.UUID).FROM(Gallery).WHERE(conditions).ORDER_BY(Raw("RANDOM()")).LIMIT(1),
+		))
 	}
 
 	var galleries []CombinedMetadata
@@ -675,11 +848,16 @@ func GetGallery(galleryUUID *string, userUUID *string, archivePath *string) (Com
 }
 
 func GetReference(galleryUUID string) (model.Reference, error) {
+	return getReference(db(), galleryUUID)
+}
+
+func getReference(q qrm.Queryable, galleryUUID string) (model.Reference, error) {
 	stmt := SELECT(Reference.AllColumns).
-		FROM(Gallery.LEFT_JOIN(Reference, Reference.GalleryUUID.EQ(String(galleryUUID))))
+		FROM(Reference).
+		WHERE(Reference.GalleryUUID.EQ(String(galleryUUID)))
 
 	var references []model.Reference
-	err := stmt.Query(db(), &references)
+	err := stmt.Query(q, &references)
 	if err != nil || len(references) == 0 {
 		return model.Reference{}, err
 	}
@@ -688,13 +866,14 @@ func GetReference(galleryUUID string) (model.Reference, error) {
 }
 
 func IsLTR(galleryUUID string) (bool, error) {
-	stmt := SELECT(Gallery.UUID.AS("UUID"), Gallery.Language.AS("Language")).
-		FROM(Gallery).
+	stmt := SELECT(Gallery.UUID.AS("UUID"), Gallery.Language.AS("Language"), Library.Ltr.AS("LTR")).
+		FROM(Gallery.LEFT_JOIN(Library, Library.ID.EQ(Gallery.LibraryID))).
 		WHERE(Gallery.UUID.EQ(String(galleryUUID)))
 
 	var galleries []struct {
 		UUID     string
 		Language *string
+		LTR      *bool
 	}
 
 	err := stmt.Query(db(), &galleries)
@@ -702,24 +881,22 @@ func IsLTR(galleryUUID string) (bool, error) {
 		return config.Options.GalleryOptions.LTR, err
 	}
 
+	// The language of the gallery decides the direction. Otherwise, the library and then the global default is used.
 	if galleries[0].Language == nil || *galleries[0].Language == "" {
+		if galleries[0].LTR != nil {
+			return *galleries[0].LTR, nil
+		}
 		return config.Options.GalleryOptions.LTR, nil
 	}
 
 	return slices.Contains(constants.LTRLanguages, strings.ToLower(*galleries[0].Language)), nil
 }
 
-// GetTags returns all tags.
+// GetTags returns the tags of the gallery.
 func GetTags(galleryUUID string, mapped bool) (MappedTags, []model.Tag, error) {
-	var stmt SelectStatement
-	if galleryUUID != "" {
-		stmt = SELECT(Tag.Namespace, Tag.Name).FROM(Gallery.
-			LEFT_JOIN(GalleryTag, GalleryTag.GalleryUUID.EQ(String(galleryUUID))).
-			LEFT_JOIN(Tag, Tag.ID.EQ(GalleryTag.TagID)),
-		)
-	} else {
-		stmt = SELECT(Tag.Namespace, Tag.Name).FROM(Tag)
-	}
+	stmt := SELECT(Tag.Namespace, Tag.Name).
+		FROM(GalleryTag.INNER_JOIN(Tag, Tag.ID.EQ(GalleryTag.TagID))).
+		WHERE(GalleryTag.GalleryUUID.EQ(String(galleryUUID)))
 
 	var tags []model.Tag
 	err := stmt.Query(db(), &tags)
@@ -731,32 +908,52 @@ func GetTags(galleryUUID string, mapped bool) (MappedTags, []model.Tag, error) {
 		return MappedTags{Data: map[string][]string{}, Count: 0}, tags, err
 	}
 
+	return mapTags(tags), nil, err
+}
+
+// GetAllTags returns all tags. Users other than admins only get the tags of the galleries they may view.
+func GetAllTags(userUUID *string) (MappedTags, error) {
+	currentViewer, err := getViewer(userUUID)
+	if err != nil {
+		return MappedTags{Data: map[string][]string{}, Count: 0}, err
+	}
+
+	stmt := SELECT(Tag.Namespace, Tag.Name).FROM(Tag)
+	if !currentViewer.isAdmin {
+		stmt = SELECT(Tag.Namespace, Tag.Name).DISTINCT().
+			FROM(Tag.
+				INNER_JOIN(GalleryTag, GalleryTag.TagID.EQ(Tag.ID)).
+				INNER_JOIN(Gallery, Gallery.UUID.EQ(GalleryTag.GalleryUUID))).
+			WHERE(Gallery.Deleted.IS_NOT_TRUE().AND(accessCondition(userUUID)))
+	}
+
+	var tags []model.Tag
+	if err = stmt.Query(db(), &tags); err != nil {
+		return MappedTags{Data: map[string][]string{}, Count: 0}, err
+	}
+
+	return mapTags(tags), nil
+}
+
+func mapTags(tags []model.Tag) MappedTags {
 	tagMap := map[string][]string{}
 	for _, tag := range tags {
 		tagMap[tag.Namespace] = append(tagMap[tag.Namespace], tag.Name)
 	}
 
-	return MappedTags{Data: tagMap, Count: len(tags)}, nil, err
+	return MappedTags{Data: tagMap, Count: len(tags)}
 }
 
-// GetCategories returns all public categories.
-func GetCategories() ([]string, error) {
-	stmt := SELECT(Gallery.Category).DISTINCT().FROM(Gallery.Table)
+// GetCategories returns the categories of the galleries the user may view.
+func GetCategories(userUUID *string) ([]string, error) {
+	stmt := SELECT(Gallery.Category).DISTINCT().FROM(Gallery.Table).
+		WHERE(Gallery.Deleted.IS_NOT_TRUE().AND(accessCondition(userUUID)))
 	var categories []string
 
 	err := stmt.Query(db(), &categories)
 	return categories, err
 }
 
-// GetSeries returns all series.
-func GetSeries() ([]string, error) {
-	stmt := SELECT(Gallery.Series).DISTINCT().FROM(Gallery.Table)
-	var series []string
-
-	err := stmt.Query(db(), &series)
-	return series, err
-}
-
 // TitleHashMatch returns true if the title hash of the gallery matches the stored hash.
 func TitleHashMatch(galleryUUID string) bool {
 	stmt := SELECT(Gallery.UUID.AS("UUID"), Gallery.Title.AS("Title"), Reference.MetaTitleHash.AS("MetaTitleHash")).
@@ -809,7 +1006,7 @@ func NeedsUpdate(archivePath string, updatedAt time.Time) (bool, string) {
 
 // ArchivePathFound returns true if the given archive path is already in the database.
 func ArchivePathFound(archivePath string) []model.Gallery {
-	stmt := SELECT(Gallery.UUID, Gallery.ArchivePath).
+	stmt := SELECT(Gallery.UUID, Gallery.ArchivePath, Gallery.Volume, Gallery.Chapter).
 		FROM(Gallery.Table).
 		WHERE(Gallery.ArchivePath.EQ(String(archivePath)))
 
@@ -866,7 +1063,9 @@ func CountAllImages(skipWithPageThumbnails bool) (int, int, error) {
 		FROM(Gallery.Table)
 
 	if skipWithPageThumbnails {
-		stmt = stmt.WHERE(Gallery.PageThumbnails.GT(Int32(0)))
+		stmt = stmt.WHERE(Gallery.PageThumbnails.GT(Int32(0)).AND(Gallery.Deleted.IS_NOT_TRUE()))
+	} else {
+		stmt = stmt.WHERE(Gallery.Deleted.IS_NOT_TRUE())
 	}
 
 	var counts struct {
@@ -881,3 +1080,28 @@ func CountAllImages(skipWithPageThumbnails bool) (int, int, error) {
 
 	return counts.CoverCount, counts.ImageCount, nil
 }
+
+// Totals holds the numbers of galleries outside the trash, tags and users.
+type Totals struct {
+	Galleries int
+	Tags      int
+	Users     int
+}
+
+// GetTotals returns the numbers of galleries outside the trash, tags and users.
+func GetTotals() (Totals, error) {
+	stmt := SELECT(
+		Gallery.SELECT(COUNT(Gallery.UUID)).WHERE(Gallery.Deleted.IS_NOT_TRUE()).AS("Galleries"),
+		Tag.SELECT(COUNT(Tag.ID)).AS("Tags"),
+		User.SELECT(COUNT(User.UUID)).AS("Users"),
+	)
+
+	var totals struct {
+		Galleries int
+		Tags      int
+		Users     int
+	}
+	err := stmt.Query(db(), &totals)
+
+	return Totals(totals), err
+}
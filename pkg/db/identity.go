@@ -0,0 +1,138 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	. "github.com/Mangatsu/server/pkg/types/sqlite/table"
+	"github.com/Mangatsu/server/pkg/utils"
+	"github.com/go-jet/jet/v2/qrm"
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/google/uuid"
+)
+
+var invalidUsernameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// ProvisionExternalUser returns the UUID of the user linked to the identity of an external provider, such as
+// OpenID Connect. The user is created on the first login with a unique username based on the given one and an
+// unusable password. The role is synced on every login, except for super admins.
+func ProvisionExternalUser(issuer string, subject string, username string, role Role) (string, Role, error) {
+	tx, err := db().Begin()
+	if err != nil {
+		return "", NoRole, err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			rollbackTx(tx)
+		}
+	}()
+
+	identityCondition := UserIdentity.Issuer.EQ(String(issuer)).AND(UserIdentity.Subject.EQ(String(subject)))
+	stmt := SELECT(User.UUID, User.Role).
+		FROM(UserIdentity.INNER_JOIN(User, User.UUID.EQ(UserIdentity.UserUUID))).
+		WHERE(identityCondition)
+
+	var users []model.User
+	if err = stmt.Query(tx, &users); err != nil {
+		return "", NoRole, err
+	}
+
+	if len(users) > 0 {
+		userUUID := users[0].UUID
+		currentRole := Role(users[0].Role)
+		if currentRole != role && currentRole != SuperAdmin {
+			updateStmt := User.UPDATE(User.Role, User.UpdatedAt).
+				SET(Int32(int32(role)), time.Now()).
+				WHERE(User.UUID.EQ(String(userUUID)))
+			if _, err = updateStmt.Exec(tx); err != nil {
+				return "", NoRole, err
+			}
+
+			// Same as when an admin changes the role.
+			if _, err = Session.DELETE().WHERE(Session.UserUUID.EQ(String(userUUID))).Exec(tx); err != nil {
+				return "", NoRole, err
+			}
+			currentRole = role
+		}
+
+		committed = true
+		return userUUID, currentRole, tx.Commit()
+	}
+
+	// The linked user may have been removed.
+	if _, err = UserIdentity.DELETE().WHERE(identityCondition).Exec(tx); err != nil {
+		return "", NoRole, err
+	}
+
+	username, err = uniqueUsername(tx, username)
+	if err != nil {
+		return "", NoRole, err
+	}
+
+	x := make([]byte, 32)
+	if _, err = rand.Read(x); err != nil {
+		return "", NoRole, err
+	}
+	hashSalt, err := utils.DefaultArgon2idHash().GenerateHash([]byte(base64.RawURLEncoding.EncodeToString(x)), nil)
+	if err != nil {
+		return "", NoRole, err
+	}
+
+	userUUID, err := uuid.NewRandom()
+	if err != nil {
+		return "", NoRole, err
+	}
+
+	now := time.Now()
+	insertUser := User.
+		INSERT(User.UUID, User.Username, User.Password, User.Salt, User.Role, User.CreatedAt, User.UpdatedAt).
+		VALUES(userUUID.String(), username, hashSalt.Hash, hashSalt.Salt, role, now, now)
+	if _, err = insertUser.Exec(tx); err != nil {
+		return "", NoRole, err
+	}
+
+	insertIdentity := UserIdentity.
+		INSERT(UserIdentity.Issuer, UserIdentity.Subject, UserIdentity.UserUUID, UserIdentity.CreatedAt).
+		VALUES(issuer, subject, userUUID.String(), now)
+	if _, err = insertIdentity.Exec(tx); err != nil {
+		return "", NoRole, err
+	}
+
+	committed = true
+	return userUUID.String(), role, tx.Commit()
+}
+
+// uniqueUsername returns a valid username that is not in use, based on the given one.
+func uniqueUsername(q qrm.Queryable, username string) (string, error) {
+	base := invalidUsernameChars.ReplaceAllString(username, "_")
+	if len(base) > 28 {
+		base = base[:28]
+	}
+	if len(base) < 2 {
+		base = "user"
+	}
+
+	for i := 1; i < 1000; i++ {
+		candidate := base
+		if i > 1 {
+			candidate = base + "-" + strconv.Itoa(i)
+		}
+
+		var users []model.User
+		if err := SELECT(User.UUID).FROM(User).WHERE(User.Username.EQ(String(candidate))).Query(q, &users); err != nil {
+			return "", err
+		}
+		if len(users) == 0 {
+			return candidate, nil
+		}
+	}
+
+	return "", errors.New("no free username found for " + username)
+}
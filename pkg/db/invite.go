@@ -0,0 +1,166 @@
+package db
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/log"
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	. "github.com/Mangatsu/server/pkg/types/sqlite/table"
+	. "github.com/go-jet/jet/v2/sqlite"
+	"go.uber.org/zap"
+)
+
+var ErrInvalidInvite = errors.New("invite code is invalid, expired or used up")
+
+// NewInvite creates an invite code giving the role to the users registering with it. The plain code is returned
+// only once, as only its SHA-256 hash is stored.
+func NewInvite(role Role, maxUses int32, expiresAt time.Time, createdBy string) (string, model.Invite, error) {
+	x := make([]byte, 18)
+	if _, err := rand.Read(x); err != nil {
+		return "", model.Invite{}, err
+	}
+	plainCode := base64.RawURLEncoding.EncodeToString(x)
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", model.Invite{}, err
+	}
+
+	invite := model.Invite{
+		ID:        hex.EncodeToString(id),
+		CodeHash:  hashInviteCode(plainCode),
+		Role:      int32(role),
+		MaxUses:   maxUses,
+		Uses:      0,
+		ExpiresAt: expiresAt,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+
+	if _, err := Invite.INSERT(Invite.AllColumns).MODEL(invite).Exec(db()); err != nil {
+		return "", model.Invite{}, err
+	}
+
+	invite.CodeHash = ""
+	return plainCode, invite, nil
+}
+
+// GetInvites returns all invites. Never returns the hashes.
+func GetInvites() ([]model.Invite, error) {
+	stmt := SELECT(
+		Invite.ID,
+		Invite.Role,
+		Invite.MaxUses,
+		Invite.Uses,
+		Invite.ExpiresAt,
+		Invite.CreatedBy,
+		Invite.CreatedAt,
+	).FROM(
+		Invite.Table,
+	).ORDER_BY(
+		Invite.CreatedAt.DESC(),
+	)
+
+	var invites []model.Invite
+	err := stmt.Query(db(), &invites)
+	return invites, err
+}
+
+// DeleteInvite revokes an invite.
+func DeleteInvite(id string) error {
+	res, err := Invite.DELETE().WHERE(Invite.ID.EQ(String(id))).Exec(db())
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// RegisterWithInvite registers a user with the role of the invite, and uses it up once. The invite is not
+// used if the registration fails.
+func RegisterWithInvite(username string, password string, code string) (Role, error) {
+	stmt := SELECT(Invite.ID, Invite.Role, Invite.MaxUses, Invite.Uses, Invite.ExpiresAt).
+		FROM(Invite).
+		WHERE(Invite.CodeHash.EQ(String(hashInviteCode(code))))
+
+	var invites []model.Invite
+	if err := stmt.Query(db(), &invites); err != nil {
+		return NoRole, err
+	}
+	if len(invites) == 0 || invites[0].Uses >= invites[0].MaxUses || time.Now().After(invites[0].ExpiresAt) {
+		return NoRole, ErrInvalidInvite
+	}
+	invite := invites[0]
+
+	tx, err := db().Begin()
+	if err != nil {
+		return NoRole, err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			rollbackTx(tx)
+		}
+	}()
+
+	// Only as many concurrent registrations as there are uses left succeed.
+	useStmt := Invite.UPDATE(Invite.Uses).
+		SET(Invite.Uses.ADD(Int32(1))).
+		WHERE(Invite.ID.EQ(String(invite.ID)).AND(Invite.Uses.LT(Invite.MaxUses)))
+	res, err := useStmt.Exec(tx)
+	if err != nil {
+		return NoRole, err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return NoRole, ErrInvalidInvite
+	}
+
+	role := Role(invite.Role)
+	if err = registerUser(tx, username, password, role, false); err != nil {
+		return NoRole, err
+	}
+
+	committed = true
+	return role, tx.Commit()
+}
+
+// PruneInvites removes expired invites. Used up invites are kept until then to be seen by admins.
+func PruneInvites() {
+	var invites []model.Invite
+	stmt := SELECT(Invite.ID, Invite.ExpiresAt).FROM(Invite)
+	if err := stmt.Query(db(), &invites); err != nil {
+		log.Z.Error("failed to prune invites", zap.String("err", err.Error()))
+		return
+	}
+
+	// Expiry is compared here, as the timestamps are stored with the time zone offset.
+	var prunedIDs []Expression
+	now := time.Now()
+	for _, invite := range invites {
+		if now.After(invite.ExpiresAt) {
+			prunedIDs = append(prunedIDs, String(invite.ID))
+		}
+	}
+	if len(prunedIDs) == 0 {
+		return
+	}
+
+	if _, err := Invite.DELETE().WHERE(Invite.ID.IN(prunedIDs...)).Exec(db()); err != nil {
+		log.Z.Error("failed to prune invites", zap.String("err", err.Error()))
+	}
+}
+
+func hashInviteCode(code string) string {
+	hash := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(hash[:])
+}
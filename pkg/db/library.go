@@ -1,6 +1,10 @@
 package db
 
 import (
+	"database/sql"
+	"errors"
+	"time"
+
 	"github.com/Mangatsu/server/internal/config"
 	"github.com/Mangatsu/server/pkg/log"
 	"github.com/Mangatsu/server/pkg/types/sqlite/model"
@@ -9,32 +13,57 @@ import (
 	"go.uber.org/zap"
 )
 
+// ErrLibraryExists is returned when another library already uses the path.
+var ErrLibraryExists = errors.New("a library with the same path already exists")
+
 type CombinedLibrary struct {
 	model.Library
 	Galleries []model.Gallery
 }
 
+// LibraryEdit describes changes to a library. Nil fields are left untouched. Empty name and language clear the field.
+type LibraryEdit struct {
+	Path     *string
+	Layout   *string
+	Name     *string
+	Nsfw     *bool
+	Language *string
+	// LTR is the reading direction of the library. ClearLTR resets it to the global default.
+	LTR      *bool
+	ClearLTR bool
+}
+
+// StorePaths seeds the libraries given in the environment. Libraries already in the database are left untouched,
+// as they may have been changed through the API. Paths are matched as IDs of libraries created through the API may
+// overlap with the given ones.
 func StorePaths(givenLibraries []config.Library) error {
 	for _, library := range givenLibraries {
-		libraries, err := getLibrary(library.ID, "")
+		existing, err := getLibraryByPath(library.Path)
 		if err != nil {
-			log.Z.Debug("failed to get libraries when storing paths",
-				zap.Int32("id", library.ID),
-				zap.String("err", err.Error()))
+			return err
+		}
+		if len(existing) > 0 {
 			continue
 		}
 
-		if len(libraries) == 0 {
-			if err := newLibrary(library.ID, library.Path, library.Layout); err != nil {
+		id := library.ID
+		taken, err := getLibrary(id)
+		if err != nil {
+			return err
+		}
+		if len(taken) > 0 {
+			if id, err = nextLibraryID(); err != nil {
 				return err
 			}
-			continue
+			log.Z.Warn("library ID given in the environment is taken by another path, seeding it with a new ID",
+				zap.Int32("givenID", library.ID),
+				zap.Int32("id", id),
+				zap.String("path", library.Path),
+				zap.String("takenBy", taken[0].Path))
 		}
 
-		if libraries[0].Path != library.Path || libraries[0].Layout != library.Layout {
-			if err := updateLibrary(library.ID, library.Path, library.Layout); err != nil {
-				return err
-			}
+		if err := newLibrary(id, library.Path, library.Layout); err != nil {
+			return err
 		}
 	}
 
@@ -42,7 +71,7 @@ func StorePaths(givenLibraries []config.Library) error {
 }
 
 func GetOnlyLibraries() ([]model.Library, error) {
-	stmt := SELECT(Library.AllColumns).FROM(Library.Table)
+	stmt := SELECT(Library.AllColumns).FROM(Library.Table).WHERE(Library.Deleted.IS_NOT_TRUE())
 	var libraries []model.Library
 
 	err := stmt.Query(db(), &libraries)
@@ -51,32 +80,207 @@ func GetOnlyLibraries() ([]model.Library, error) {
 
 func GetLibraries() ([]CombinedLibrary, error) {
 	stmt := SELECT(Library.AllColumns, Gallery.AllColumns).
-		FROM(Library.LEFT_JOIN(Gallery, Gallery.LibraryID.EQ(Library.ID)))
+		FROM(Library.LEFT_JOIN(Gallery, Gallery.LibraryID.EQ(Library.ID).AND(Gallery.Deleted.IS_NOT_TRUE()))).
+		WHERE(Library.Deleted.IS_NOT_TRUE())
 	var libraries []CombinedLibrary
 
 	err := stmt.Query(db(), &libraries)
 	return libraries, err
 }
 
-// getLibrary returns the library from the database based on the ID or path.
-func getLibrary(id int32, path string) ([]model.Library, error) {
-	stmt := SELECT(
-		Library.AllColumns,
-	).FROM(
-		Library.Table,
-	)
+// GetLibrary returns the library with the given ID. Removed libraries are not returned.
+func GetLibrary(id int32) (model.Library, error) {
+	libraries, err := getLibrary(id)
+	if err != nil {
+		return model.Library{}, err
+	}
+	if len(libraries) == 0 || libraries[0].Deleted {
+		return model.Library{}, sql.ErrNoRows
+	}
+
+	return libraries[0], nil
+}
+
+// NewLibrary creates a new library. If a removed library has the same path, it's reactivated with the new options
+// instead. Its galleries stay in the trash until restored.
+func NewLibrary(library model.Library) (model.Library, error) {
+	library.Name = SanitizeString(library.Name)
+	library.Language = SanitizeString(library.Language)
+	library.Deleted = false
+
+	columns := ColumnList{Library.Path, Library.Layout, Library.Name, Library.Nsfw, Library.Language, Library.Ltr, Library.Deleted}
+
+	existing, err := getLibraryByPath(library.Path)
+	if err != nil {
+		return model.Library{}, err
+	}
+
+	if len(existing) > 0 {
+		if !existing[0].Deleted {
+			return model.Library{}, ErrLibraryExists
+		}
+
+		library.ID = existing[0].ID
+		updateStmt := Library.UPDATE(columns).MODEL(library).WHERE(Library.ID.EQ(Int32(library.ID)))
+		if _, err := updateStmt.Exec(db()); err != nil {
+			return model.Library{}, err
+		}
+
+		return library, nil
+	}
+
+	if library.ID, err = nextLibraryID(); err != nil {
+		return model.Library{}, err
+	}
+
+	insertStmt := Library.INSERT(append(ColumnList{Library.ID}, columns...)).MODEL(library)
+	if _, err := insertStmt.Exec(db()); err != nil {
+		return model.Library{}, err
+	}
+
+	return library, nil
+}
+
+// UpdateLibrary changes the options of the library. Galleries keep their paths relative to the library,
+// so changing the path of a library moves all of its galleries with it.
+func UpdateLibrary(id int32, edit LibraryEdit) (model.Library, error) {
+	library, err := GetLibrary(id)
+	if err != nil {
+		return model.Library{}, err
+	}
+
+	columns := ColumnList{}
+	if edit.Path != nil {
+		library.Path = *edit.Path
+		columns = append(columns, Library.Path)
+	}
+	if edit.Layout != nil {
+		library.Layout = *edit.Layout
+		columns = append(columns, Library.Layout)
+	}
+	if edit.Name != nil {
+		library.Name = SanitizeString(edit.Name)
+		columns = append(columns, Library.Name)
+	}
+	if edit.Nsfw != nil {
+		library.Nsfw = *edit.Nsfw
+		columns = append(columns, Library.Nsfw)
+	}
+	if edit.Language != nil {
+		library.Language = SanitizeString(edit.Language)
+		columns = append(columns, Library.Language)
+	}
+	if edit.LTR != nil || edit.ClearLTR {
+		library.Ltr = edit.LTR
+		columns = append(columns, Library.Ltr)
+	}
+
+	if len(columns) == 0 {
+		return library, nil
+	}
+
+	if edit.Path != nil {
+		existing, err := getLibraryByPath(library.Path)
+		if err != nil {
+			return model.Library{}, err
+		}
+		for _, other := range existing {
+			if other.ID != id {
+				return model.Library{}, ErrLibraryExists
+			}
+		}
+	}
+
+	stmt := Library.UPDATE(columns).MODEL(library).WHERE(Library.ID.EQ(Int32(id)))
+	if _, err = stmt.Exec(db()); err != nil {
+		return model.Library{}, err
+	}
+
+	return library, nil
+}
+
+// DeleteLibrary removes the library and moves all of its galleries to the trash. Archives are left in place.
+func DeleteLibrary(id int32, author Author) error {
+	if _, err := GetLibrary(id); err != nil {
+		return err
+	}
+
+	tx, err := db().Begin()
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			rollbackTx(tx)
+		}
+	}()
+
+	now := time.Now()
+	deleteGalleriesStmt := Gallery.UPDATE(Gallery.Deleted, Gallery.DeletedAt, Gallery.UpdatedAt).
+		MODEL(model.Gallery{Deleted: true, DeletedAt: &now, UpdatedAt: now}).
+		WHERE(Gallery.LibraryID.EQ(Int32(id)).AND(Gallery.Deleted.IS_NOT_TRUE())).
+		RETURNING(Gallery.UUID)
+
+	var galleries []model.Gallery
+	if err = deleteGalleriesStmt.Query(tx, &galleries); err != nil {
+		return err
+	}
+
+	changes := []FieldChange{{Field: "Deleted", Old: false, New: true}}
+	for _, gallery := range galleries {
+		if err = insertRevision(tx, gallery.UUID, author, changes, now); err != nil {
+			return err
+		}
+	}
+
+	deleteLibraryStmt := Library.UPDATE(Library.Deleted).SET(Bool(true)).WHERE(Library.ID.EQ(Int32(id)))
+	if _, err = deleteLibraryStmt.Exec(tx); err != nil {
+		return err
+	}
 
-	if path == "" {
-		stmt = stmt.WHERE(Library.ID.EQ(Int32(id)))
-	} else {
-		stmt = stmt.WHERE(Library.ID.EQ(Int32(id)).OR(Library.Path.EQ(String(path))))
+	committed = true
+	if err = tx.Commit(); err != nil {
+		return err
 	}
 
+	log.Z.Info("library removed", zap.Int32("id", id), zap.Int("galleries", len(galleries)))
+
+	return nil
+}
+
+// getLibrary returns the library from the database based on the ID, including removed ones.
+func getLibrary(id int32) ([]model.Library, error) {
+	stmt := SELECT(Library.AllColumns).FROM(Library.Table).WHERE(Library.ID.EQ(Int32(id)))
+
+	var libraries []model.Library
+	err := stmt.Query(db(), &libraries)
+	return libraries, err
+}
+
+// getLibraryByPath returns the library from the database based on the path, including removed ones.
+func getLibraryByPath(path string) ([]model.Library, error) {
+	stmt := SELECT(Library.AllColumns).FROM(Library.Table).WHERE(Library.Path.EQ(String(path)))
+
 	var libraries []model.Library
 	err := stmt.Query(db(), &libraries)
 	return libraries, err
 }
 
+// nextLibraryID returns the ID after the largest one. IDs given in the environment are kept, so new libraries
+// continue from them.
+func nextLibraryID() (int32, error) {
+	var maxID []struct{ MaxID *int32 }
+	if err := SELECT(MAX(Library.ID).AS("MaxID")).FROM(Library.Table).Query(db(), &maxID); err != nil {
+		return 0, err
+	}
+	if len(maxID) > 0 && maxID[0].MaxID != nil {
+		return *maxID[0].MaxID + 1, nil
+	}
+	return 1, nil
+}
+
 // newLibrary creates a new library to the database.
 func newLibrary(id int32, path string, layout string) error {
 	stmt := Library.INSERT(Library.ID, Library.Path, Library.Layout).VALUES(id, path, layout).
@@ -93,10 +297,3 @@ func newLibrary(id int32, path string, layout string) error {
 
 	return err
 }
-
-// updateLibrary updates the library in the database.
-func updateLibrary(id int32, path string, layout string) error {
-	stmt := Library.UPDATE(Library.Path, Library.Layout).SET(path, layout).WHERE(Library.ID.EQ(Int32(id)))
-	_, err := stmt.Exec(db())
-	return err
-}
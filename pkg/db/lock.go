@@ -0,0 +1,166 @@
+package db
+
+import (
+	"slices"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	. "github.com/Mangatsu/server/pkg/types/sqlite/table"
+	"github.com/go-jet/jet/v2/qrm"
+	. "github.com/go-jet/jet/v2/sqlite"
+)
+
+// LockableFields are the fields that can be protected from the metadata parsers.
+// The names match the fields of the revisions.
+var LockableFields = []string{
+	"Title",
+	"TitleNative",
+	"TitleTranslated",
+	"Category",
+	"Released",
+	"Series",
+	"Language",
+	"Translated",
+	"Nsfw",
+	"Tags",
+	"Urls",
+	"ExhGid",
+	"ExhToken",
+	"AnilistID",
+}
+
+// GetLocks returns the locked fields of the gallery.
+func GetLocks(galleryUUID string) ([]string, error) {
+	locks, err := getLocks(db(), galleryUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(locks))
+	for _, field := range LockableFields {
+		if locks[field] {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields, nil
+}
+
+// LockFields locks the given fields of the gallery. Unknown fields are ignored.
+func LockFields(galleryUUID string, fields []string) error {
+	return lockFields(db(), galleryUUID, fields, time.Now())
+}
+
+// UnlockFields unlocks the given fields of the gallery. If no fields are given, all locks are cleared.
+func UnlockFields(galleryUUID string, fields []string) error {
+	condition := GalleryLock.GalleryUUID.EQ(String(galleryUUID))
+	if len(fields) > 0 {
+		fieldExpressions := make([]Expression, 0, len(fields))
+		for _, field := range fields {
+			fieldExpressions = append(fieldExpressions, String(field))
+		}
+		condition = condition.AND(GalleryLock.Field.IN(fieldExpressions...))
+	}
+
+	_, err := GalleryLock.DELETE().WHERE(condition).Exec(db())
+	return err
+}
+
+func getLocks(q qrm.Queryable, galleryUUID string) (map[string]bool, error) {
+	stmt := SELECT(GalleryLock.AllColumns).
+		FROM(GalleryLock).
+		WHERE(GalleryLock.GalleryUUID.EQ(String(galleryUUID)))
+
+	var locks []model.GalleryLock
+	if err := stmt.Query(q, &locks); err != nil {
+		return nil, err
+	}
+
+	lockMap := make(map[string]bool, len(locks))
+	for _, lock := range locks {
+		lockMap[lock.Field] = true
+	}
+
+	return lockMap, nil
+}
+
+func lockFields(q qrm.Executable, galleryUUID string, fields []string, now time.Time) error {
+	for _, field := range fields {
+		if !slices.Contains(LockableFields, field) {
+			continue
+		}
+
+		stmt := GalleryLock.
+			INSERT(GalleryLock.GalleryUUID, GalleryLock.Field, GalleryLock.CreatedAt).
+			VALUES(galleryUUID, field, now).
+			ON_CONFLICT(GalleryLock.GalleryUUID, GalleryLock.Field).
+			DO_NOTHING()
+
+		if _, err := stmt.Exec(q); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// changedFields returns the names of the changed fields.
+func changedFields(changes []FieldChange) []string {
+	fields := make([]string, 0, len(changes))
+	for _, change := range changes {
+		fields = append(fields, change.Field)
+	}
+
+	return fields
+}
+
+// skipLockedFields clears the locked fields from the metadata found by a parser,
+// so that they are left untouched by an internal update.
+func skipLockedFields(locks map[string]bool, prevGallery model.Gallery, gallery *model.Gallery, tags *[]model.Tag, reference *model.Reference) {
+	if len(locks) == 0 {
+		return
+	}
+
+	if locks["Title"] {
+		gallery.Title = ""
+	}
+	if locks["TitleNative"] {
+		gallery.TitleNative = nil
+	}
+	if locks["TitleTranslated"] {
+		gallery.TitleTranslated = nil
+	}
+	if locks["Category"] {
+		gallery.Category = nil
+	}
+	if locks["Released"] {
+		gallery.Released = nil
+	}
+	if locks["Series"] {
+		gallery.Series = nil
+	}
+	if locks["Language"] {
+		gallery.Language = nil
+	}
+	if locks["Translated"] {
+		gallery.Translated = nil
+	}
+	if locks["Nsfw"] {
+		gallery.Nsfw = prevGallery.Nsfw
+	}
+	if locks["Tags"] {
+		*tags = nil
+	}
+	if locks["Urls"] {
+		reference.Urls = nil
+	}
+	if locks["ExhGid"] {
+		reference.ExhGid = nil
+	}
+	if locks["ExhToken"] {
+		reference.ExhToken = nil
+	}
+	if locks["AnilistID"] {
+		reference.AnilistID = nil
+	}
+}
@@ -0,0 +1,196 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/log"
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	. "github.com/Mangatsu/server/pkg/types/sqlite/table"
+	. "github.com/go-jet/jet/v2/sqlite"
+	"go.uber.org/zap"
+)
+
+// loginAttemptRetention is how long the login attempts are kept.
+const loginAttemptRetention = time.Hour * 24 * 30
+
+// LoginAttemptFilters filters the login attempts shown to admins.
+type LoginAttemptFilters struct {
+	Username   string
+	UserUUID   string
+	IP         string
+	FailedOnly bool
+	Limit      int64
+	Offset     int64
+}
+
+// NewLoginAttempt records a login attempt. Username is what was entered, and userUUID is set if the user exists.
+func NewLoginAttempt(username *string, userUUID *string, ip string, success bool, reason string) {
+	stmt := LoginAttempt.INSERT(
+		LoginAttempt.Username,
+		LoginAttempt.UserUUID,
+		LoginAttempt.IP,
+		LoginAttempt.Success,
+		LoginAttempt.Reason,
+		LoginAttempt.CreatedAt,
+	).VALUES(username, userUUID, ip, success, reason, time.Now())
+
+	if _, err := stmt.Exec(db()); err != nil {
+		log.Z.Error("failed to record login attempt", zap.String("err", err.Error()))
+	}
+}
+
+// GetLoginAttempts returns the latest login attempts.
+func GetLoginAttempts(filters LoginAttemptFilters) ([]model.LoginAttempt, error) {
+	condition := Bool(true)
+	if filters.Username != "" {
+		condition = condition.AND(LoginAttempt.Username.EQ(String(filters.Username)))
+	}
+	if filters.UserUUID != "" {
+		condition = condition.AND(LoginAttempt.UserUUID.EQ(String(filters.UserUUID)))
+	}
+	if filters.IP != "" {
+		condition = condition.AND(LoginAttempt.IP.EQ(String(filters.IP)))
+	}
+	if filters.FailedOnly {
+		condition = condition.AND(LoginAttempt.Success.IS_FALSE())
+	}
+
+	stmt := SELECT(LoginAttempt.AllColumns).
+		FROM(LoginAttempt).
+		WHERE(condition).
+		ORDER_BY(LoginAttempt.ID.DESC()).
+		LIMIT(filters.Limit).
+		OFFSET(filters.Offset)
+
+	var attempts []model.LoginAttempt
+	err := stmt.Query(db(), &attempts)
+	return attempts, err
+}
+
+// PruneLoginAttempts removes login attempts older than 30 days.
+func PruneLoginAttempts() {
+	cutoff := time.Now().Add(-loginAttemptRetention)
+	for {
+		var attempts []model.LoginAttempt
+		stmt := SELECT(LoginAttempt.ID, LoginAttempt.CreatedAt).
+			FROM(LoginAttempt).
+			ORDER_BY(LoginAttempt.ID.ASC()).
+			LIMIT(1000)
+		if err := stmt.Query(db(), &attempts); err != nil {
+			log.Z.Error("failed to prune login attempts", zap.String("err", err.Error()))
+			return
+		}
+
+		// The attempts are in chronological order. Age is compared here, as the timestamps are stored with the
+		// time zone offset.
+		lastID := int32(-1)
+		for _, attempt := range attempts {
+			if attempt.CreatedAt.After(cutoff) {
+				break
+			}
+			lastID = attempt.ID
+		}
+		if lastID < 0 {
+			return
+		}
+
+		if _, err := LoginAttempt.DELETE().WHERE(LoginAttempt.ID.LT_EQ(Int32(lastID))).Exec(db()); err != nil {
+			log.Z.Error("failed to prune login attempts", zap.String("err", err.Error()))
+			return
+		}
+
+		if lastID != attempts[len(attempts)-1].ID {
+			return
+		}
+	}
+}
+
+// LoginState tells whether the user is allowed to log in.
+type LoginState struct {
+	UUID string
+	// LockedUntil is set if the account is locked after too many failed logins.
+	LockedUntil *time.Time
+	// Pending is true if the user is waiting for an admin to approve their registration.
+	Pending bool
+}
+
+// GetLoginState returns the login state of the user. Returns sql.ErrNoRows if the user does not exist.
+func GetLoginState(username string) (LoginState, error) {
+	stmt := SELECT(User.UUID, User.LockedUntil, User.Pending).FROM(User).WHERE(User.Username.EQ(String(username)))
+
+	var users []model.User
+	if err := stmt.Query(db(), &users); err != nil {
+		return LoginState{}, err
+	}
+	if len(users) == 0 {
+		return LoginState{}, sql.ErrNoRows
+	}
+
+	state := LoginState{UUID: users[0].UUID, LockedUntil: users[0].LockedUntil, Pending: users[0].Pending}
+	if state.LockedUntil != nil && time.Now().After(*state.LockedUntil) {
+		state.LockedUntil = nil
+	}
+
+	return state, nil
+}
+
+// RecordLoginFailure counts a failed login of the user. After threshold consecutive failures the account is
+// locked for the duration, and the time it is locked until is returned. A threshold of 0 disables the lockout.
+func RecordLoginFailure(userUUID string, threshold int, duration time.Duration) (*time.Time, error) {
+	stmt := User.UPDATE(User.FailedLogins).
+		SET(User.FailedLogins.ADD(Int32(1))).
+		WHERE(User.UUID.EQ(String(userUUID))).
+		RETURNING(User.FailedLogins)
+
+	var users []model.User
+	if err := stmt.Query(db(), &users); err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	if threshold <= 0 || int(users[0].FailedLogins) < threshold {
+		return nil, nil
+	}
+
+	lockedUntil := time.Now().Add(duration)
+	lockStmt := User.UPDATE(User.FailedLogins, User.LockedUntil).
+		SET(Int32(0), lockedUntil).
+		WHERE(User.UUID.EQ(String(userUUID)))
+	if _, err := lockStmt.Exec(db()); err != nil {
+		return nil, err
+	}
+
+	return &lockedUntil, nil
+}
+
+// ResetLoginFailures clears the failed login count after a successful login.
+func ResetLoginFailures(userUUID string) {
+	stmt := User.UPDATE(User.FailedLogins, User.LockedUntil).
+		SET(Int32(0), NULL).
+		WHERE(User.UUID.EQ(String(userUUID)).AND(User.FailedLogins.GT(Int32(0)).OR(User.LockedUntil.IS_NOT_NULL())))
+
+	if _, err := stmt.Exec(db()); err != nil {
+		log.Z.Error("failed to reset failed logins", zap.String("err", err.Error()), zap.String("user", userUUID))
+	}
+}
+
+// UnlockUser removes the lock from the account. Returns the username.
+func UnlockUser(userUUID string) (string, error) {
+	stmt := User.UPDATE(User.FailedLogins, User.LockedUntil).
+		SET(Int32(0), NULL).
+		WHERE(User.UUID.EQ(String(userUUID))).
+		RETURNING(User.Username)
+
+	var users []model.User
+	if err := stmt.Query(db(), &users); err != nil {
+		return "", err
+	}
+	if len(users) == 0 {
+		return "", sql.ErrNoRows
+	}
+
+	return users[0].Username, nil
+}
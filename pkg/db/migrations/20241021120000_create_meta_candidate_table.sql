@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE IF NOT EXISTS meta_candidate
+(
+    id           integer PRIMARY KEY AUTOINCREMENT NOT NULL,
+    gallery_uuid text     NOT NULL,
+    source       text     NOT NULL,
+    source_id    text     NOT NULL,
+    similarity   integer  NOT NULL,
+    proposal     text     NOT NULL,
+    status       text     NOT NULL DEFAULT 'pending',
+    created_at   datetime NOT NULL,
+    updated_at   datetime NOT NULL,
+    CONSTRAINT unique_candidate UNIQUE (gallery_uuid, source, source_id),
+    CONSTRAINT gallery
+        FOREIGN KEY (gallery_uuid)
+            REFERENCES gallery (uuid)
+            ON DELETE CASCADE
+);
+
+CREATE INDEX idx_meta_candidate_status ON meta_candidate (status);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE IF EXISTS meta_candidate;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE IF NOT EXISTS gallery_revision
+(
+    id           integer PRIMARY KEY AUTOINCREMENT NOT NULL,
+    gallery_uuid text     NOT NULL,
+    source       text     NOT NULL,
+    user_uuid    text,
+    changes      text     NOT NULL,
+    created_at   datetime NOT NULL,
+    CONSTRAINT gallery
+        FOREIGN KEY (gallery_uuid)
+            REFERENCES gallery (uuid)
+            ON DELETE CASCADE
+);
+
+CREATE INDEX idx_gallery_revision_gallery ON gallery_revision (gallery_uuid, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE IF EXISTS gallery_revision;
+-- +goose StatementEnd
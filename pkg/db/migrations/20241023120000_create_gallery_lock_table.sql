@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE IF NOT EXISTS gallery_lock
+(
+    gallery_uuid text     NOT NULL,
+    field        text     NOT NULL,
+    created_at   datetime NOT NULL,
+    PRIMARY KEY (gallery_uuid, field),
+    CONSTRAINT gallery
+        FOREIGN KEY (gallery_uuid)
+            REFERENCES gallery (uuid)
+            ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE IF EXISTS gallery_lock;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE gallery
+    ADD COLUMN deleted_at datetime;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE gallery
+    DROP COLUMN deleted_at;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE library
+    ADD COLUMN name text;
+ALTER TABLE library
+    ADD COLUMN nsfw boolean NOT NULL DEFAULT false;
+ALTER TABLE library
+    ADD COLUMN language text;
+ALTER TABLE library
+    ADD COLUMN ltr boolean;
+ALTER TABLE library
+    ADD COLUMN deleted boolean NOT NULL DEFAULT false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE library
+    DROP COLUMN deleted;
+ALTER TABLE library
+    DROP COLUMN ltr;
+ALTER TABLE library
+    DROP COLUMN language;
+ALTER TABLE library
+    DROP COLUMN nsfw;
+ALTER TABLE library
+    DROP COLUMN name;
+-- +goose StatementEnd
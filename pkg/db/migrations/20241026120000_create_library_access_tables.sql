@@ -0,0 +1,53 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE IF NOT EXISTS user_group
+(
+    id         integer UNIQUE NOT NULL,
+    name       text UNIQUE    NOT NULL,
+    created_at datetime       NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS user_group_member
+(
+    group_id  integer NOT NULL,
+    user_uuid text    NOT NULL,
+    PRIMARY KEY (group_id, user_uuid),
+    CONSTRAINT user_group
+        FOREIGN KEY (group_id)
+            REFERENCES user_group (id)
+            ON DELETE CASCADE,
+    CONSTRAINT user
+        FOREIGN KEY (user_uuid)
+            REFERENCES user (uuid)
+            ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS library_access
+(
+    library_id     integer  NOT NULL,
+    principal_type text     NOT NULL,
+    principal_id   text     NOT NULL,
+    allow          boolean  NOT NULL,
+    created_at     datetime NOT NULL,
+    PRIMARY KEY (library_id, principal_type, principal_id),
+    CONSTRAINT library
+        FOREIGN KEY (library_id)
+            REFERENCES library (id)
+            ON DELETE CASCADE
+);
+
+ALTER TABLE user
+    ADD COLUMN hide_nsfw boolean NOT NULL DEFAULT false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE user
+    DROP COLUMN hide_nsfw;
+DROP TABLE IF EXISTS library_access;
+DROP TABLE IF EXISTS user_group_member;
+DROP TABLE IF EXISTS user_group;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE IF NOT EXISTS api_token
+(
+    id           text UNIQUE NOT NULL,
+    user_uuid    text        NOT NULL,
+    name         text        NOT NULL,
+    token_hash   text UNIQUE NOT NULL,
+    scopes       text        NOT NULL,
+    expires_at   datetime,
+    last_used_at datetime,
+    created_at   datetime    NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT user
+        FOREIGN KEY (user_uuid)
+            REFERENCES user (uuid)
+            ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE IF EXISTS api_token;
+-- +goose StatementEnd
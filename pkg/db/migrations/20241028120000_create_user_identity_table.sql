@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE IF NOT EXISTS user_identity
+(
+    issuer     text     NOT NULL,
+    subject    text     NOT NULL,
+    user_uuid  text     NOT NULL,
+    created_at datetime NOT NULL,
+    PRIMARY KEY (issuer, subject),
+    CONSTRAINT user
+        FOREIGN KEY (user_uuid)
+            REFERENCES user (uuid)
+            ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE IF EXISTS user_identity;
+-- +goose StatementEnd
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE user
+    ADD COLUMN totp_secret text;
+ALTER TABLE user
+    ADD COLUMN totp_enabled boolean NOT NULL DEFAULT false;
+ALTER TABLE user
+    ADD COLUMN totp_last_step integer NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS recovery_code
+(
+    user_uuid  text     NOT NULL,
+    code_hash  text     NOT NULL,
+    used_at    datetime,
+    created_at datetime NOT NULL,
+    PRIMARY KEY (user_uuid, code_hash),
+    CONSTRAINT user
+        FOREIGN KEY (user_uuid)
+            REFERENCES user (uuid)
+            ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE IF EXISTS recovery_code;
+ALTER TABLE user
+    DROP COLUMN totp_last_step;
+ALTER TABLE user
+    DROP COLUMN totp_enabled;
+ALTER TABLE user
+    DROP COLUMN totp_secret;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE IF NOT EXISTS login_attempt
+(
+    id         integer UNIQUE NOT NULL,
+    username   text,
+    user_uuid  text,
+    ip         text           NOT NULL,
+    success    boolean        NOT NULL,
+    reason     text           NOT NULL,
+    created_at datetime       NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS login_attempt_username_idx ON login_attempt (username);
+
+ALTER TABLE user
+    ADD COLUMN failed_logins integer NOT NULL DEFAULT 0;
+ALTER TABLE user
+    ADD COLUMN locked_until datetime;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE user
+    DROP COLUMN locked_until;
+ALTER TABLE user
+    DROP COLUMN failed_logins;
+DROP INDEX IF EXISTS login_attempt_username_idx;
+DROP TABLE IF EXISTS login_attempt;
+-- +goose StatementEnd
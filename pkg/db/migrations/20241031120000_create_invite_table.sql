@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE IF NOT EXISTS invite
+(
+    id         text UNIQUE NOT NULL,
+    code_hash  text UNIQUE NOT NULL,
+    role       integer     NOT NULL,
+    max_uses   integer     NOT NULL,
+    uses       integer     NOT NULL DEFAULT 0,
+    expires_at datetime    NOT NULL,
+    created_by text        NOT NULL,
+    created_at datetime    NOT NULL,
+    PRIMARY KEY (id)
+);
+
+ALTER TABLE user
+    ADD COLUMN pending boolean NOT NULL DEFAULT false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE user
+    DROP COLUMN pending;
+DROP TABLE IF EXISTS invite;
+-- +goose StatementEnd
@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE IF NOT EXISTS webhook
+(
+    id         integer UNIQUE NOT NULL,
+    name       text           NOT NULL,
+    url        text           NOT NULL,
+    secret     text           NOT NULL,
+    events     text           NOT NULL,
+    enabled    boolean        NOT NULL DEFAULT true,
+    created_at datetime       NOT NULL,
+    updated_at datetime       NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery
+(
+    id              integer UNIQUE NOT NULL,
+    webhook_id      integer        NOT NULL,
+    event           text           NOT NULL,
+    payload         text           NOT NULL,
+    status          text           NOT NULL,
+    attempts        integer        NOT NULL DEFAULT 0,
+    next_attempt_at datetime       NOT NULL,
+    last_attempt_at datetime,
+    response_status integer,
+    error           text,
+    created_at      datetime       NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT webhook
+        FOREIGN KEY (webhook_id)
+            REFERENCES webhook (id)
+            ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_status_idx ON webhook_delivery (status);
+CREATE INDEX IF NOT EXISTS webhook_delivery_webhook_id_idx ON webhook_delivery (webhook_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP INDEX IF EXISTS webhook_delivery_webhook_id_idx;
+DROP INDEX IF EXISTS webhook_delivery_status_idx;
+DROP TABLE IF EXISTS webhook_delivery;
+DROP TABLE IF EXISTS webhook;
+-- +goose StatementEnd
@@ -0,0 +1,60 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE IF NOT EXISTS series
+(
+    id                 integer UNIQUE NOT NULL,
+    name               text UNIQUE    NOT NULL,
+    description        text,
+    cover              text,
+    status             text,
+    alternative_titles text,
+    created_at         datetime       NOT NULL,
+    updated_at         datetime       NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS series_tag
+(
+    series_id integer NOT NULL,
+    tag_id    integer NOT NULL,
+    PRIMARY KEY (series_id, tag_id),
+    CONSTRAINT series
+        FOREIGN KEY (series_id)
+            REFERENCES series (id)
+            ON DELETE CASCADE,
+    CONSTRAINT tag
+        FOREIGN KEY (tag_id)
+            REFERENCES tag (id)
+            ON DELETE CASCADE
+);
+
+ALTER TABLE gallery
+    ADD COLUMN volume real;
+ALTER TABLE gallery
+    ADD COLUMN chapter real;
+ALTER TABLE gallery
+    ADD COLUMN series_order integer;
+
+CREATE INDEX IF NOT EXISTS gallery_series_idx ON gallery (series);
+
+INSERT INTO series (name, created_at, updated_at)
+SELECT DISTINCT series, datetime('now'), datetime('now')
+FROM gallery
+WHERE series IS NOT NULL
+  AND series != '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP INDEX IF EXISTS gallery_series_idx;
+ALTER TABLE gallery
+    DROP COLUMN series_order;
+ALTER TABLE gallery
+    DROP COLUMN chapter;
+ALTER TABLE gallery
+    DROP COLUMN volume;
+DROP TABLE IF EXISTS series_tag;
+DROP TABLE IF EXISTS series;
+-- +goose StatementEnd
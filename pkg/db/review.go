@@ -0,0 +1,353 @@
+package db
+
+import (
+	"encoding/json"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/log"
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	. "github.com/Mangatsu/server/pkg/types/sqlite/table"
+	. "github.com/go-jet/jet/v2/sqlite"
+	"go.uber.org/zap"
+)
+
+type CandidateStatus string
+
+const (
+	CandidatePending  CandidateStatus = "pending"
+	CandidateAccepted                 = "accepted"
+	CandidateRejected                 = "rejected"
+)
+
+// MetaProposal is the metadata a match would apply to a gallery.
+type MetaProposal struct {
+	Gallery   model.Gallery
+	Tags      []model.Tag
+	Reference model.Reference
+}
+
+// ReviewCandidate is a low-confidence metadata match waiting for a review.
+type ReviewCandidate struct {
+	ID           int32
+	GalleryUUID  string
+	GalleryTitle string
+	Source       string
+	SourceID     string
+	Similarity   float64
+	Status       CandidateStatus
+	Proposal     MetaProposal
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// FieldChange describes a change of a single gallery field.
+type FieldChange struct {
+	Field string
+	Old   interface{}
+	New   interface{}
+}
+
+type reviewCandidateRow struct {
+	model.MetaCandidate
+	GalleryTitle string `alias:"gallery.title"`
+}
+
+// ProposeMetaCandidate adds the match to the review queue.
+// Pending candidates from the same source are refreshed, reviewed ones are left untouched.
+func ProposeMetaCandidate(galleryUUID string, source string, sourceID string, similarity float64, proposal MetaProposal) error {
+	payload, err := json.Marshal(proposal)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	permil := int32(math.Round(similarity * 1000))
+	stmt := MetaCandidate.
+		INSERT(
+			MetaCandidate.GalleryUUID,
+			MetaCandidate.Source,
+			MetaCandidate.SourceID,
+			MetaCandidate.Similarity,
+			MetaCandidate.Proposal,
+			MetaCandidate.Status,
+			MetaCandidate.CreatedAt,
+			MetaCandidate.UpdatedAt,
+		).
+		VALUES(galleryUUID, source, sourceID, permil, string(payload), string(CandidatePending), now, now).
+		ON_CONFLICT(MetaCandidate.GalleryUUID, MetaCandidate.Source, MetaCandidate.SourceID).
+		DO_UPDATE(
+			SET(
+				MetaCandidate.Similarity.SET(MetaCandidate.EXCLUDED.Similarity),
+				MetaCandidate.Proposal.SET(MetaCandidate.EXCLUDED.Proposal),
+				MetaCandidate.UpdatedAt.SET(MetaCandidate.EXCLUDED.UpdatedAt),
+			).WHERE(MetaCandidate.Status.EQ(String(string(CandidatePending)))),
+		)
+
+	_, err = stmt.Exec(db())
+	return err
+}
+
+// MetaCandidateRejected returns true if the match has been rejected before.
+func MetaCandidateRejected(galleryUUID string, source string, sourceID string) bool {
+	stmt := SELECT(MetaCandidate.ID).
+		FROM(MetaCandidate).
+		WHERE(
+			MetaCandidate.GalleryUUID.EQ(String(galleryUUID)).
+				AND(MetaCandidate.Source.EQ(String(source))).
+				AND(MetaCandidate.SourceID.EQ(String(sourceID))).
+				AND(MetaCandidate.Status.EQ(String(string(CandidateRejected)))),
+		)
+
+	var candidates []model.MetaCandidate
+	if err := stmt.Query(db(), &candidates); err != nil {
+		log.Z.Debug("failed to query for rejected candidates",
+			zap.String("uuid", galleryUUID),
+			zap.String("err", err.Error()))
+		return false
+	}
+
+	return len(candidates) > 0
+}
+
+// GetMetaCandidates returns candidates with the given status, the most similar first.
+// If the status is empty, all candidates are returned.
+func GetMetaCandidates(status CandidateStatus) ([]ReviewCandidate, error) {
+	stmt := SELECT(MetaCandidate.AllColumns, Gallery.Title).
+		FROM(MetaCandidate.INNER_JOIN(Gallery, Gallery.UUID.EQ(MetaCandidate.GalleryUUID))).
+		ORDER_BY(MetaCandidate.Similarity.DESC(), MetaCandidate.ID.ASC())
+
+	if status != "" {
+		stmt = stmt.WHERE(MetaCandidate.Status.EQ(String(string(status))))
+	}
+
+	var rows []reviewCandidateRow
+	if err := stmt.Query(db(), &rows); err != nil {
+		return nil, err
+	}
+
+	candidates := make([]ReviewCandidate, 0, len(rows))
+	for _, row := range rows {
+		candidate, err := row.convert()
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	return candidates, nil
+}
+
+// GetMetaCandidate returns the candidate with the given ID.
+func GetMetaCandidate(id int32) (ReviewCandidate, error) {
+	stmt := SELECT(MetaCandidate.AllColumns, Gallery.Title).
+		FROM(MetaCandidate.INNER_JOIN(Gallery, Gallery.UUID.EQ(MetaCandidate.GalleryUUID))).
+		WHERE(MetaCandidate.ID.EQ(Int32(id)))
+
+	var row reviewCandidateRow
+	if err := stmt.Query(db(), &row); err != nil {
+		return ReviewCandidate{}, err
+	}
+
+	return row.convert()
+}
+
+// SetMetaCandidateStatus marks the candidate as reviewed.
+func SetMetaCandidateStatus(id int32, status CandidateStatus) error {
+	stmt := MetaCandidate.
+		UPDATE(MetaCandidate.Status, MetaCandidate.UpdatedAt).
+		SET(String(string(status)), time.Now()).
+		WHERE(MetaCandidate.ID.EQ(Int32(id)))
+
+	_, err := stmt.Exec(db())
+	return err
+}
+
+// AcceptMetaCandidate applies the proposed metadata to the gallery and marks the candidate as accepted.
+func AcceptMetaCandidate(candidate ReviewCandidate, userUUID *string) error {
+	current, err := getGallery(&candidate.GalleryUUID, nil, nil, false)
+	if err != nil {
+		return err
+	}
+
+	gallery := candidate.Proposal.Gallery
+	gallery.UUID = current.UUID
+	gallery.ArchivePath = current.ArchivePath
+
+	reference := candidate.Proposal.Reference
+	reference.GalleryUUID = current.UUID
+
+	if err = UpdateGallery(gallery, slices.Clone(candidate.Proposal.Tags), reference, true, Author{
+		Source:   SourceReview,
+		UserUUID: userUUID,
+	}); err != nil {
+		return err
+	}
+
+	return SetMetaCandidateStatus(candidate.ID, CandidateAccepted)
+}
+
+// PreviewMetaCandidate returns the changes accepting the candidate would make to the gallery.
+func PreviewMetaCandidate(candidate ReviewCandidate) ([]FieldChange, error) {
+	current, err := getGallery(&candidate.GalleryUUID, nil, nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	locks, err := getLocks(db(), candidate.GalleryUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	proposal := candidate.Proposal
+	skipLockedFields(locks, current.Gallery, &proposal.Gallery, &proposal.Tags, &proposal.Reference)
+
+	// The same rules as in UpdateGallery for internal scans apply: only non-empty values replace the old ones.
+	proposed := mergeGalleryInternal(current.Gallery, proposal.Gallery)
+	changes := galleryChanges(current.Gallery, proposed)
+
+	if proposal.Tags != nil {
+		_, currentTags, err := GetTags(candidate.GalleryUUID, false)
+		if err != nil {
+			return nil, err
+		}
+
+		oldTags, newTags := tagStrings(currentTags), tagStrings(proposal.Tags)
+		if !slices.Equal(oldTags, newTags) {
+			changes = append(changes, FieldChange{Field: "Tags", Old: oldTags, New: newTags})
+		}
+	}
+
+	reference := ValidateReferenceInternal(proposal.Reference)
+	currentReference, err := GetReference(candidate.GalleryUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	if reference.Urls != nil && !equalPtr(currentReference.Urls, reference.Urls) {
+		changes = append(changes, FieldChange{Field: "Urls", Old: currentReference.Urls, New: reference.Urls})
+	}
+	if reference.ExhGid != nil && !equalPtr(currentReference.ExhGid, reference.ExhGid) {
+		changes = append(changes, FieldChange{Field: "ExhGid", Old: currentReference.ExhGid, New: reference.ExhGid})
+	}
+	if reference.ExhToken != nil && !equalPtr(currentReference.ExhToken, reference.ExhToken) {
+		changes = append(changes, FieldChange{Field: "ExhToken", Old: currentReference.ExhToken, New: reference.ExhToken})
+	}
+	if reference.AnilistID != nil && !equalPtr(currentReference.AnilistID, reference.AnilistID) {
+		changes = append(changes, FieldChange{Field: "AnilistID", Old: currentReference.AnilistID, New: reference.AnilistID})
+	}
+
+	return changes, nil
+}
+
+// mergeGalleryInternal returns the gallery as it would be after an internal update with the new gallery.
+func mergeGalleryInternal(gallery model.Gallery, newGallery model.Gallery) model.Gallery {
+	if value := strings.TrimSpace(newGallery.Title); value != "" {
+		gallery.Title = value
+	}
+	if value := SanitizeString(newGallery.TitleNative); value != nil {
+		gallery.TitleNative = value
+	}
+	if value := SanitizeString(newGallery.TitleTranslated); value != nil {
+		gallery.TitleTranslated = value
+	}
+	if value := SanitizeString(newGallery.Category); value != nil {
+		gallery.Category = value
+	}
+	if value := SanitizeString(newGallery.Released); value != nil {
+		gallery.Released = value
+	}
+	if value := SanitizeString(newGallery.Series); value != nil {
+		gallery.Series = value
+	}
+	if value := SanitizeString(newGallery.Language); value != nil {
+		gallery.Language = value
+	}
+	if newGallery.Translated != nil {
+		gallery.Translated = newGallery.Translated
+	}
+	gallery.Nsfw = gallery.Nsfw || newGallery.Nsfw
+
+	return gallery
+}
+
+// galleryChanges returns the differences of the user editable fields of the galleries.
+func galleryChanges(oldGallery model.Gallery, newGallery model.Gallery) []FieldChange {
+	var changes []FieldChange
+
+	if oldGallery.Title != newGallery.Title {
+		changes = append(changes, FieldChange{Field: "Title", Old: oldGallery.Title, New: newGallery.Title})
+	}
+
+	stringFields := []struct {
+		name     string
+		old, new *string
+	}{
+		{"TitleNative", oldGallery.TitleNative, newGallery.TitleNative},
+		{"TitleTranslated", oldGallery.TitleTranslated, newGallery.TitleTranslated},
+		{"Category", oldGallery.Category, newGallery.Category},
+		{"Released", oldGallery.Released, newGallery.Released},
+		{"Series", oldGallery.Series, newGallery.Series},
+		{"Language", oldGallery.Language, newGallery.Language},
+	}
+	for _, field := range stringFields {
+		if !equalPtr(field.old, field.new) {
+			changes = append(changes, FieldChange{Field: field.name, Old: field.old, New: field.new})
+		}
+	}
+
+	if !equalPtr(oldGallery.Translated, newGallery.Translated) {
+		changes = append(changes, FieldChange{Field: "Translated", Old: oldGallery.Translated, New: newGallery.Translated})
+	}
+	if oldGallery.Nsfw != newGallery.Nsfw {
+		changes = append(changes, FieldChange{Field: "Nsfw", Old: oldGallery.Nsfw, New: newGallery.Nsfw})
+	}
+	if oldGallery.Hidden != newGallery.Hidden {
+		changes = append(changes, FieldChange{Field: "Hidden", Old: oldGallery.Hidden, New: newGallery.Hidden})
+	}
+
+	return changes
+}
+
+// tagStrings returns the tags as sorted and deduplicated namespace:name strings.
+func tagStrings(tags []model.Tag) []string {
+	values := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag.Namespace == "" || tag.Name == "" {
+			continue
+		}
+		values = append(values, tag.Namespace+":"+tag.Name)
+	}
+	slices.Sort(values)
+
+	return slices.Compact(values)
+}
+
+func equalPtr[T comparable](a *T, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func (row reviewCandidateRow) convert() (ReviewCandidate, error) {
+	var proposal MetaProposal
+	if err := json.Unmarshal([]byte(row.Proposal), &proposal); err != nil {
+		return ReviewCandidate{}, err
+	}
+
+	return ReviewCandidate{
+		ID:           row.ID,
+		GalleryUUID:  row.MetaCandidate.GalleryUUID,
+		GalleryTitle: row.GalleryTitle,
+		Source:       row.Source,
+		SourceID:     row.SourceID,
+		Similarity:   float64(row.Similarity) / 1000,
+		Status:       CandidateStatus(row.Status),
+		Proposal:     proposal,
+		CreatedAt:    row.CreatedAt,
+		UpdatedAt:    row.UpdatedAt,
+	}, nil
+}
@@ -0,0 +1,347 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	. "github.com/Mangatsu/server/pkg/types/sqlite/table"
+	"github.com/go-jet/jet/v2/qrm"
+	. "github.com/go-jet/jet/v2/sqlite"
+)
+
+// Sources of changes made outside the metadata parsers.
+const (
+	SourceAPI    = "api"
+	SourceReview = "review"
+	SourceRevert = "revert"
+)
+
+// Author identifies who made a change: a user through the API or a metadata parser.
+type Author struct {
+	// Source is the origin of the change such as api or the name of the parser.
+	Source string
+	// UserUUID is the user who made or triggered the change, if any.
+	UserUUID *string
+}
+
+// Revision is a stored change of a gallery.
+type Revision struct {
+	ID          int32
+	GalleryUUID string
+	Source      string
+	UserUUID    *string
+	Username    *string
+	Changes     []FieldChange
+	CreatedAt   time.Time
+}
+
+// galleryState is the revisioned state of a gallery. Tags are stored as namespace:name strings.
+type galleryState struct {
+	Gallery   model.Gallery
+	Tags      []string
+	Reference model.Reference
+}
+
+type revisionRow struct {
+	model.GalleryRevision
+	Username *string `alias:"user.username"`
+}
+
+// GetRevisions returns the revisions of the gallery, the latest first.
+func GetRevisions(galleryUUID string) ([]Revision, error) {
+	stmt := SELECT(GalleryRevision.AllColumns, User.Username).
+		FROM(GalleryRevision.LEFT_JOIN(User, User.UUID.EQ(GalleryRevision.UserUUID))).
+		WHERE(GalleryRevision.GalleryUUID.EQ(String(galleryUUID))).
+		ORDER_BY(GalleryRevision.ID.DESC())
+
+	var rows []revisionRow
+	if err := stmt.Query(db(), &rows); err != nil {
+		return nil, err
+	}
+
+	revisions := make([]Revision, 0, len(rows))
+	for _, row := range rows {
+		var changes []FieldChange
+		if err := json.Unmarshal([]byte(row.Changes), &changes); err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, Revision{
+			ID:          row.ID,
+			GalleryUUID: row.GalleryUUID,
+			Source:      row.Source,
+			UserUUID:    row.UserUUID,
+			Username:    row.Username,
+			Changes:     changes,
+			CreatedAt:   row.CreatedAt,
+		})
+	}
+
+	return revisions, nil
+}
+
+// RevertGallery restores the gallery to the state before the given revision, undoing it and all later revisions.
+// The revert itself is stored as a new revision.
+func RevertGallery(galleryUUID string, revisionID int32, author Author) error {
+	stmt := SELECT(GalleryRevision.AllColumns).
+		FROM(GalleryRevision).
+		WHERE(GalleryRevision.GalleryUUID.EQ(String(galleryUUID)).AND(GalleryRevision.ID.GT_EQ(Int32(revisionID)))).
+		ORDER_BY(GalleryRevision.ID.DESC())
+
+	var revisions []model.GalleryRevision
+	if err := stmt.Query(db(), &revisions); err != nil {
+		return err
+	}
+	if len(revisions) == 0 || revisions[len(revisions)-1].ID != revisionID {
+		return sql.ErrNoRows
+	}
+
+	tx, err := db().Begin()
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			rollbackTx(tx)
+		}
+	}()
+
+	prevState, err := getGalleryState(tx, galleryUUID)
+	if err != nil {
+		return err
+	}
+
+	state := prevState
+	state.Tags = slices.Clone(prevState.Tags)
+	for _, revision := range revisions {
+		var changes []struct {
+			Field string
+			Old   json.RawMessage
+		}
+		if err = json.Unmarshal([]byte(revision.Changes), &changes); err != nil {
+			return err
+		}
+
+		for _, change := range changes {
+			if target := state.field(change.Field); target != nil {
+				if err = json.Unmarshal(change.Old, target); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	now := time.Now()
+	state.Gallery.UpdatedAt = now
+	updateGalleryStmt := Gallery.
+		UPDATE(
+			Gallery.Title,
+			Gallery.TitleNative,
+			Gallery.TitleTranslated,
+			Gallery.Category,
+			Gallery.Released,
+			Gallery.Series,
+			Gallery.Language,
+			Gallery.Translated,
+			Gallery.Nsfw,
+			Gallery.Hidden,
+			Gallery.UpdatedAt,
+		).
+		MODEL(state.Gallery).
+		WHERE(Gallery.UUID.EQ(String(galleryUUID)))
+	if _, err = updateGalleryStmt.Exec(tx); err != nil {
+		return err
+	}
+
+	deleteTagsStmt := GalleryTag.DELETE().WHERE(GalleryTag.GalleryUUID.EQ(String(galleryUUID)))
+	if _, err = deleteTagsStmt.Exec(tx); err != nil {
+		return err
+	}
+
+	tagIDs, err := newTags(tx, parseTagStrings(state.Tags))
+	if err != nil {
+		return err
+	}
+	if err = insertGalleryTags(tx, galleryUUID, tagIDs); err != nil {
+		return err
+	}
+
+	state.Reference.GalleryUUID = galleryUUID
+	insertRefStmt := Reference.
+		INSERT(Reference.GalleryUUID, Reference.Urls, Reference.ExhGid, Reference.ExhToken, Reference.AnilistID).
+		MODEL(state.Reference).
+		ON_CONFLICT(Reference.GalleryUUID).
+		DO_UPDATE(
+			SET(
+				Reference.Urls.SET(Reference.EXCLUDED.Urls),
+				Reference.ExhGid.SET(Reference.EXCLUDED.ExhGid),
+				Reference.ExhToken.SET(Reference.EXCLUDED.ExhToken),
+				Reference.AnilistID.SET(Reference.EXCLUDED.AnilistID),
+			),
+		)
+	if _, err = insertRefStmt.Exec(tx); err != nil {
+		return err
+	}
+
+	newState, err := getGalleryState(tx, galleryUUID)
+	if err != nil {
+		return err
+	}
+
+	changes := diffStates(prevState, newState)
+	if err = insertRevision(tx, galleryUUID, author, changes, now); err != nil {
+		return err
+	}
+	if err = addChangedSeries(tx, changes, newState, now); err != nil {
+		return err
+	}
+
+	// Reverted fields are locked like any other manual edit.
+	if err = lockFields(tx, galleryUUID, changedFields(changes), now); err != nil {
+		return err
+	}
+
+	committed = true
+	return tx.Commit()
+}
+
+// getGalleryState returns the revisioned state of the gallery.
+func getGalleryState(q qrm.Queryable, galleryUUID string) (galleryState, error) {
+	galleryStmt := SELECT(Gallery.AllColumns).FROM(Gallery).WHERE(Gallery.UUID.EQ(String(galleryUUID)))
+
+	var galleries []model.Gallery
+	if err := galleryStmt.Query(q, &galleries); err != nil {
+		return galleryState{}, err
+	}
+	if len(galleries) == 0 {
+		return galleryState{}, sql.ErrNoRows
+	}
+
+	tagsStmt := SELECT(Tag.Namespace, Tag.Name).
+		FROM(GalleryTag.INNER_JOIN(Tag, Tag.ID.EQ(GalleryTag.TagID))).
+		WHERE(GalleryTag.GalleryUUID.EQ(String(galleryUUID)))
+
+	var tags []model.Tag
+	if err := tagsStmt.Query(q, &tags); err != nil {
+		return galleryState{}, err
+	}
+
+	reference, err := getReference(q, galleryUUID)
+	if err != nil {
+		return galleryState{}, err
+	}
+
+	return galleryState{
+		Gallery:   galleries[0],
+		Tags:      tagStrings(tags),
+		Reference: reference,
+	}, nil
+}
+
+// insertRevision stores the changes as a revision. Nothing is stored if there are no changes.
+func insertRevision(q qrm.Executable, galleryUUID string, author Author, changes []FieldChange, now time.Time) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	stmt := GalleryRevision.
+		INSERT(GalleryRevision.GalleryUUID, GalleryRevision.Source, GalleryRevision.UserUUID, GalleryRevision.Changes, GalleryRevision.CreatedAt).
+		MODEL(model.GalleryRevision{
+			GalleryUUID: galleryUUID,
+			Source:      author.Source,
+			UserUUID:    author.UserUUID,
+			Changes:     string(payload),
+			CreatedAt:   now,
+		})
+
+	_, err = stmt.Exec(q)
+	return err
+}
+
+// diffStates returns the field-level differences of the states.
+func diffStates(oldState galleryState, newState galleryState) []FieldChange {
+	changes := galleryChanges(oldState.Gallery, newState.Gallery)
+
+	if !slices.Equal(oldState.Tags, newState.Tags) {
+		changes = append(changes, FieldChange{Field: "Tags", Old: oldState.Tags, New: newState.Tags})
+	}
+
+	oldRef, newRef := oldState.Reference, newState.Reference
+	if !equalPtr(oldRef.Urls, newRef.Urls) {
+		changes = append(changes, FieldChange{Field: "Urls", Old: oldRef.Urls, New: newRef.Urls})
+	}
+	if !equalPtr(oldRef.ExhGid, newRef.ExhGid) {
+		changes = append(changes, FieldChange{Field: "ExhGid", Old: oldRef.ExhGid, New: newRef.ExhGid})
+	}
+	if !equalPtr(oldRef.ExhToken, newRef.ExhToken) {
+		changes = append(changes, FieldChange{Field: "ExhToken", Old: oldRef.ExhToken, New: newRef.ExhToken})
+	}
+	if !equalPtr(oldRef.AnilistID, newRef.AnilistID) {
+		changes = append(changes, FieldChange{Field: "AnilistID", Old: oldRef.AnilistID, New: newRef.AnilistID})
+	}
+
+	return changes
+}
+
+// field returns a pointer to the field of the state with the given name, or nil if the field is not revisioned.
+func (s *galleryState) field(name string) interface{} {
+	switch name {
+	case "Title":
+		return &s.Gallery.Title
+	case "TitleNative":
+		return &s.Gallery.TitleNative
+	case "TitleTranslated":
+		return &s.Gallery.TitleTranslated
+	case "Category":
+		return &s.Gallery.Category
+	case "Released":
+		return &s.Gallery.Released
+	case "Series":
+		return &s.Gallery.Series
+	case "Language":
+		return &s.Gallery.Language
+	case "Translated":
+		return &s.Gallery.Translated
+	case "Nsfw":
+		return &s.Gallery.Nsfw
+	case "Hidden":
+		return &s.Gallery.Hidden
+	case "Tags":
+		return &s.Tags
+	case "Urls":
+		return &s.Reference.Urls
+	case "ExhGid":
+		return &s.Reference.ExhGid
+	case "ExhToken":
+		return &s.Reference.ExhToken
+	case "AnilistID":
+		return &s.Reference.AnilistID
+	default:
+		return nil
+	}
+}
+
+// parseTagStrings converts namespace:name strings back to tags.
+func parseTagStrings(values []string) []model.Tag {
+	tags := make([]model.Tag, 0, len(values))
+	for _, value := range values {
+		namespace, name, found := strings.Cut(value, ":")
+		if !found {
+			continue
+		}
+		tags = append(tags, model.Tag{Namespace: namespace, Name: name})
+	}
+
+	return tags
+}
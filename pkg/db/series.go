@@ -0,0 +1,540 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	. "github.com/Mangatsu/server/pkg/types/sqlite/table"
+	"github.com/go-jet/jet/v2/qrm"
+	. "github.com/go-jet/jet/v2/sqlite"
+)
+
+var ErrSeriesExists = errors.New("a series with the same name already exists")
+var ErrNotInSeries = errors.New("gallery is not in the series")
+
+// SeriesInfo is a series with its tags and the number of its galleries visible to the user.
+type SeriesInfo struct {
+	model.Series
+
+	Tags         []model.Tag
+	GalleryCount int32
+}
+
+// SeriesEdit holds the changed fields of a series. Nil fields are left as they are, and empty strings clear them.
+type SeriesEdit struct {
+	Name              *string
+	Description       *string
+	Cover             *string // UUID of a gallery of the series
+	Status            *string
+	AlternativeTitles []string
+	Tags              []model.Tag
+}
+
+// SyncSeries adds the series named by galleries but not stored yet, such as the ones found by scans.
+func SyncSeries() error {
+	stmt := SELECT(Gallery.Series).
+		DISTINCT().
+		FROM(Gallery).
+		WHERE(Gallery.Series.IS_NOT_NULL().AND(Gallery.Series.NOT_IN(SELECT(Series.Name).FROM(Series))))
+
+	var names []string
+	if err := stmt.Query(db(), &names); err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	insertStmt := Series.INSERT(Series.Name, Series.CreatedAt, Series.UpdatedAt)
+	for _, name := range names {
+		insertStmt = insertStmt.VALUES(name, now, now)
+	}
+
+	_, err := insertStmt.ON_CONFLICT(Series.Name).DO_NOTHING().Exec(db())
+	return err
+}
+
+// addSeries stores the series if it doesn't exist yet. Nothing is done for a nil or empty name.
+func addSeries(q qrm.Executable, name *string, now time.Time) error {
+	if name == nil || *name == "" {
+		return nil
+	}
+
+	stmt := Series.INSERT(Series.Name, Series.CreatedAt, Series.UpdatedAt).
+		VALUES(*name, now, now).
+		ON_CONFLICT(Series.Name).
+		DO_NOTHING()
+	_, err := stmt.Exec(q)
+	return err
+}
+
+// addChangedSeries stores the series of the gallery if the changes gave it one that doesn't exist yet.
+func addChangedSeries(q qrm.Executable, changes []FieldChange, state galleryState, now time.Time) error {
+	if !slices.ContainsFunc(changes, func(change FieldChange) bool { return change.Field == "Series" }) {
+		return nil
+	}
+	return addSeries(q, state.Gallery.Series, now)
+}
+
+// GetSeries returns the series that have galleries the user, or an anonymous user if nil, may view.
+// Admins get all series, including the ones without galleries.
+func GetSeries(userUUID *string) ([]SeriesInfo, error) {
+	currentViewer, err := getViewer(userUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := seriesGalleryCounts(Gallery.Series.IS_NOT_NULL(), userUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	series, err := getSeries(Bool(true))
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make([]SeriesInfo, 0, len(series))
+	for _, s := range series {
+		if s.GalleryCount = counts[s.Name]; s.GalleryCount > 0 || currentViewer.isAdmin {
+			visible = append(visible, s)
+		}
+	}
+
+	return visible, nil
+}
+
+// GetSeriesByID returns the series if it has galleries the user, or an anonymous user if nil, may view.
+// Admins get the series even without galleries.
+func GetSeriesByID(id int32, userUUID *string) (SeriesInfo, error) {
+	currentViewer, err := getViewer(userUUID)
+	if err != nil {
+		return SeriesInfo{}, err
+	}
+
+	series, err := getSeries(Series.ID.EQ(Int32(id)))
+	if err != nil {
+		return SeriesInfo{}, err
+	}
+	if len(series) == 0 {
+		return SeriesInfo{}, sql.ErrNoRows
+	}
+
+	counts, err := seriesGalleryCounts(Gallery.Series.EQ(String(series[0].Name)), userUUID)
+	if err != nil {
+		return SeriesInfo{}, err
+	}
+	if counts[series[0].Name] == 0 && !currentViewer.isAdmin {
+		return SeriesInfo{}, sql.ErrNoRows
+	}
+
+	series[0].GalleryCount = counts[series[0].Name]
+	return series[0], nil
+}
+
+func getSeries(condition BoolExpression) ([]SeriesInfo, error) {
+	stmt := SELECT(Series.AllColumns, Tag.ID, Tag.Namespace, Tag.Name).
+		FROM(Series.
+			LEFT_JOIN(SeriesTag, SeriesTag.SeriesID.EQ(Series.ID)).
+			LEFT_JOIN(Tag, Tag.ID.EQ(SeriesTag.TagID)),
+		).
+		WHERE(condition).
+		ORDER_BY(Series.Name.ASC())
+
+	var series []SeriesInfo
+	err := stmt.Query(db(), &series)
+	return series, err
+}
+
+// seriesGalleryCounts returns the number of galleries the user may view in each series.
+func seriesGalleryCounts(condition BoolExpression, userUUID *string) (map[string]int32, error) {
+	stmt := SELECT(Gallery.Series.AS("Name"), COUNT(Gallery.UUID).AS("GalleryCount")).
+		FROM(Gallery).
+		WHERE(condition.AND(Gallery.Deleted.IS_NOT_TRUE()).AND(accessCondition(userUUID))).
+		GROUP_BY(Gallery.Series)
+
+	var rows []struct {
+		Name         string
+		GalleryCount int32
+	}
+	if err := stmt.Query(db(), &rows); err != nil && !errors.Is(err, qrm.ErrNoRows) {
+		return nil, err
+	}
+
+	counts := make(map[string]int32, len(rows))
+	for _, row := range rows {
+		counts[row.Name] = row.GalleryCount
+	}
+
+	return counts, nil
+}
+
+// UpdateSeries changes the series. A new name is also given to the galleries of the series as an edit of the author.
+func UpdateSeries(id int32, edit SeriesEdit, author Author) error {
+	tx, err := db().Begin()
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			rollbackTx(tx)
+		}
+	}()
+
+	var series model.Series
+	if err = SELECT(Series.AllColumns).FROM(Series).WHERE(Series.ID.EQ(Int32(id))).Query(tx, &series); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	oldName := series.Name
+	if edit.Name != nil && *edit.Name != oldName {
+		if err = renameSeries(tx, oldName, *edit.Name, author, now); err != nil {
+			return err
+		}
+		series.Name = *edit.Name
+	}
+	if edit.Description != nil {
+		series.Description = SanitizeString(edit.Description)
+	}
+	if edit.Cover != nil {
+		series.Cover = SanitizeString(edit.Cover)
+		if series.Cover != nil {
+			if err = checkInSeries(tx, series.Name, *series.Cover); err != nil {
+				return err
+			}
+		}
+	}
+	if edit.Status != nil {
+		series.Status = SanitizeString(edit.Status)
+	}
+	if edit.AlternativeTitles != nil {
+		series.AlternativeTitles = joinTitles(edit.AlternativeTitles)
+	}
+	series.UpdatedAt = now
+
+	stmt := Series.UPDATE(
+		Series.Name,
+		Series.Description,
+		Series.Cover,
+		Series.Status,
+		Series.AlternativeTitles,
+		Series.UpdatedAt,
+	).MODEL(series).WHERE(Series.ID.EQ(Int32(id)))
+	if _, err = stmt.Exec(tx); err != nil {
+		return err
+	}
+
+	if edit.Tags != nil {
+		if _, err = SeriesTag.DELETE().WHERE(SeriesTag.SeriesID.EQ(Int32(id))).Exec(tx); err != nil {
+			return err
+		}
+
+		tagIDs, err := newTags(tx, edit.Tags)
+		if err != nil {
+			return err
+		}
+		if err = insertSeriesTags(tx, id, tagIDs); err != nil {
+			return err
+		}
+	}
+
+	committed = true
+	return tx.Commit()
+}
+
+// renameSeries gives the new name to the galleries of the series. Fails if the name is taken by another series.
+func renameSeries(tx *sql.Tx, oldName string, newName string, author Author, now time.Time) error {
+	var taken []model.Series
+	if err := SELECT(Series.ID).FROM(Series).WHERE(Series.Name.EQ(String(newName))).Query(tx, &taken); err != nil {
+		return err
+	}
+	if len(taken) > 0 {
+		return ErrSeriesExists
+	}
+
+	return moveSeriesGalleries(tx, oldName, newName, author, now)
+}
+
+// moveSeriesGalleries gives the galleries of the series a new series, or none if empty, like a bulk edit of the
+// author, including the galleries in the trash. The changes are stored as revisions and the series is locked, so that
+// scans and restoring galleries don't bring the old one back.
+func moveSeriesGalleries(tx *sql.Tx, name string, newName string, author Author, now time.Time) error {
+	var galleries []model.Gallery
+	if err := SELECT(Gallery.UUID).FROM(Gallery).WHERE(Gallery.Series.EQ(String(name))).Query(tx, &galleries); err != nil {
+		return err
+	}
+
+	edit := BulkEdit{Series: &newName}
+	for _, gallery := range galleries {
+		if _, err := bulkUpdateGallery(tx, gallery.UUID, edit, nil, nil, true, author, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MergeSeries moves the galleries of the other series to the series and removes the other series. Their names,
+// alternative titles and tags are added to the series, and their description and cover are used if it has none.
+// The galleries are moved as an edit of the author.
+func MergeSeries(id int32, otherIDs []int32, author Author) error {
+	tx, err := db().Begin()
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			rollbackTx(tx)
+		}
+	}()
+
+	var series model.Series
+	if err = SELECT(Series.AllColumns).FROM(Series).WHERE(Series.ID.EQ(Int32(id))).Query(tx, &series); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	titles := splitTitles(series.AlternativeTitles)
+	for _, otherID := range otherIDs {
+		if otherID == id {
+			continue
+		}
+
+		var other model.Series
+		if err = SELECT(Series.AllColumns).FROM(Series).WHERE(Series.ID.EQ(Int32(otherID))).Query(tx, &other); err != nil {
+			return err
+		}
+
+		if err = moveSeriesGalleries(tx, other.Name, series.Name, author, now); err != nil {
+			return err
+		}
+
+		titles = append(titles, other.Name)
+		titles = append(titles, splitTitles(other.AlternativeTitles)...)
+		if series.Description == nil {
+			series.Description = other.Description
+		}
+		if series.Cover == nil {
+			series.Cover = other.Cover
+		}
+		if series.Status == nil {
+			series.Status = other.Status
+		}
+
+		var tags []model.SeriesTag
+		tagsStmt := SELECT(SeriesTag.AllColumns).FROM(SeriesTag).WHERE(SeriesTag.SeriesID.EQ(Int32(otherID)))
+		if err = tagsStmt.Query(tx, &tags); err != nil {
+			return err
+		}
+		tagIDs := make([]int32, 0, len(tags))
+		for _, tag := range tags {
+			tagIDs = append(tagIDs, tag.TagID)
+		}
+		if err = insertSeriesTags(tx, id, tagIDs); err != nil {
+			return err
+		}
+
+		if err = deleteSeries(tx, otherID); err != nil {
+			return err
+		}
+	}
+
+	slices.Sort(titles)
+	titles = slices.DeleteFunc(slices.Compact(titles), func(title string) bool { return title == series.Name })
+	series.AlternativeTitles = joinTitles(titles)
+	series.UpdatedAt = now
+
+	stmt := Series.UPDATE(Series.Description, Series.Cover, Series.Status, Series.AlternativeTitles, Series.UpdatedAt).
+		MODEL(series).
+		WHERE(Series.ID.EQ(Int32(id)))
+	if _, err = stmt.Exec(tx); err != nil {
+		return err
+	}
+
+	committed = true
+	return tx.Commit()
+}
+
+// DeleteSeries removes the series. Its galleries are kept without a series as an edit of the author.
+func DeleteSeries(id int32, author Author) error {
+	tx, err := db().Begin()
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			rollbackTx(tx)
+		}
+	}()
+
+	var series model.Series
+	if err = SELECT(Series.AllColumns).FROM(Series).WHERE(Series.ID.EQ(Int32(id))).Query(tx, &series); err != nil {
+		return err
+	}
+
+	stmt := Gallery.UPDATE(Gallery.SeriesOrder).
+		SET(NULL).
+		WHERE(Gallery.Series.EQ(String(series.Name)))
+	if _, err = stmt.Exec(tx); err != nil {
+		return err
+	}
+
+	if err = moveSeriesGalleries(tx, series.Name, "", author, time.Now()); err != nil {
+		return err
+	}
+
+	if err = deleteSeries(tx, id); err != nil {
+		return err
+	}
+
+	committed = true
+	return tx.Commit()
+}
+
+func deleteSeries(tx *sql.Tx, id int32) error {
+	if _, err := SeriesTag.DELETE().WHERE(SeriesTag.SeriesID.EQ(Int32(id))).Exec(tx); err != nil {
+		return err
+	}
+
+	_, err := Series.DELETE().WHERE(Series.ID.EQ(Int32(id))).Exec(tx)
+	return err
+}
+
+// SetSeriesOrder sets the reading order of the galleries of the series, overriding their volume and chapter numbers.
+// Galleries left out are read after the ordered ones. No galleries resets the order back to the numbers.
+func SetSeriesOrder(id int32, galleryUUIDs []string) error {
+	tx, err := db().Begin()
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			rollbackTx(tx)
+		}
+	}()
+
+	var series model.Series
+	if err = SELECT(Series.AllColumns).FROM(Series).WHERE(Series.ID.EQ(Int32(id))).Query(tx, &series); err != nil {
+		return err
+	}
+
+	resetStmt := Gallery.UPDATE(Gallery.SeriesOrder).
+		SET(NULL).
+		WHERE(Gallery.Series.EQ(String(series.Name)))
+	if _, err = resetStmt.Exec(tx); err != nil {
+		return err
+	}
+
+	for i, galleryUUID := range galleryUUIDs {
+		stmt := Gallery.UPDATE(Gallery.SeriesOrder).
+			SET(int32(i + 1)).
+			WHERE(Gallery.UUID.EQ(String(galleryUUID)).AND(Gallery.Series.EQ(String(series.Name))))
+
+		res, err := stmt.Exec(tx)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+			return ErrNotInSeries
+		}
+	}
+
+	committed = true
+	return tx.Commit()
+}
+
+// NextUnread returns the gallery the user should read next from galleries in reading order: the furthest started one
+// if it is not finished, otherwise the one after it. Nil if the last one has been read.
+func NextUnread(galleries []CombinedMetadata) *CombinedMetadata {
+	next := 0
+	for i, gallery := range galleries {
+		if gallery.GalleryPref == nil || gallery.GalleryPref.Progress == 0 {
+			continue
+		}
+
+		next = i
+		if gallery.ImageCount != nil && gallery.GalleryPref.Progress >= *gallery.ImageCount {
+			next = i + 1
+		}
+	}
+
+	if next >= len(galleries) {
+		return nil
+	}
+	return &galleries[next]
+}
+
+// checkInSeries returns ErrNotInSeries if the gallery does not belong to the series.
+func checkInSeries(tx *sql.Tx, name string, galleryUUID string) error {
+	var galleries []model.Gallery
+	stmt := SELECT(Gallery.UUID).
+		FROM(Gallery).
+		WHERE(Gallery.UUID.EQ(String(galleryUUID)).AND(Gallery.Series.EQ(String(name))))
+	if err := stmt.Query(tx, &galleries); err != nil {
+		return err
+	}
+	if len(galleries) == 0 {
+		return ErrNotInSeries
+	}
+
+	return nil
+}
+
+func insertSeriesTags(q qrm.Executable, seriesID int32, tagIDs []int32) error {
+	for _, tagID := range tagIDs {
+		stmt := SeriesTag.
+			INSERT(SeriesTag.SeriesID, SeriesTag.TagID).
+			VALUES(seriesID, tagID).
+			ON_CONFLICT(SeriesTag.SeriesID, SeriesTag.TagID).
+			DO_NOTHING()
+
+		if _, err := stmt.Exec(q); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// joinTitles stores the alternative titles one per line, or nil if there are none.
+func joinTitles(titles []string) *string {
+	var values []string
+	for _, title := range titles {
+		if value := SanitizeString(&title); value != nil && !slices.Contains(values, *value) {
+			values = append(values, *value)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	joined := strings.Join(values, "\n")
+	return &joined
+}
+
+// splitTitles returns the alternative titles stored by joinTitles.
+func splitTitles(titles *string) []string {
+	if titles == nil || *titles == "" {
+		return nil
+	}
+	return strings.Split(*titles, "\n")
+}
+
+// Titles returns the alternative titles of the series.
+func (s SeriesInfo) Titles() []string {
+	return splitTitles(s.AlternativeTitles)
+}
@@ -0,0 +1,216 @@
+package db
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/log"
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	. "github.com/Mangatsu/server/pkg/types/sqlite/table"
+	. "github.com/go-jet/jet/v2/sqlite"
+	"go.uber.org/zap"
+)
+
+type Scope string
+
+// Scopes of the personal access tokens.
+const (
+	ScopeRead     Scope = "read"     // Reading the galleries and other content
+	ScopeProgress Scope = "progress" // Updating reading progress and favorites
+	ScopeAdmin    Scope = "admin"    // Admin tasks such as scanning and managing libraries
+)
+
+// APITokenPrefix is the prefix of all personal access tokens. Used to tell them apart from JWTs.
+const APITokenPrefix = "mtsu_"
+
+var ErrInvalidScope = errors.New("scope must be read, progress or admin")
+
+// VerifiedAPIToken is a valid token and the current role of its user.
+type VerifiedAPIToken struct {
+	UserUUID string
+	Role     Role
+	Scopes   []Scope
+}
+
+// ParseScopes validates the scopes and removes duplicates.
+func ParseScopes(values []string) ([]Scope, error) {
+	scopes := make([]Scope, 0, len(values))
+	for _, value := range values {
+		scope := Scope(value)
+		switch scope {
+		case ScopeRead, ScopeProgress, ScopeAdmin:
+		default:
+			return nil, ErrInvalidScope
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if len(scopes) == 0 {
+		return nil, ErrInvalidScope
+	}
+
+	return scopes, nil
+}
+
+// NewAPIToken creates a personal access token for the user. The plain token is returned only once, as only
+// its SHA-256 hash is stored.
+func NewAPIToken(userUUID string, name string, scopes []Scope, expiresAt *time.Time) (string, model.APIToken, error) {
+	x := make([]byte, 32)
+	if _, err := rand.Read(x); err != nil {
+		return "", model.APIToken{}, err
+	}
+	plainToken := APITokenPrefix + base64.RawURLEncoding.EncodeToString(x)
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", model.APIToken{}, err
+	}
+
+	scopeValues := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scopeValues = append(scopeValues, string(scope))
+	}
+
+	token := model.APIToken{
+		ID:        hex.EncodeToString(id),
+		UserUUID:  userUUID,
+		Name:      name,
+		TokenHash: hashAPIToken(plainToken),
+		Scopes:    strings.Join(scopeValues, ","),
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	if _, err := APIToken.INSERT(APIToken.AllColumns).MODEL(token).Exec(db()); err != nil {
+		return "", model.APIToken{}, err
+	}
+
+	token.TokenHash = ""
+	return plainToken, token, nil
+}
+
+// GetAPITokens returns the tokens of the user. Never returns the hashes.
+func GetAPITokens(userUUID string) ([]model.APIToken, error) {
+	stmt := SELECT(
+		APIToken.ID,
+		APIToken.UserUUID,
+		APIToken.Name,
+		APIToken.Scopes,
+		APIToken.ExpiresAt,
+		APIToken.LastUsedAt,
+		APIToken.CreatedAt,
+	).FROM(
+		APIToken.Table,
+	).WHERE(
+		APIToken.UserUUID.EQ(String(userUUID)),
+	).ORDER_BY(
+		APIToken.CreatedAt.DESC(),
+	)
+
+	var tokens []model.APIToken
+	err := stmt.Query(db(), &tokens)
+	return tokens, err
+}
+
+// DeleteAPIToken revokes a token of the user.
+func DeleteAPIToken(id string, userUUID string) error {
+	stmt := APIToken.DELETE().WHERE(APIToken.ID.EQ(String(id)).AND(APIToken.UserUUID.EQ(String(userUUID))))
+
+	res, err := stmt.Exec(db())
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// VerifyAPIToken returns the user and scopes of a valid, unexpired token, and updates when it was last used.
+func VerifyAPIToken(plainToken string) (*VerifiedAPIToken, error) {
+	stmt := SELECT(
+		APIToken.ID,
+		APIToken.UserUUID,
+		APIToken.Scopes,
+		APIToken.ExpiresAt,
+		User.Role,
+	).FROM(
+		APIToken.INNER_JOIN(User, User.UUID.EQ(APIToken.UserUUID)),
+	).WHERE(
+		APIToken.TokenHash.EQ(String(hashAPIToken(plainToken))),
+	)
+
+	var tokens []struct {
+		model.APIToken
+		User model.User
+	}
+	if err := stmt.Query(db(), &tokens); err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	token := tokens[0]
+	now := time.Now()
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		return nil, sql.ErrNoRows
+	}
+
+	updateStmt := APIToken.UPDATE(APIToken.LastUsedAt).SET(now).WHERE(APIToken.ID.EQ(String(token.ID)))
+	if _, err := updateStmt.Exec(db()); err != nil {
+		log.Z.Debug("failed to update token usage", zap.String("id", token.ID), zap.String("err", err.Error()))
+	}
+
+	var scopes []Scope
+	for _, scope := range strings.Split(token.Scopes, ",") {
+		scopes = append(scopes, Scope(scope))
+	}
+
+	return &VerifiedAPIToken{
+		UserUUID: token.UserUUID,
+		Role:     Role(token.User.Role),
+		Scopes:   scopes,
+	}, nil
+}
+
+// PruneExpiredAPITokens removes all expired tokens.
+func PruneExpiredAPITokens() {
+	var tokens []model.APIToken
+	stmt := SELECT(APIToken.ID, APIToken.ExpiresAt).FROM(APIToken).WHERE(APIToken.ExpiresAt.IS_NOT_NULL())
+	if err := stmt.Query(db(), &tokens); err != nil {
+		log.Z.Error("failed to prune expired tokens", zap.String("err", err.Error()))
+		return
+	}
+
+	// Expiry is compared here, as the timestamps are stored with the time zone offset.
+	var expiredIDs []Expression
+	now := time.Now()
+	for _, token := range tokens {
+		if now.After(*token.ExpiresAt) {
+			expiredIDs = append(expiredIDs, String(token.ID))
+		}
+	}
+	if len(expiredIDs) == 0 {
+		return
+	}
+
+	if _, err := APIToken.DELETE().WHERE(APIToken.ID.IN(expiredIDs...)).Exec(db()); err != nil {
+		log.Z.Error("failed to prune expired tokens", zap.String("err", err.Error()))
+	}
+}
+
+func hashAPIToken(plainToken string) string {
+	hash := sha256.Sum256([]byte(plainToken))
+	return hex.EncodeToString(hash[:])
+}
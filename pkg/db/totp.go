@@ -0,0 +1,267 @@
+package db
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/log"
+	"github.com/Mangatsu/server/pkg/totp"
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	. "github.com/Mangatsu/server/pkg/types/sqlite/table"
+	. "github.com/go-jet/jet/v2/sqlite"
+	"go.uber.org/zap"
+)
+
+const recoveryCodeCount = 10
+
+var ErrTOTPEnabled = errors.New("two-factor authentication already enabled")
+var ErrTOTPNotEnrolled = errors.New("two-factor authentication not enrolled")
+var ErrInvalidCode = errors.New("invalid code")
+
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// TOTPEnabled returns true if the user has enabled two-factor authentication.
+func TOTPEnabled(userUUID string) bool {
+	var users []model.User
+	stmt := SELECT(User.TotpEnabled).FROM(User).WHERE(User.UUID.EQ(String(userUUID)))
+	if err := stmt.Query(db(), &users); err != nil {
+		log.Z.Debug("failed to check two-factor authentication", zap.String("err", err.Error()))
+		return false
+	}
+
+	return len(users) > 0 && users[0].TotpEnabled
+}
+
+// StartTOTPEnrollment generates a new secret for the user. It is enabled once confirmed with a valid code.
+// Returns the secret and the username.
+func StartTOTPEnrollment(userUUID string) (string, string, error) {
+	user, err := getTOTPUser(userUUID)
+	if err != nil {
+		return "", "", err
+	}
+	if user.TotpEnabled {
+		return "", "", ErrTOTPEnabled
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	stmt := User.UPDATE(User.TotpSecret, User.TotpLastStep, User.UpdatedAt).
+		SET(String(secret), Int64(0), time.Now()).
+		WHERE(User.UUID.EQ(String(userUUID)))
+	if _, err = stmt.Exec(db()); err != nil {
+		return "", "", err
+	}
+
+	return secret, user.Username, nil
+}
+
+// ConfirmTOTPEnrollment enables two-factor authentication if the code matches the pending secret.
+// Returns new recovery codes.
+func ConfirmTOTPEnrollment(userUUID string, code string) ([]string, error) {
+	user, err := getTOTPUser(userUUID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TotpEnabled {
+		return nil, ErrTOTPEnabled
+	}
+	if user.TotpSecret == nil {
+		return nil, ErrTOTPNotEnrolled
+	}
+
+	matchedStep, ok := totp.Validate(*user.TotpSecret, code, time.Now(), user.TotpLastStep)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	tx, err := db().Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			rollbackTx(tx)
+		}
+	}()
+
+	stmt := User.UPDATE(User.TotpEnabled, User.TotpLastStep, User.UpdatedAt).
+		SET(Bool(true), Int64(matchedStep), time.Now()).
+		WHERE(User.UUID.EQ(String(userUUID)))
+	if _, err = stmt.Exec(tx); err != nil {
+		return nil, err
+	}
+
+	codes, err := replaceRecoveryCodes(tx, userUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	committed = true
+	return codes, tx.Commit()
+}
+
+// VerifySecondFactor checks a TOTP code or an unused recovery code of the user. Both can only be used once.
+func VerifySecondFactor(userUUID string, code string) (bool, error) {
+	user, err := getTOTPUser(userUUID)
+	if err != nil {
+		return false, err
+	}
+	if !user.TotpEnabled || user.TotpSecret == nil {
+		return false, ErrTOTPNotEnrolled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		matchedStep, ok := totp.Validate(*user.TotpSecret, code, time.Now(), user.TotpLastStep)
+		if !ok {
+			return false, nil
+		}
+
+		// Only the first of concurrent logins with the same code succeeds.
+		stmt := User.UPDATE(User.TotpLastStep).
+			SET(Int64(matchedStep)).
+			WHERE(User.UUID.EQ(String(userUUID)).AND(User.TotpLastStep.LT(Int64(matchedStep))))
+		res, err := stmt.Exec(db())
+		if err != nil {
+			return false, err
+		}
+		rowsAffected, _ := res.RowsAffected()
+		return rowsAffected == 1, nil
+	}
+
+	stmt := RecoveryCode.UPDATE(RecoveryCode.UsedAt).
+		SET(time.Now()).
+		WHERE(
+			RecoveryCode.UserUUID.EQ(String(userUUID)).
+				AND(RecoveryCode.CodeHash.EQ(String(hashRecoveryCode(code)))).
+				AND(RecoveryCode.UsedAt.IS_NULL()),
+		)
+	res, err := stmt.Exec(db())
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, _ := res.RowsAffected()
+	return rowsAffected == 1, nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user.
+func RegenerateRecoveryCodes(userUUID string) ([]string, error) {
+	if !TOTPEnabled(userUUID) {
+		return nil, ErrTOTPNotEnrolled
+	}
+
+	tx, err := db().Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			rollbackTx(tx)
+		}
+	}()
+
+	codes, err := replaceRecoveryCodes(tx, userUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	committed = true
+	return codes, tx.Commit()
+}
+
+// DisableTOTP disables two-factor authentication and removes the secret and recovery codes of the user.
+func DisableTOTP(userUUID string) error {
+	tx, err := db().Begin()
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			rollbackTx(tx)
+		}
+	}()
+
+	stmt := User.UPDATE(User.TotpSecret, User.TotpEnabled, User.TotpLastStep, User.UpdatedAt).
+		SET(NULL, Bool(false), Int64(0), time.Now()).
+		WHERE(User.UUID.EQ(String(userUUID)))
+	res, err := stmt.Exec(tx)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err = RecoveryCode.DELETE().WHERE(RecoveryCode.UserUUID.EQ(String(userUUID))).Exec(tx); err != nil {
+		return err
+	}
+
+	committed = true
+	return tx.Commit()
+}
+
+func getTOTPUser(userUUID string) (model.User, error) {
+	stmt := SELECT(User.Username, User.TotpSecret, User.TotpEnabled, User.TotpLastStep).
+		FROM(User).
+		WHERE(User.UUID.EQ(String(userUUID)))
+
+	var users []model.User
+	if err := stmt.Query(db(), &users); err != nil {
+		return model.User{}, err
+	}
+	if len(users) == 0 {
+		return model.User{}, sql.ErrNoRows
+	}
+
+	return users[0], nil
+}
+
+// replaceRecoveryCodes removes the old recovery codes and returns new ones. Only their hashes are stored.
+func replaceRecoveryCodes(tx *sql.Tx, userUUID string) ([]string, error) {
+	if _, err := RecoveryCode.DELETE().WHERE(RecoveryCode.UserUUID.EQ(String(userUUID))).Exec(tx); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	codes := make([]string, 0, recoveryCodeCount)
+	stmt := RecoveryCode.INSERT(RecoveryCode.UserUUID, RecoveryCode.CodeHash, RecoveryCode.CreatedAt)
+	for i := 0; i < recoveryCodeCount; i++ {
+		x := make([]byte, 7)
+		if _, err := rand.Read(x); err != nil {
+			return nil, err
+		}
+
+		encoded := recoveryCodeEncoding.EncodeToString(x)[:10]
+		code := encoded[:5] + "-" + encoded[5:]
+		codes = append(codes, code)
+		stmt = stmt.VALUES(userUUID, hashRecoveryCode(code), now)
+	}
+
+	if _, err := stmt.Exec(tx); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// hashRecoveryCode returns the SHA-256 of the code, ignoring case and dashes.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(code, "-", ""), " ", ""))
+	hash := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(hash[:])
+}
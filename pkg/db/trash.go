@@ -0,0 +1,138 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	. "github.com/Mangatsu/server/pkg/types/sqlite/table"
+	. "github.com/go-jet/jet/v2/sqlite"
+)
+
+// LocatedGallery is a gallery with the library it belongs to.
+type LocatedGallery struct {
+	model.Gallery
+	Library model.Library
+}
+
+// GetLocatedGallery returns the gallery and its library. Deleted selects whether the gallery is looked up from the
+// trash or from the library.
+func GetLocatedGallery(galleryUUID string, deleted bool) (LocatedGallery, error) {
+	condition := Gallery.UUID.EQ(String(galleryUUID))
+	if deleted {
+		condition = condition.AND(Gallery.Deleted.IS_TRUE())
+	} else {
+		condition = condition.AND(Gallery.Deleted.IS_NOT_TRUE())
+	}
+
+	stmt := SELECT(Gallery.AllColumns, Library.AllColumns).
+		FROM(Gallery.INNER_JOIN(Library, Library.ID.EQ(Gallery.LibraryID))).
+		WHERE(condition)
+
+	var galleries []LocatedGallery
+	if err := stmt.Query(db(), &galleries); err != nil {
+		return LocatedGallery{}, err
+	}
+	if len(galleries) == 0 {
+		return LocatedGallery{}, sql.ErrNoRows
+	}
+
+	return galleries[0], nil
+}
+
+// GetTrash returns the galleries in the trash, the latest deleted first.
+func GetTrash() ([]LocatedGallery, error) {
+	stmt := SELECT(Gallery.AllColumns, Library.AllColumns).
+		FROM(Gallery.INNER_JOIN(Library, Library.ID.EQ(Gallery.LibraryID))).
+		WHERE(Gallery.Deleted.IS_TRUE()).
+		ORDER_BY(Gallery.DeletedAt.DESC())
+
+	var galleries []LocatedGallery
+	err := stmt.Query(db(), &galleries)
+	return galleries, err
+}
+
+// SetGalleryDeleted moves the gallery to the trash or restores it from there.
+// The change is stored as a revision.
+func SetGalleryDeleted(galleryUUID string, deleted bool, author Author) error {
+	tx, err := db().Begin()
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			rollbackTx(tx)
+		}
+	}()
+
+	now := time.Now()
+	galleryModel := model.Gallery{Deleted: deleted, UpdatedAt: now}
+	if deleted {
+		galleryModel.DeletedAt = &now
+	}
+
+	stmt := Gallery.UPDATE(Gallery.Deleted, Gallery.DeletedAt, Gallery.UpdatedAt).
+		MODEL(galleryModel).
+		WHERE(Gallery.UUID.EQ(String(galleryUUID)).AND(Gallery.Deleted.EQ(Bool(!deleted))))
+
+	res, err := stmt.Exec(tx)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	changes := []FieldChange{{Field: "Deleted", Old: !deleted, New: deleted}}
+	if err = insertRevision(tx, galleryUUID, author, changes, now); err != nil {
+		return err
+	}
+
+	committed = true
+	return tx.Commit()
+}
+
+// PurgeGallery permanently removes a gallery in the trash and everything related to it from the database.
+func PurgeGallery(galleryUUID string) error {
+	tx, err := db().Begin()
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			rollbackTx(tx)
+		}
+	}()
+
+	res, err := Gallery.DELETE().
+		WHERE(Gallery.UUID.EQ(String(galleryUUID)).AND(Gallery.Deleted.IS_TRUE())).
+		Exec(tx)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	// Foreign keys aren't enforced by SQLite by default, so the related rows are removed explicitly.
+	relatedStmts := []DeleteStatement{
+		GalleryTag.DELETE().WHERE(GalleryTag.GalleryUUID.EQ(String(galleryUUID))),
+		Reference.DELETE().WHERE(Reference.GalleryUUID.EQ(String(galleryUUID))),
+		GalleryPref.DELETE().WHERE(GalleryPref.GalleryUUID.EQ(String(galleryUUID))),
+		GalleryRevision.DELETE().WHERE(GalleryRevision.GalleryUUID.EQ(String(galleryUUID))),
+		GalleryLock.DELETE().WHERE(GalleryLock.GalleryUUID.EQ(String(galleryUUID))),
+		MetaCandidate.DELETE().WHERE(MetaCandidate.GalleryUUID.EQ(String(galleryUUID))),
+	}
+	for _, stmt := range relatedStmts {
+		if _, err = stmt.Exec(tx); err != nil {
+			return err
+		}
+	}
+
+	committed = true
+	return tx.Commit()
+}
@@ -8,11 +8,13 @@ import (
 	"github.com/Mangatsu/server/pkg/types/sqlite/model"
 	. "github.com/Mangatsu/server/pkg/types/sqlite/table"
 	"github.com/Mangatsu/server/pkg/utils"
+	"github.com/go-jet/jet/v2/qrm"
 	. "github.com/go-jet/jet/v2/sqlite"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"io"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -20,6 +22,7 @@ type UserForm struct {
 	Password *string `json:"password"`
 	Username *string `json:"username"`
 	Role     *string `json:"role"`
+	HideNsfw *bool   `json:"hideNsfw"`
 }
 
 type FavoriteGroups struct {
@@ -36,6 +39,33 @@ const (
 	NoRole     Role = 0
 )
 
+// ParseRole returns the role by its name (superadmin, admin, member or viewer) or value.
+// Names of roles above maxRole are rejected and values are clamped to it.
+func ParseRole(value string, maxRole Role) (Role, bool) {
+	role := NoRole
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "superadmin":
+		role = SuperAdmin
+	case "admin":
+		role = Admin
+	case "member":
+		role = Member
+	case "viewer":
+		role = Viewer
+	default:
+		number, err := strconv.ParseInt(strings.TrimSpace(value), 10, 16)
+		if err != nil || number < int64(NoRole) {
+			return NoRole, false
+		}
+		return Role(utils.Clamp(number, int64(NoRole), int64(maxRole))), true
+	}
+
+	if role > maxRole {
+		return NoRole, false
+	}
+	return role, true
+}
+
 // GetUser returns a user from the database.
 func GetUser(name string) ([]model.User, error) {
 	stmt := SELECT(
@@ -57,6 +87,10 @@ func GetUsers() ([]model.User, error) {
 		User.UUID,
 		User.Username,
 		User.Role,
+		User.HideNsfw,
+		User.TotpEnabled,
+		User.LockedUntil,
+		User.Pending,
 		User.CreatedAt,
 		User.UpdatedAt,
 	).FROM(
@@ -83,6 +117,15 @@ func GetFavoriteGroups(userUUID string) ([]string, error) {
 
 // Register registers a new user.
 func Register(username string, password string, role Role) error {
+	return registerUser(db(), username, password, role, false)
+}
+
+// RegisterPending registers a user that cannot log in before an admin approves them.
+func RegisterPending(username string, password string, role Role) error {
+	return registerUser(db(), username, password, role, true)
+}
+
+func registerUser(q qrm.Executable, username string, password string, role Role, pending bool) error {
 	now := time.Now()
 
 	hashSalt, err := utils.DefaultArgon2idHash().GenerateHash([]byte(password), nil)
@@ -98,13 +141,45 @@ func Register(username string, password string, role Role) error {
 	}
 
 	insertUser := User.
-		INSERT(User.UUID, User.Username, User.Password, User.Salt, User.Role, User.CreatedAt, User.UpdatedAt).
-		VALUES(userUUID.String(), username, hashSalt.Hash, hashSalt.Salt, role, now, now)
+		INSERT(User.UUID, User.Username, User.Password, User.Salt, User.Role, User.Pending, User.CreatedAt, User.UpdatedAt).
+		VALUES(userUUID.String(), username, hashSalt.Hash, hashSalt.Salt, role, pending, now, now)
 
-	_, err = insertUser.Exec(db())
+	_, err = insertUser.Exec(q)
 	return err
 }
 
+// ApproveUser allows a pending user to log in.
+func ApproveUser(userUUID string) error {
+	stmt := User.UPDATE(User.Pending, User.UpdatedAt).
+		SET(Bool(false), time.Now()).
+		WHERE(User.UUID.EQ(String(userUUID)).AND(User.Pending.IS_TRUE()))
+
+	res, err := stmt.Exec(db())
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// RejectUser removes a pending user.
+func RejectUser(userUUID string) error {
+	stmt := User.DELETE().WHERE(User.UUID.EQ(String(userUUID)).AND(User.Pending.IS_TRUE()))
+
+	res, err := stmt.Exec(db())
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
 // Login logs the user in and returns the UUID of the user.
 func Login(username string, password string, role Role) (*string, *int32, error) {
 	result, err := GetUser(username)
@@ -171,7 +246,7 @@ func VerifySession(id string, userUUID string) bool {
 	return len(sessions) > 0
 }
 
-// UpdateUser can be used to update role, password or username of users.
+// UpdateUser can be used to update role, NSFW visibility, password or username of users.
 func UpdateUser(userUUID string, userForm *UserForm) error {
 	now := time.Now()
 
@@ -203,6 +278,16 @@ func UpdateUser(userUUID string, userForm *UserForm) error {
 		}
 	}
 
+	if userForm.HideNsfw != nil {
+		updateUserStmt := User.
+			UPDATE(User.HideNsfw, User.UpdatedAt).
+			SET(*userForm.HideNsfw, now).
+			WHERE(User.UUID.EQ(String(userUUID)))
+		if _, err = updateUserStmt.Exec(tx); err != nil {
+			return err
+		}
+	}
+
 	if userForm.Username != nil && *userForm.Username != "" {
 		updateUserStmt := User.
 			UPDATE(User.Username, User.UpdatedAt).
@@ -0,0 +1,31 @@
+package db
+
+import "testing"
+
+func TestParseRole(t *testing.T) {
+	tests := []struct {
+		value   string
+		maxRole Role
+		role    Role
+		ok      bool
+	}{
+		{"viewer", Admin, Viewer, true},
+		{" Member ", Admin, Member, true},
+		{"ADMIN", Admin, Admin, true},
+		{"superadmin", Admin, NoRole, false},
+		{"superadmin", SuperAdmin, SuperAdmin, true},
+		{"50", Admin, 50, true},
+		{"255", Admin, Admin, true},
+		{"255", SuperAdmin, SuperAdmin, true},
+		{"-1", SuperAdmin, NoRole, false},
+		{"owner", SuperAdmin, NoRole, false},
+	}
+
+	for _, test := range tests {
+		role, ok := ParseRole(test.value, test.maxRole)
+		if role != test.role || ok != test.ok {
+			t.Errorf("%q with max %d: expected %d and %v, got %d and %v",
+				test.value, test.maxRole, test.role, test.ok, role, ok)
+		}
+	}
+}
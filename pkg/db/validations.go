@@ -73,12 +73,16 @@ func ValidateReference(reference model.Reference) model.Reference {
 }
 
 // ValidateGalleryInternal returns updated and sanitized gallery model and column list.
-// Non-empty and non-negative values are preferred.
+// Non-empty and non-negative values are preferred. Scans never unhide a gallery or clear its NSFW flag,
+// as parsers leave them false when the source has no such information.
 // For internal scanner use.
-func ValidateGalleryInternal(newGallery model.Gallery, now time.Time) (model.Gallery, ColumnList) {
+func ValidateGalleryInternal(gallery model.Gallery, newGallery model.Gallery, now time.Time) (model.Gallery, ColumnList) {
 	galleryModel := model.Gallery{}
 	galleryUpdateColumnList := ColumnList{}
 
+	newGallery.Hidden = gallery.Hidden
+	newGallery.Nsfw = newGallery.Nsfw || gallery.Nsfw
+
 	// Title cannot be empty or nil
 	if value := strings.TrimSpace(newGallery.Title); value != "" {
 		galleryUpdateColumnList = append(galleryUpdateColumnList, Gallery.Title)
@@ -159,7 +163,7 @@ func ValidateReferenceInternal(reference model.Reference) model.Reference {
 	}
 
 	if reference.MetaMatch != nil {
-		referenceModel.MetaPath = reference.MetaPath
+		referenceModel.MetaMatch = reference.MetaMatch
 	}
 
 	if value := SanitizeString(reference.Urls); value != nil {
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	. "github.com/Mangatsu/server/pkg/types/sqlite/table"
+)
+
+func TestValidateGalleryInternal(t *testing.T) {
+	series := "Parsed Series"
+	blank := "  "
+	category := "Manga"
+
+	tests := []struct {
+		name       string
+		prev       model.Gallery
+		parsed     model.Gallery
+		nsfw       bool
+		hidden     bool
+		title      string
+		series     *string
+		category   *string
+		hasColumns []string
+	}{
+		{
+			name:       "parsed fields are applied",
+			prev:       model.Gallery{Title: "old title"},
+			parsed:     model.Gallery{Title: " new title ", Series: &series},
+			title:      "new title",
+			series:     &series,
+			hasColumns: []string{"title", "series"},
+		},
+		{
+			name:     "empty values are skipped",
+			prev:     model.Gallery{Title: "old title", Category: &category},
+			parsed:   model.Gallery{Title: "", Category: &blank},
+			category: nil,
+		},
+		{
+			name:   "hidden is kept",
+			prev:   model.Gallery{Hidden: true},
+			parsed: model.Gallery{},
+			hidden: true,
+		},
+		{
+			name:   "nsfw is not cleared",
+			prev:   model.Gallery{Nsfw: true},
+			parsed: model.Gallery{},
+			nsfw:   true,
+		},
+		{
+			name:   "nsfw is set",
+			prev:   model.Gallery{},
+			parsed: model.Gallery{Nsfw: true, Hidden: true},
+			nsfw:   true,
+		},
+	}
+
+	now := time.Now()
+	for _, test := range tests {
+		gallery, columns := ValidateGalleryInternal(test.prev, test.parsed, now)
+
+		if gallery.Nsfw != test.nsfw || gallery.Hidden != test.hidden {
+			t.Errorf("%s: expected nsfw %v and hidden %v, got %v and %v",
+				test.name, test.nsfw, test.hidden, gallery.Nsfw, gallery.Hidden)
+		}
+		if gallery.Title != test.title {
+			t.Errorf("%s: expected title %q, got %q", test.name, test.title, gallery.Title)
+		}
+		if !equalStrings(gallery.Series, test.series) || !equalStrings(gallery.Category, test.category) {
+			t.Errorf("%s: expected series %v and category %v, got %v and %v",
+				test.name, test.series, test.category, gallery.Series, gallery.Category)
+		}
+		if !gallery.UpdatedAt.Equal(now) {
+			t.Errorf("%s: expected updated at %v, got %v", test.name, now, gallery.UpdatedAt)
+		}
+
+		names := map[string]bool{}
+		for _, column := range columns {
+			names[column.Name()] = true
+		}
+		for _, name := range append(test.hasColumns, Gallery.Nsfw.Name(), Gallery.Hidden.Name()) {
+			if !names[name] {
+				t.Errorf("%s: expected column %s to be updated", test.name, name)
+			}
+		}
+		if test.title == "" && names[Gallery.Title.Name()] {
+			t.Errorf("%s: expected an empty title not to be updated", test.name)
+		}
+	}
+}
+
+func equalStrings(a *string, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
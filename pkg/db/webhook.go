@@ -0,0 +1,267 @@
+package db
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/log"
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	. "github.com/Mangatsu/server/pkg/types/sqlite/table"
+	. "github.com/go-jet/jet/v2/sqlite"
+	"go.uber.org/zap"
+)
+
+type DeliveryStatus string
+
+// Statuses of the webhook deliveries.
+const (
+	DeliveryPending   DeliveryStatus = "pending"   // Waiting for the first attempt or a retry
+	DeliveryDelivered DeliveryStatus = "delivered" // Answered with 2xx
+	DeliveryFailed    DeliveryStatus = "failed"    // No attempts left
+)
+
+// webhookDeliveryRetention is how long the finished deliveries are kept.
+const webhookDeliveryRetention = time.Hour * 24 * 30
+
+// WebhookEdit holds the changed fields of a webhook. Nil fields are left as they are.
+type WebhookEdit struct {
+	Name    *string
+	URL     *string
+	Events  []string
+	Enabled *bool
+}
+
+// WebhookDeliveryFilters filters the deliveries shown to admins.
+type WebhookDeliveryFilters struct {
+	Status DeliveryStatus
+	Limit  int64
+	Offset int64
+}
+
+// NewWebhook registers a webhook with a random secret for signing the payloads. No events means all events.
+func NewWebhook(name string, url string, events []string, enabled bool) (model.Webhook, error) {
+	x := make([]byte, 32)
+	if _, err := rand.Read(x); err != nil {
+		return model.Webhook{}, err
+	}
+
+	now := time.Now()
+	stmt := Webhook.INSERT(Webhook.MutableColumns).
+		MODEL(model.Webhook{
+			Name:      name,
+			URL:       url,
+			Secret:    hex.EncodeToString(x),
+			Events:    strings.Join(events, ","),
+			Enabled:   enabled,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}).
+		RETURNING(Webhook.AllColumns)
+
+	var webhooks []model.Webhook
+	if err := stmt.Query(db(), &webhooks); err != nil {
+		return model.Webhook{}, err
+	}
+	if len(webhooks) == 0 {
+		return model.Webhook{}, sql.ErrNoRows
+	}
+
+	return webhooks[0], nil
+}
+
+// GetWebhooks returns all webhooks with their secrets.
+func GetWebhooks() ([]model.Webhook, error) {
+	var webhooks []model.Webhook
+	err := SELECT(Webhook.AllColumns).FROM(Webhook).ORDER_BY(Webhook.ID.ASC()).Query(db(), &webhooks)
+	return webhooks, err
+}
+
+// GetWebhook returns the webhook with its secret.
+func GetWebhook(id int32) (model.Webhook, error) {
+	var webhook model.Webhook
+	err := SELECT(Webhook.AllColumns).FROM(Webhook).WHERE(Webhook.ID.EQ(Int32(id))).Query(db(), &webhook)
+	return webhook, err
+}
+
+// UpdateWebhook changes the webhook. The secret is kept.
+func UpdateWebhook(id int32, edit WebhookEdit) (model.Webhook, error) {
+	webhook, err := GetWebhook(id)
+	if err != nil {
+		return model.Webhook{}, err
+	}
+
+	if edit.Name != nil {
+		webhook.Name = *edit.Name
+	}
+	if edit.URL != nil {
+		webhook.URL = *edit.URL
+	}
+	if edit.Events != nil {
+		webhook.Events = strings.Join(edit.Events, ",")
+	}
+	if edit.Enabled != nil {
+		webhook.Enabled = *edit.Enabled
+	}
+	webhook.UpdatedAt = time.Now()
+
+	stmt := Webhook.UPDATE(Webhook.Name, Webhook.URL, Webhook.Events, Webhook.Enabled, Webhook.UpdatedAt).
+		MODEL(webhook).
+		WHERE(Webhook.ID.EQ(Int32(id)))
+	if _, err = stmt.Exec(db()); err != nil {
+		return model.Webhook{}, err
+	}
+
+	return webhook, nil
+}
+
+// DeleteWebhook removes the webhook and its deliveries.
+func DeleteWebhook(id int32) error {
+	tx, err := db().Begin()
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			rollbackTx(tx)
+		}
+	}()
+
+	res, err := Webhook.DELETE().WHERE(Webhook.ID.EQ(Int32(id))).Exec(tx)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err = WebhookDelivery.DELETE().WHERE(WebhookDelivery.WebhookID.EQ(Int32(id))).Exec(tx); err != nil {
+		return err
+	}
+
+	committed = true
+	return tx.Commit()
+}
+
+// NewWebhookDeliveries queues the payload of an event to the webhooks. Delivered as soon as possible.
+func NewWebhookDeliveries(webhookIDs []int32, event string, payload string) error {
+	if len(webhookIDs) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	stmt := WebhookDelivery.INSERT(
+		WebhookDelivery.WebhookID,
+		WebhookDelivery.Event,
+		WebhookDelivery.Payload,
+		WebhookDelivery.Status,
+		WebhookDelivery.Attempts,
+		WebhookDelivery.NextAttemptAt,
+		WebhookDelivery.CreatedAt,
+	)
+	for _, webhookID := range webhookIDs {
+		stmt = stmt.VALUES(webhookID, event, payload, string(DeliveryPending), 0, now, now)
+	}
+
+	_, err := stmt.Exec(db())
+	return err
+}
+
+// GetPendingWebhookDeliveries returns the deliveries waiting for an attempt, oldest first.
+func GetPendingWebhookDeliveries() ([]model.WebhookDelivery, error) {
+	stmt := SELECT(WebhookDelivery.AllColumns).
+		FROM(WebhookDelivery).
+		WHERE(WebhookDelivery.Status.EQ(String(string(DeliveryPending)))).
+		ORDER_BY(WebhookDelivery.ID.ASC())
+
+	var deliveries []model.WebhookDelivery
+	err := stmt.Query(db(), &deliveries)
+	return deliveries, err
+}
+
+// UpdateWebhookDelivery records the result of an attempt.
+func UpdateWebhookDelivery(delivery model.WebhookDelivery) error {
+	stmt := WebhookDelivery.UPDATE(
+		WebhookDelivery.Status,
+		WebhookDelivery.Attempts,
+		WebhookDelivery.NextAttemptAt,
+		WebhookDelivery.LastAttemptAt,
+		WebhookDelivery.ResponseStatus,
+		WebhookDelivery.Error,
+	).MODEL(delivery).WHERE(WebhookDelivery.ID.EQ(Int32(delivery.ID)))
+
+	_, err := stmt.Exec(db())
+	return err
+}
+
+// GetWebhookDeliveries returns the latest deliveries of the webhook.
+func GetWebhookDeliveries(webhookID int32, filters WebhookDeliveryFilters) ([]model.WebhookDelivery, error) {
+	condition := WebhookDelivery.WebhookID.EQ(Int32(webhookID))
+	if filters.Status != "" {
+		condition = condition.AND(WebhookDelivery.Status.EQ(String(string(filters.Status))))
+	}
+
+	stmt := SELECT(WebhookDelivery.AllColumns).
+		FROM(WebhookDelivery).
+		WHERE(condition).
+		ORDER_BY(WebhookDelivery.ID.DESC()).
+		LIMIT(filters.Limit).
+		OFFSET(filters.Offset)
+
+	var deliveries []model.WebhookDelivery
+	err := stmt.Query(db(), &deliveries)
+	return deliveries, err
+}
+
+// RetryWebhookDelivery queues a finished delivery again with all of its attempts.
+func RetryWebhookDelivery(webhookID int32, deliveryID int32) error {
+	stmt := WebhookDelivery.UPDATE(WebhookDelivery.Status, WebhookDelivery.Attempts, WebhookDelivery.NextAttemptAt).
+		SET(string(DeliveryPending), 0, time.Now()).
+		WHERE(
+			WebhookDelivery.ID.EQ(Int32(deliveryID)).
+				AND(WebhookDelivery.WebhookID.EQ(Int32(webhookID))).
+				AND(WebhookDelivery.Status.NOT_EQ(String(string(DeliveryPending)))),
+		)
+
+	res, err := stmt.Exec(db())
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// PruneWebhookDeliveries removes delivered and failed deliveries older than 30 days.
+func PruneWebhookDeliveries() {
+	var deliveries []model.WebhookDelivery
+	stmt := SELECT(WebhookDelivery.ID, WebhookDelivery.CreatedAt).
+		FROM(WebhookDelivery).
+		WHERE(WebhookDelivery.Status.NOT_EQ(String(string(DeliveryPending))))
+	if err := stmt.Query(db(), &deliveries); err != nil {
+		log.Z.Error("failed to prune webhook deliveries", zap.String("err", err.Error()))
+		return
+	}
+
+	// Age is compared here, as the timestamps are stored with the time zone offset.
+	var prunedIDs []Expression
+	cutoff := time.Now().Add(-webhookDeliveryRetention)
+	for _, delivery := range deliveries {
+		if delivery.CreatedAt.Before(cutoff) {
+			prunedIDs = append(prunedIDs, Int32(delivery.ID))
+		}
+	}
+	if len(prunedIDs) == 0 {
+		return
+	}
+
+	if _, err := WebhookDelivery.DELETE().WHERE(WebhookDelivery.ID.IN(prunedIDs...)).Exec(db()); err != nil {
+		log.Z.Error("failed to prune webhook deliveries", zap.String("err", err.Error()))
+	}
+}
@@ -0,0 +1,129 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+type Type string
+
+// Types of the events.
+const (
+	GalleryAdded       Type = "gallery_added"
+	ThumbnailGenerated Type = "thumbnail_generated"
+	MetadataParsed     Type = "metadata_parsed"
+	Error              Type = "error"
+	TaskStarted        Type = "task_started"
+	TaskFinished       Type = "task_finished"
+)
+
+type Task string
+
+// Tasks the events belong to.
+const (
+	TaskScan       Task = "scan"
+	TaskThumbnails Task = "thumbnails"
+	TaskMetadata   Task = "metadata"
+)
+
+// historySize is how many of the latest events are kept for reconnecting subscribers.
+const historySize = 256
+
+// subscriberBuffer is how many events can wait for a subscriber before it is dropped.
+const subscriberBuffer = 64
+
+type Event struct {
+	ID          uint64
+	Type        Type
+	Task        Task              `json:",omitempty"`
+	GalleryUUID string            `json:",omitempty"`
+	UUIDOrPath  string            `json:",omitempty"` // Gallery or file the error is about
+	Error       string            `json:",omitempty"`
+	Details     map[string]string `json:",omitempty"`
+	Time        time.Time
+}
+
+// Subscription receives the published events from C. C is closed when the subscription is cancelled, or when
+// the subscriber falls too far behind. The subscriber can then subscribe again with the ID of the last event.
+type Subscription struct {
+	C <-chan Event
+	c chan Event
+}
+
+// Bus delivers the events to the subscribers. Publishing never blocks.
+type Bus struct {
+	mu          sync.Mutex
+	lastID      uint64
+	history     []Event
+	subscribers map[*Subscription]struct{}
+}
+
+// DefaultBus is used by the tasks and the API.
+var DefaultBus = NewBus()
+
+func NewBus() *Bus {
+	return &Bus{
+		history:     make([]Event, 0, historySize),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish sets the ID and time of the event and delivers it to the subscribers.
+func (b *Bus) Publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event.ID = b.lastID
+	event.Time = time.Now()
+
+	if len(b.history) == historySize {
+		copy(b.history, b.history[1:])
+		b.history = b.history[:historySize-1]
+	}
+	b.history = append(b.history, event)
+
+	for subscription := range b.subscribers {
+		select {
+		case subscription.c <- event:
+		default:
+			delete(b.subscribers, subscription)
+			close(subscription.c)
+		}
+	}
+}
+
+// Subscribe returns a subscription to the new events, and the kept events published after lastID.
+func (b *Bus) Subscribe(lastID uint64) (*Subscription, []Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []Event
+	for _, event := range b.history {
+		if event.ID > lastID {
+			missed = append(missed, event)
+		}
+	}
+
+	c := make(chan Event, subscriberBuffer)
+	subscription := &Subscription{C: c, c: c}
+	b.subscribers[subscription] = struct{}{}
+
+	return subscription, missed
+}
+
+// Unsubscribe cancels the subscription.
+func (b *Bus) Unsubscribe(subscription *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[subscription]; ok {
+		delete(b.subscribers, subscription)
+		close(subscription.c)
+	}
+}
+
+// Publish publishes the event to the DefaultBus.
+func Publish(event Event) {
+	DefaultBus.Publish(event)
+}
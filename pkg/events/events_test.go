@@ -0,0 +1,65 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestPublishAndSubscribe(t *testing.T) {
+	bus := NewBus()
+	bus.Publish(Event{Type: TaskStarted, Task: TaskScan})
+
+	subscription, missed := bus.Subscribe(0)
+	if len(missed) != 1 || missed[0].ID != 1 || missed[0].Type != TaskStarted {
+		t.Fatalf("Expected the earlier event to be replayed, got %+v", missed)
+	}
+
+	bus.Publish(Event{Type: GalleryAdded, GalleryUUID: "uuid"})
+	event := <-subscription.C
+	if event.ID != 2 || event.GalleryUUID != "uuid" || event.Time.IsZero() {
+		t.Errorf("Unexpected event %+v", event)
+	}
+
+	if _, missed = bus.Subscribe(2); len(missed) != 0 {
+		t.Errorf("Expected no missed events after the last ID, got %d", len(missed))
+	}
+
+	bus.Unsubscribe(subscription)
+	if _, ok := <-subscription.C; ok {
+		t.Error("Expected the channel to be closed after unsubscribing")
+	}
+	bus.Unsubscribe(subscription)
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	bus := NewBus()
+	subscription, _ := bus.Subscribe(0)
+
+	for i := 0; i < subscriberBuffer+1; i++ {
+		bus.Publish(Event{Type: ThumbnailGenerated})
+	}
+
+	received := 0
+	for range subscription.C {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("Expected %d events before the channel was closed, got %d", subscriberBuffer, received)
+	}
+
+	_, missed := bus.Subscribe(uint64(received))
+	if len(missed) != 1 {
+		t.Errorf("Expected the dropped event to be replayed, got %d", len(missed))
+	}
+}
+
+func TestHistoryIsBounded(t *testing.T) {
+	bus := NewBus()
+	for i := 0; i < historySize+10; i++ {
+		bus.Publish(Event{Type: GalleryAdded})
+	}
+
+	_, missed := bus.Subscribe(0)
+	if len(missed) != historySize || missed[0].ID != 11 {
+		t.Errorf("Expected the latest %d events, got %d starting from %d", historySize, len(missed), missed[0].ID)
+	}
+}
@@ -0,0 +1,52 @@
+package library
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Mangatsu/server/pkg/constants"
+)
+
+// Volume and chapter markers, e.g. "Vol. 2", "Volume 03", "v01", "Ch. 12", "Chapter 12.5", "c012", "第3巻" and
+// "第12話". Chapters are not matched after a parenthesis, so that "(C99)" is not taken as a chapter.
+var (
+	volumeRegex  = regexp.MustCompile(`(?i)(?:^|[\s_\-\[\]()])(?:vol(?:ume)?\.?\s*|v)(\d+(?:\.\d+)?)(?:[^\da-z]|$)|第\s*(\d+)\s*巻`)
+	chapterRegex = regexp.MustCompile(`(?i)(?:^|[\s_\-\[\]])(?:ch(?:ap(?:ter)?)?\.?\s*|c)(\d+(?:\.\d+)?)(?:[^\da-z]|$)|第\s*(\d+)\s*[話章]`)
+)
+
+// ParseNumbering returns the volume and chapter numbers of a gallery from its path relative to the library, e.g.
+// "Series/Vol. 02/Series c012.cbz". Numbers in the filename override the ones in the directories.
+func ParseNumbering(relativePath string) (volume *float64, chapter *float64) {
+	relativePath = constants.ArchiveExtensions.ReplaceAllString(relativePath, "")
+
+	for _, name := range strings.Split(relativePath, "/") {
+		if number := findNumber(volumeRegex, name); number != nil {
+			volume = number
+		}
+		if number := findNumber(chapterRegex, name); number != nil {
+			chapter = number
+		}
+	}
+
+	return volume, chapter
+}
+
+// findNumber returns the first number matched by the regex, or nil if there are none.
+func findNumber(regex *regexp.Regexp, name string) *float64 {
+	match := regex.FindStringSubmatch(name)
+	if match == nil {
+		return nil
+	}
+
+	for _, group := range match[1:] {
+		if group == "" {
+			continue
+		}
+		if number, err := strconv.ParseFloat(group, 64); err == nil {
+			return &number
+		}
+	}
+
+	return nil
+}
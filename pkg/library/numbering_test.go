@@ -0,0 +1,35 @@
+package library
+
+import "testing"
+
+func TestParseNumbering(t *testing.T) {
+	tests := []struct {
+		path    string
+		volume  float64
+		chapter float64
+	}{
+		{"Series/Series v01.cbz", 1, 0},
+		{"Series/Vol. 2/Chapter 12.5.zip", 2, 12.5},
+		{"Series/Volume 03 Ch.7", 3, 7},
+		{"Series/[Group] Series c012 (v02).cbz", 2, 12},
+		{"(C99) [circle] very lewd title.zip", 0, 0},
+		{"シリーズ 第3巻 第12話.zip", 3, 12},
+		{"Series/Vortex 10.cbz", 0, 0},
+		{"Series/Series v2 c3 v4.cbz", 2, 3},
+	}
+
+	for _, test := range tests {
+		volume, chapter := ParseNumbering(test.path)
+		if value(volume) != test.volume || value(chapter) != test.chapter {
+			t.Errorf("%s: expected volume %v and chapter %v, got %v and %v",
+				test.path, test.volume, test.chapter, value(volume), value(chapter))
+		}
+	}
+}
+
+func value(number *float64) float64 {
+	if number == nil {
+		return 0
+	}
+	return *number
+}
@@ -1,6 +1,7 @@
 package library
 
 import (
+	"context"
 	"github.com/Mangatsu/server/internal/config"
 	"github.com/Mangatsu/server/pkg/cache"
 	"github.com/Mangatsu/server/pkg/constants"
@@ -13,8 +14,12 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
+// coverJobs tracks the cover thumbnails generated in the background for newly found galleries.
+var coverJobs sync.WaitGroup
+
 func countImages(archivePath string) (uint64, error) {
 	filesystem, err := archiver.FileSystem(nil, archivePath)
 	if err != nil {
@@ -48,11 +53,15 @@ func countImages(archivePath string) (uint64, error) {
 	return fileCount, nil
 }
 
-func walk(libraryPath string, libraryID int32, libraryLayout config.Layout) fs.WalkDirFunc {
+func walk(ctx context.Context, libraryPath string, libraryID int32, libraryLayout config.Layout) fs.WalkDirFunc {
 	return func(s string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
+		// Stops the walk after the current gallery when cancelled.
+		if err = ctx.Err(); err != nil {
+			return err
+		}
 
 		if d.IsDir() {
 			return nil
@@ -78,6 +87,17 @@ func walk(libraryPath string, libraryID int32, libraryLayout config.Layout) fs.W
 		if foundGallery != nil {
 			log.Z.Debug("skipping archive already in db", zap.String("name", d.Name()))
 
+			// Galleries added before the numbers were parsed get them on the next scan.
+			if foundGallery[0].Volume == nil && foundGallery[0].Chapter == nil {
+				if volume, chapter := ParseNumbering(relativePath); volume != nil || chapter != nil {
+					if err = db.SetNumbering(foundGallery[0].UUID, volume, chapter); err != nil {
+						log.Z.Error("failed to set volume and chapter",
+							zap.String("path", relativePath),
+							zap.String("err", err.Error()))
+					}
+				}
+			}
+
 			cache.ProcessingStatusCache.AddScanSkippedGallery(foundGallery[0].UUID)
 			return nil
 		}
@@ -112,7 +132,8 @@ func walk(libraryPath string, libraryID int32, libraryLayout config.Layout) fs.W
 			log.Z.Error("failed to count images", zap.String("path", fullPath), zap.String("err", err.Error()))
 		}
 
-		uuid, err := db.NewGallery(relativePath, libraryID, title, series, size, imageCount)
+		volume, chapter := ParseNumbering(relativePath)
+		uuid, err := db.NewGallery(relativePath, libraryID, title, series, volume, chapter, size, imageCount)
 
 		if err != nil {
 			log.Z.Error("failed to add gallery to db",
@@ -126,7 +147,11 @@ func walk(libraryPath string, libraryID int32, libraryLayout config.Layout) fs.W
 
 		} else {
 			// Generates cover thumbnail
-			go GenerateCoverThumbnail(fullPath, uuid)
+			coverJobs.Add(1)
+			go func() {
+				defer coverJobs.Done()
+				GenerateCoverThumbnail(fullPath, uuid)
+			}()
 
 			log.Z.Info("added gallery", zap.String("path", relativePath), zap.String("uuid", uuid))
 
@@ -141,7 +166,8 @@ func walk(libraryPath string, libraryID int32, libraryLayout config.Layout) fs.W
 	}
 }
 
-func ScanArchives() {
+// ScanArchives scans the libraries for new galleries. Stops after the current gallery when the context is cancelled.
+func ScanArchives(ctx context.Context) {
 	// TODO: Quick scan by only checking directories that have been modified.
 	// Not too important as current implementation is pretty fast already.
 	libraries, err := db.GetOnlyLibraries()
@@ -156,7 +182,11 @@ func ScanArchives() {
 	defer cache.ProcessingStatusCache.SetScanRunning(false)
 
 	for _, library := range libraries {
-		err := filepath.WalkDir(library.Path, walk(library.Path, library.ID, config.Layout(library.Layout)))
+		err := filepath.WalkDir(library.Path, walk(ctx, library.Path, library.ID, config.Layout(library.Layout)))
+		if ctx.Err() != nil {
+			log.Z.Info("library scan cancelled", zap.String("path", library.Path))
+			break
+		}
 		if err != nil {
 			log.Z.Error("skipping library as an error occurred during scanning",
 				zap.String("path", library.Path),
@@ -166,4 +196,10 @@ func ScanArchives() {
 			continue
 		}
 	}
+
+	coverJobs.Wait()
+
+	if err = db.SyncSeries(); err != nil {
+		log.Z.Error("failed to add the series found", zap.String("err", err.Error()))
+	}
 }
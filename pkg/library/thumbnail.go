@@ -2,6 +2,7 @@ package library
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/Mangatsu/server/internal/config"
@@ -21,7 +22,8 @@ import (
 )
 
 // GenerateThumbnails generates thumbnails for covers and pages in parallel. // TODO: ignore generated files or rewrite existing cache option
-func GenerateThumbnails(pages bool, force bool) {
+// Stops generating thumbnails for the remaining galleries when the context is cancelled.
+func GenerateThumbnails(ctx context.Context, pages bool, force bool) {
 	var wg sync.WaitGroup
 
 	cache.ProcessingStatusCache.SetThumbnailsRunning(true)
@@ -36,14 +38,14 @@ func GenerateThumbnails(pages bool, force bool) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		thumbnailWalker(&wg, true)
+		thumbnailWalker(ctx, &wg, true)
 	}()
 
 	if pages {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			thumbnailWalker(&wg, false)
+			thumbnailWalker(ctx, &wg, false)
 		}()
 	}
 
@@ -53,7 +55,7 @@ func GenerateThumbnails(pages bool, force bool) {
 
 // thumbnailWalker walks through the database and generates thumbnails for covers or pages depending on onlyCover param.
 // If force is set to false, already existing directories will be skipped.
-func thumbnailWalker(wg *sync.WaitGroup, onlyCover bool) {
+func thumbnailWalker(ctx context.Context, wg *sync.WaitGroup, onlyCover bool) {
 	libraries, err := db.GetLibraries()
 	if err != nil {
 		log.Z.Error("could not get libraries for thumbnail generation", zap.String("err", err.Error()))
@@ -75,10 +77,13 @@ func thumbnailWalker(wg *sync.WaitGroup, onlyCover bool) {
 				semaphore <- struct{}{}
 
 				defer wg.Done()
-				if onlyCover {
-					GenerateCoverThumbnail(fullPath, gallery.UUID)
-				} else {
-					GeneratePageThumbnails(fullPath, gallery.UUID)
+				// Galleries still waiting for their turn are skipped when cancelled.
+				if ctx.Err() == nil {
+					if onlyCover {
+						GenerateCoverThumbnail(fullPath, gallery.UUID)
+					} else {
+						GeneratePageThumbnails(ctx, fullPath, gallery.UUID)
+					}
 				}
 
 				<-semaphore
@@ -125,8 +130,8 @@ func readArchiveImages(archivePath string, galleryUUID string) *fs.FS {
 	return &filesystem
 }
 
-// GeneratePageThumbnails generates page thumbnails.
-func GeneratePageThumbnails(archivePath string, galleryUUID string) {
+// GeneratePageThumbnails generates page thumbnails. Stops after the current page when the context is cancelled.
+func GeneratePageThumbnails(ctx context.Context, archivePath string, galleryUUID string) {
 	filesystem := readArchiveImages(archivePath, galleryUUID)
 	if filesystem == nil {
 		return
@@ -137,6 +142,9 @@ func GeneratePageThumbnails(archivePath string, galleryUUID string) {
 		if err != nil {
 			return err
 		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
 		if s == ".." {
 			return nil
 		}
@@ -201,6 +209,7 @@ func GeneratePageThumbnails(archivePath string, galleryUUID string) {
 	log.Z.Info("non-cover thumbnails generated for gallery",
 		zap.String("uuid", galleryUUID),
 		zap.Int("count", generatedCount))
+	cache.ProcessingStatusCache.FinishThumbnailPages(galleryUUID, generatedCount)
 }
 
 // GenerateCoverThumbnail generates a cover thumbnail.
@@ -239,7 +248,7 @@ func GenerateCoverThumbnail(archivePath string, galleryUUID string) {
 		}
 
 		log.Z.Info("cover thumbnail generated", zap.String("img", imgName), zap.String("uuid", galleryUUID))
-		cache.ProcessingStatusCache.AddThumbnailGeneratedCover()
+		cache.ProcessingStatusCache.AddThumbnailGeneratedCover(galleryUUID)
 
 		if err := db.SetThumbnail(galleryUUID, imgName); err != nil {
 			log.Z.Error("could not save cover thumbnail to db",
@@ -307,11 +316,7 @@ func generateThumbnail(galleryUUID string, imageName string, imgBytes []byte, co
 		return err
 	}
 
-	err = os.WriteFile(
-		thumbnailPath,
-		buf.Bytes(),
-		0666,
-	)
+	err = utils.WriteFileAtomic(thumbnailPath, buf.Bytes(), 0666)
 	if err != nil {
 		log.Z.Debug("could not write image file",
 			zap.String("err", err.Error()),
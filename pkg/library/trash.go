@@ -0,0 +1,140 @@
+package library
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+
+	"github.com/Mangatsu/server/internal/config"
+	"github.com/Mangatsu/server/pkg/cache"
+	"github.com/Mangatsu/server/pkg/db"
+	"github.com/Mangatsu/server/pkg/log"
+	"github.com/Mangatsu/server/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// ErrArchiveExists is returned when a gallery can't be restored because its archive path is taken in the library.
+var ErrArchiveExists = errors.New("an archive already exists in the library at the same path")
+
+// ErrLibraryRemoved is returned when a gallery can't be restored because its library has been removed.
+var ErrLibraryRemoved = errors.New("the library of the gallery has been removed")
+
+// trashedArchivePath returns the path of the archive in the trash. Each gallery has its own dir to avoid name clashes.
+func trashedArchivePath(gallery db.LocatedGallery) string {
+	return config.BuildPath(config.Options.GalleryOptions.TrashPath, gallery.UUID, path.Base(gallery.ArchivePath))
+}
+
+// TrashGallery moves the gallery to the trash. If the trash path is set, the archive is moved there as well.
+func TrashGallery(galleryUUID string, author db.Author) error {
+	gallery, err := db.GetLocatedGallery(galleryUUID, false)
+	if err != nil {
+		return err
+	}
+
+	libraryArchivePath := config.BuildLibraryPath(gallery.Library.Path, gallery.ArchivePath)
+	moved := false
+	if config.Options.GalleryOptions.TrashPath != "" && utils.PathExists(libraryArchivePath) {
+		trashPath := trashedArchivePath(gallery)
+		if err = os.MkdirAll(path.Dir(trashPath), os.ModePerm); err != nil {
+			return err
+		}
+		if err = os.Rename(libraryArchivePath, trashPath); err != nil {
+			return err
+		}
+		moved = true
+	}
+
+	if err = db.SetGalleryDeleted(galleryUUID, true, author); err != nil {
+		if moved {
+			if restoreErr := os.Rename(trashedArchivePath(gallery), libraryArchivePath); restoreErr != nil {
+				log.Z.Error("failed to move archive back from the trash",
+					zap.String("uuid", galleryUUID),
+					zap.String("path", libraryArchivePath),
+					zap.String("err", restoreErr.Error()))
+			}
+		}
+		return err
+	}
+
+	log.Z.Info("gallery moved to trash", zap.String("uuid", galleryUUID), zap.Bool("archiveMoved", moved))
+
+	return nil
+}
+
+// RestoreGallery restores the gallery from the trash, moving its archive back to the library if needed.
+func RestoreGallery(galleryUUID string, author db.Author) error {
+	gallery, err := db.GetLocatedGallery(galleryUUID, true)
+	if err != nil {
+		return err
+	}
+	if gallery.Library.Deleted {
+		return ErrLibraryRemoved
+	}
+
+	libraryArchivePath := config.BuildLibraryPath(gallery.Library.Path, gallery.ArchivePath)
+	if config.Options.GalleryOptions.TrashPath != "" && utils.PathExists(trashedArchivePath(gallery)) {
+		if utils.PathExists(libraryArchivePath) {
+			return ErrArchiveExists
+		}
+		if err = os.MkdirAll(path.Dir(libraryArchivePath), os.ModePerm); err != nil {
+			return err
+		}
+		if err = os.Rename(trashedArchivePath(gallery), libraryArchivePath); err != nil {
+			return err
+		}
+		removeTrashDir(gallery)
+	}
+
+	if err = db.SetGalleryDeleted(galleryUUID, false, author); err != nil {
+		return err
+	}
+
+	log.Z.Info("gallery restored from trash", zap.String("uuid", galleryUUID))
+
+	return nil
+}
+
+// PurgeGallery permanently removes the gallery in the trash from the database along with its thumbnails and cache.
+// The archive is removed only if it was moved to the trash path.
+func PurgeGallery(galleryUUID string) error {
+	gallery, err := db.GetLocatedGallery(galleryUUID, true)
+	if err != nil {
+		return err
+	}
+
+	if err = db.PurgeGallery(galleryUUID); err != nil {
+		return err
+	}
+
+	if err = cache.Remove(galleryUUID); err != nil {
+		log.Z.Error("failed to remove cache of a purged gallery",
+			zap.String("uuid", galleryUUID),
+			zap.String("err", err.Error()))
+	}
+
+	thumbnailPath := config.BuildCachePath("thumbnails", galleryUUID)
+	if err = os.RemoveAll(thumbnailPath); err != nil {
+		log.Z.Error("failed to remove thumbnails of a purged gallery",
+			zap.String("path", thumbnailPath),
+			zap.String("err", err.Error()))
+	}
+
+	if config.Options.GalleryOptions.TrashPath != "" {
+		removeTrashDir(gallery)
+	}
+
+	log.Z.Info("gallery purged", zap.String("uuid", galleryUUID), zap.String("path", gallery.ArchivePath))
+
+	return nil
+}
+
+// removeTrashDir removes the trash dir of the gallery and everything in it.
+func removeTrashDir(gallery db.LocatedGallery) {
+	trashDir := path.Dir(trashedArchivePath(gallery))
+	if err := os.RemoveAll(trashDir); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Z.Error("failed to remove trash dir",
+			zap.String("path", trashDir),
+			zap.String("err", err.Error()))
+	}
+}
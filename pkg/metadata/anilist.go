@@ -0,0 +1,261 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Mangatsu/server/internal/config"
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+)
+
+const aniListURL = "https://graphql.anilist.co"
+
+// AniList allows 90 requests per minute.
+const aniListInterval = 700 * time.Millisecond
+
+const aniListSearchQuery = `query ($search: String) {
+  Page(perPage: 10) {
+    media(search: $search, type: MANGA) {
+      id
+      title { romaji english native }
+      synonyms
+    }
+  }
+}`
+
+const aniListFetchQuery = `query ($id: Int) {
+  Media(id: $id, type: MANGA) {
+    id
+    title { romaji english native }
+    synonyms
+    format
+    startDate { year month day }
+    genres
+    tags { name isMediaSpoiler }
+    isAdult
+    siteUrl
+  }
+}`
+
+type aniListTitle struct {
+	Romaji  *string `json:"romaji"`
+	English *string `json:"english"`
+	Native  *string `json:"native"`
+}
+
+type aniListMedia struct {
+	ID        int32        `json:"id"`
+	Title     aniListTitle `json:"title"`
+	Synonyms  []string     `json:"synonyms"`
+	Format    *string      `json:"format"`
+	StartDate *struct {
+		Year  *int `json:"year"`
+		Month *int `json:"month"`
+		Day   *int `json:"day"`
+	} `json:"startDate"`
+	Genres []string `json:"genres"`
+	Tags   []struct {
+		Name           string `json:"name"`
+		IsMediaSpoiler bool   `json:"isMediaSpoiler"`
+	} `json:"tags"`
+	IsAdult bool    `json:"isAdult"`
+	SiteURL *string `json:"siteUrl"`
+}
+
+type aniListResponse struct {
+	Data struct {
+		Page *struct {
+			Media []aniListMedia `json:"media"`
+		} `json:"Page"`
+		Media *aniListMedia `json:"Media"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+		Status  int    `json:"status"`
+	} `json:"errors"`
+}
+
+// AniListProvider fetches metadata from the AniList GraphQL API (https://anilist.co).
+type AniListProvider struct {
+	url string
+
+	mu          sync.Mutex
+	lastRequest time.Time
+}
+
+// NewAniListProvider returns a new AniList provider. URL defaults to the public AniList API.
+func NewAniListProvider(options config.ProviderOptions) (Provider, error) {
+	url := options.URL
+	if url == "" {
+		url = aniListURL
+	}
+
+	return &AniListProvider{url: url}, nil
+}
+
+func (p *AniListProvider) Name() string {
+	return "anilist"
+}
+
+func (p *AniListProvider) Search(ctx context.Context, title string) ([]Candidate, error) {
+	res, err := p.query(ctx, aniListSearchQuery, map[string]interface{}{"search": title})
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Data.Page == nil {
+		return nil, nil
+	}
+
+	candidates := make([]Candidate, 0, len(res.Data.Page.Media))
+	for _, media := range res.Data.Page.Media {
+		candidates = append(candidates, Candidate{
+			ID:     strconv.Itoa(int(media.ID)),
+			Titles: media.titles(),
+		})
+	}
+
+	return candidates, nil
+}
+
+func (p *AniListProvider) Fetch(ctx context.Context, id string) (model.Gallery, []model.Tag, model.Reference, error) {
+	anilistID, err := strconv.ParseInt(id, 10, 32)
+	if err != nil {
+		return model.Gallery{}, nil, model.Reference{}, fmt.Errorf("invalid AniList ID: %s", id)
+	}
+
+	res, err := p.query(ctx, aniListFetchQuery, map[string]interface{}{"id": anilistID})
+	if err != nil {
+		return model.Gallery{}, nil, model.Reference{}, err
+	}
+
+	if res.Data.Media == nil {
+		return model.Gallery{}, nil, model.Reference{}, ErrNotFound
+	}
+
+	gallery, tags, reference := convertAniList(*res.Data.Media)
+	return gallery, tags, reference, nil
+}
+
+// query sends a GraphQL query to AniList. Requests are throttled to respect the rate limit.
+func (p *AniListProvider) query(ctx context.Context, query string, variables map[string]interface{}) (aniListResponse, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return aniListResponse{}, err
+	}
+
+	if err = p.throttle(ctx); err != nil {
+		return aniListResponse{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(payload))
+	if err != nil {
+		return aniListResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	body, err := doProviderRequest(req)
+	if err != nil {
+		return aniListResponse{}, err
+	}
+
+	var res aniListResponse
+	if err = json.Unmarshal(body, &res); err != nil {
+		return aniListResponse{}, err
+	}
+
+	if len(res.Errors) > 0 {
+		if res.Errors[0].Status == http.StatusNotFound {
+			return aniListResponse{}, ErrNotFound
+		}
+		return aniListResponse{}, errors.New("AniList: " + res.Errors[0].Message)
+	}
+
+	return res, nil
+}
+
+// throttle waits for the next free slot of the rate limit. The slot is reserved under the lock, and waited without
+// it, so that cancelling the context returns right away.
+func (p *AniListProvider) throttle(ctx context.Context) error {
+	p.mu.Lock()
+	slot := p.lastRequest.Add(aniListInterval)
+	if now := time.Now(); slot.Before(now) {
+		slot = now
+	}
+	p.lastRequest = slot
+	p.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Until(slot)):
+		return nil
+	}
+}
+
+// titles returns all known titles of the media.
+func (m aniListMedia) titles() []string {
+	var titles []string
+	for _, title := range []*string{m.Title.Romaji, m.Title.English, m.Title.Native} {
+		if title != nil && *title != "" {
+			titles = append(titles, *title)
+		}
+	}
+
+	return append(titles, m.Synonyms...)
+}
+
+// convertAniList converts AniList media to gallery, tags and reference models.
+func convertAniList(media aniListMedia) (model.Gallery, []model.Tag, model.Reference) {
+	gallery := model.Gallery{
+		TitleNative:     media.Title.Native,
+		TitleTranslated: media.Title.English,
+		Series:          media.Title.Romaji,
+		Nsfw:            media.IsAdult,
+	}
+
+	if media.Format != nil {
+		category := strings.ReplaceAll(strings.ToLower(*media.Format), "_", " ")
+		gallery.Category = &category
+	}
+
+	if media.StartDate != nil && media.StartDate.Year != nil {
+		released := strconv.Itoa(*media.StartDate.Year)
+		if media.StartDate.Month != nil {
+			released += fmt.Sprintf("-%02d", *media.StartDate.Month)
+			if media.StartDate.Day != nil {
+				released += fmt.Sprintf("-%02d", *media.StartDate.Day)
+			}
+		}
+		gallery.Released = &released
+	}
+
+	var tags []model.Tag
+	for _, genre := range media.Genres {
+		tags = append(tags, model.Tag{Namespace: "genre", Name: strings.ToLower(genre)})
+	}
+	for _, tag := range media.Tags {
+		if tag.IsMediaSpoiler {
+			continue
+		}
+		tags = append(tags, model.Tag{Namespace: "tag", Name: strings.ToLower(tag.Name)})
+	}
+
+	reference := model.Reference{
+		AnilistID: &media.ID,
+		Urls:      media.SiteURL,
+	}
+
+	return gallery, tags, reference
+}
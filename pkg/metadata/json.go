@@ -0,0 +1,152 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Mangatsu/server/internal/config"
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+)
+
+// JSONSearchResult is a single search result of the generic JSON provider.
+type JSONSearchResult struct {
+	ID                string   `json:"id"`
+	Title             string   `json:"title"`
+	AlternativeTitles []string `json:"alternative_titles"`
+}
+
+// JSONEntry is the metadata returned by the generic JSON provider. All fields except ID are optional.
+type JSONEntry struct {
+	ID              string              `json:"id"`
+	Title           *string             `json:"title"`
+	TitleNative     *string             `json:"title_native"`
+	TitleTranslated *string             `json:"title_translated"`
+	Category        *string             `json:"category"`
+	Series          *string             `json:"series"`
+	Released        *string             `json:"released"`
+	Language        *string             `json:"language"`
+	Translated      *bool               `json:"translated"`
+	Nsfw            *bool               `json:"nsfw"`
+	Tags            map[string][]string `json:"tags"`
+	Urls            []string            `json:"urls"`
+}
+
+// JSONProvider fetches metadata from any HTTP service following a simple JSON contract.
+// The search URL must contain {title} and the fetch URL {id}. Both are replaced with the escaped values.
+// Searching returns a list of JSONSearchResult and fetching a single JSONEntry.
+type JSONProvider struct {
+	searchURL string
+	fetchURL  string
+	token     string
+}
+
+// NewJSONProvider returns a new generic JSON provider.
+func NewJSONProvider(options config.ProviderOptions) (Provider, error) {
+	if !strings.Contains(options.SearchURL, "{title}") {
+		return nil, errors.New("search URL must contain {title}")
+	}
+	if !strings.Contains(options.FetchURL, "{id}") {
+		return nil, errors.New("fetch URL must contain {id}")
+	}
+
+	return &JSONProvider{
+		searchURL: options.SearchURL,
+		fetchURL:  options.FetchURL,
+		token:     options.Token,
+	}, nil
+}
+
+func (p *JSONProvider) Name() string {
+	return "json"
+}
+
+func (p *JSONProvider) Search(ctx context.Context, title string) ([]Candidate, error) {
+	body, err := p.get(ctx, strings.ReplaceAll(p.searchURL, "{title}", url.QueryEscape(title)))
+	if err != nil {
+		return nil, err
+	}
+
+	var results []JSONSearchResult
+	if err = json.Unmarshal(body, &results); err != nil {
+		return nil, err
+	}
+
+	candidates := make([]Candidate, 0, len(results))
+	for _, result := range results {
+		if result.ID == "" {
+			continue
+		}
+		candidates = append(candidates, Candidate{
+			ID:     result.ID,
+			Titles: append([]string{result.Title}, result.AlternativeTitles...),
+		})
+	}
+
+	return candidates, nil
+}
+
+func (p *JSONProvider) Fetch(ctx context.Context, id string) (model.Gallery, []model.Tag, model.Reference, error) {
+	body, err := p.get(ctx, strings.ReplaceAll(p.fetchURL, "{id}", url.PathEscape(id)))
+	if err != nil {
+		return model.Gallery{}, nil, model.Reference{}, err
+	}
+
+	var entry JSONEntry
+	if err = json.Unmarshal(body, &entry); err != nil {
+		return model.Gallery{}, nil, model.Reference{}, err
+	}
+
+	gallery, tags, reference := convertJSONEntry(entry)
+	return gallery, tags, reference, nil
+}
+
+func (p *JSONProvider) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	return doProviderRequest(req)
+}
+
+// convertJSONEntry converts the generic JSON entry to gallery, tags and reference models.
+func convertJSONEntry(entry JSONEntry) (model.Gallery, []model.Tag, model.Reference) {
+	gallery := model.Gallery{
+		TitleNative:     entry.TitleNative,
+		TitleTranslated: entry.TitleTranslated,
+		Category:        entry.Category,
+		Series:          entry.Series,
+		Released:        entry.Released,
+		Language:        entry.Language,
+		Translated:      entry.Translated,
+	}
+	if entry.Title != nil {
+		gallery.Title = *entry.Title
+	}
+	if entry.Nsfw != nil {
+		gallery.Nsfw = *entry.Nsfw
+	}
+
+	var tags []model.Tag
+	for namespace, names := range entry.Tags {
+		for _, name := range names {
+			tags = append(tags, model.Tag{Namespace: namespace, Name: name})
+		}
+	}
+
+	reference := model.Reference{}
+	if len(entry.Urls) > 0 {
+		urls := strings.Join(entry.Urls, "\n")
+		reference.Urls = &urls
+	}
+
+	return gallery, tags, reference
+}
@@ -0,0 +1,325 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Mangatsu/server/internal/config"
+	"github.com/Mangatsu/server/pkg/cache"
+	"github.com/Mangatsu/server/pkg/db"
+	"github.com/Mangatsu/server/pkg/log"
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	"github.com/Mangatsu/server/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// Candidate is a search result of a metadata provider.
+type Candidate struct {
+	ID     string
+	Titles []string
+}
+
+// Provider is an online source of metadata such as AniList.
+type Provider interface {
+	// Name returns the unique name of the provider used in the configuration and the API.
+	Name() string
+	// Search returns candidates matching the given title.
+	Search(ctx context.Context, title string) ([]Candidate, error)
+	// Fetch returns the metadata of the given ID mapped to a gallery, its tags and reference.
+	Fetch(ctx context.Context, id string) (model.Gallery, []model.Tag, model.Reference, error)
+}
+
+// ProviderFactory creates a provider from its options.
+type ProviderFactory func(options config.ProviderOptions) (Provider, error)
+
+var providerFactories = map[string]ProviderFactory{
+	"anilist": NewAniListProvider,
+	"json":    NewJSONProvider,
+}
+
+var providersMu sync.RWMutex
+var providers = make(map[string]Provider)
+
+// ErrNotFound is returned by providers when the requested entry doesn't exist.
+var ErrNotFound = errors.New("not found in the provider")
+
+// InitProviders registers the providers enabled in the configuration.
+func InitProviders() {
+	for name, options := range config.Options.Metadata.Providers {
+		factory, ok := providerFactories[name]
+		if !ok {
+			log.Z.Error("unknown metadata provider", zap.String("name", name))
+			continue
+		}
+
+		provider, err := factory(options)
+		if err != nil {
+			log.Z.Error("could not initialize metadata provider", zap.String("name", name), zap.String("err", err.Error()))
+			continue
+		}
+
+		RegisterProvider(provider)
+	}
+}
+
+// RegisterProvider adds the provider to the registry. Providers with the same name are replaced.
+func RegisterProvider(provider Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	providers[provider.Name()] = provider
+}
+
+// GetProvider returns the registered provider with the given name.
+func GetProvider(name string) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	provider, ok := providers[name]
+	return provider, ok
+}
+
+// ProviderNames returns the names of all registered providers in alphabetical order.
+func ProviderNames() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// BestCandidate returns the candidate with the highest similarity to the given title and the similarity.
+func BestCandidate(title string, candidates []Candidate) (*Candidate, float64) {
+	var best *Candidate
+	bestSimilarity := 0.0
+
+	for i, candidate := range candidates {
+		for _, candidateTitle := range candidate.Titles {
+			if candidateTitle == "" {
+				continue
+			}
+
+			similarity := utils.Similarity(title, candidateTitle)
+			if strings.EqualFold(title, candidateTitle) {
+				similarity = 1
+			}
+
+			if similarity > bestSimilarity {
+				best = &candidates[i]
+				bestSimilarity = similarity
+			}
+		}
+	}
+
+	return best, bestSimilarity
+}
+
+// providerQuery returns the title used to search providers for the gallery.
+// Volumes in the same series share the query, so the series is preferred.
+func providerQuery(gallery model.Gallery) string {
+	if gallery.Series != nil && *gallery.Series != "" {
+		return *gallery.Series
+	}
+
+	if titleMeta := ParseTitle(gallery.Title); titleMeta != nil && titleMeta.Title != "" {
+		return titleMeta.Title
+	}
+
+	return gallery.Title
+}
+
+type providerMatch struct {
+	id         string
+	similarity float64
+	gallery    model.Gallery
+	tags       []model.Tag
+	reference  model.Reference
+	err        error
+}
+
+// ParseProviders searches the given providers for metadata of all galleries.
+// Matches are confirmed with the similarity threshold of the fuzzy search.
+// Matches below the auto-accept threshold are added to the review queue.
+// Stops after the current gallery when the context is cancelled.
+func ParseProviders(ctx context.Context, names []string) {
+	var selected []Provider
+	for _, name := range names {
+		provider, ok := GetProvider(name)
+		if !ok {
+			log.Z.Warn("metadata provider not enabled", zap.String("name", name))
+			continue
+		}
+		selected = append(selected, provider)
+	}
+
+	if len(selected) == 0 {
+		return
+	}
+
+	libraries, err := db.GetLibraries()
+	if err != nil {
+		log.Z.Error("libraries could not be retrieved to fetch metadata from providers", zap.String("err", err.Error()))
+		return
+	}
+
+	cache.ProcessingStatusCache.SetMetadataRunning(true)
+	defer cache.ProcessingStatusCache.SetMetadataRunning(false)
+
+	for _, provider := range selected {
+		// Results are reused for galleries sharing the same query, e.g. volumes of a series.
+		matches := make(map[string]providerMatch)
+		cache.ProcessingStatusCache.AddMetadataTotal(provider.Name(), countGalleries(libraries, nil))
+
+		for _, galleryLibrary := range libraries {
+			for _, gallery := range galleryLibrary.Galleries {
+				if ctx.Err() != nil {
+					log.Z.Info("fetching metadata from providers cancelled")
+					return
+				}
+				cache.ProcessingStatusCache.AddMetadataProcessed()
+				query := providerQuery(gallery)
+				if query == "" {
+					continue
+				}
+
+				match, ok := matches[query]
+				if !ok {
+					match = matchProvider(ctx, provider, query)
+					matches[query] = match
+				}
+
+				if match.err != nil {
+					if !errors.Is(match.err, ErrNotFound) {
+						cache.ProcessingStatusCache.AddMetadataError(gallery.UUID, match.err.Error(), map[string]string{
+							"provider": provider.Name(),
+							"query":    query,
+						})
+					}
+					continue
+				}
+
+				if match.similarity <= config.Options.Reloadable().FuzzySearchSimilarity {
+					continue
+				}
+
+				newGallery := match.gallery
+				newGallery.UUID = gallery.UUID
+				newGallery.ArchivePath = gallery.ArchivePath
+				if newGallery.Title == "" {
+					newGallery.Title = gallery.Title
+				}
+				newGallery.Nsfw = newGallery.Nsfw || gallery.Nsfw
+				newGallery.Hidden = gallery.Hidden
+				// Series are linked to their galleries by name, so renaming one would split the series.
+				if gallery.Series != nil && *gallery.Series != "" {
+					newGallery.Series = gallery.Series
+				}
+
+				applied, err := applyMatch(gallery.UUID, provider.Name(), match.id, match.similarity, newGallery, slices.Clone(match.tags), match.reference)
+				if err != nil {
+					log.Z.Debug("could not tag gallery with provider metadata",
+						zap.String("provider", provider.Name()),
+						zap.String("path", gallery.ArchivePath),
+						zap.String("err", err.Error()))
+
+					cache.ProcessingStatusCache.AddMetadataError(gallery.UUID, err.Error(), map[string]string{
+						"provider": provider.Name(),
+						"path":     gallery.ArchivePath,
+					})
+					continue
+				}
+
+				if !applied {
+					log.Z.Info("provider match added to the review queue",
+						zap.String("provider", provider.Name()),
+						zap.Float64("similarity", match.similarity),
+						zap.String("uuid", gallery.UUID),
+						zap.String("title", gallery.Title))
+					continue
+				}
+
+				log.Z.Info("metadata fetched from provider",
+					zap.String("provider", provider.Name()),
+					zap.Float64("similarity", match.similarity),
+					zap.String("uuid", gallery.UUID),
+					zap.String("title", gallery.Title))
+				cache.ProcessingStatusCache.AddMetadataParsed(gallery.UUID, provider.Name())
+			}
+		}
+	}
+}
+
+// matchProvider searches the provider and fetches the metadata of the best candidate.
+func matchProvider(ctx context.Context, provider Provider, query string) providerMatch {
+	candidates, err := provider.Search(ctx, query)
+	if err != nil {
+		return providerMatch{err: err}
+	}
+
+	best, similarity := BestCandidate(query, candidates)
+	if best == nil {
+		return providerMatch{err: ErrNotFound}
+	}
+
+	if similarity <= config.Options.Reloadable().FuzzySearchSimilarity {
+		return providerMatch{similarity: similarity}
+	}
+
+	gallery, tags, reference, err := provider.Fetch(ctx, best.ID)
+	return providerMatch{
+		id:         best.ID,
+		similarity: similarity,
+		gallery:    gallery,
+		tags:       tags,
+		reference:  reference,
+		err:        err,
+	}
+}
+
+// providerClient is the HTTP client shared by the providers.
+var providerClient = &http.Client{Timeout: 20 * time.Second}
+
+// doProviderRequest sends the request and returns the body. Non-2xx responses are returned as errors.
+func doProviderRequest(req *http.Request) ([]byte, error) {
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "Mangatsu")
+
+	res, err := providerClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(res.Body)
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 8<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("provider responded with status %d", res.StatusCode)
+	}
+
+	return body, nil
+}
+
+func closeBody(body io.Closer) {
+	if err := body.Close(); err != nil {
+		log.Z.Debug("failed to close response body", zap.String("err", err.Error()))
+	}
+}
@@ -0,0 +1,191 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Mangatsu/server/internal/config"
+)
+
+// newAniListFixture returns a stand-in for the AniList GraphQL API.
+func newAniListFixture(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error("invalid GraphQL request:", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if _, ok := req.Variables["search"]; ok {
+			_, _ = w.Write([]byte(`{"data":{"Page":{"media":[
+				{"id":1,"title":{"romaji":"Mahou Shoujo","english":"Magical Girls","native":"魔法少女"},"synonyms":[]},
+				{"id":2,"title":{"romaji":"Something Else","english":null,"native":null},"synonyms":["Other"]}
+			]}}}`))
+			return
+		}
+
+		if req.Variables["id"] != float64(1) {
+			_, _ = w.Write([]byte(`{"data":{"Media":null},"errors":[{"message":"Not Found.","status":404}]}`))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"data":{"Media":{
+			"id":1,
+			"title":{"romaji":"Mahou Shoujo","english":"Magical Girls","native":"魔法少女"},
+			"synonyms":[],
+			"format":"ONE_SHOT",
+			"startDate":{"year":2019,"month":4,"day":null},
+			"genres":["Comedy"],
+			"tags":[{"name":"Swimsuit","isMediaSpoiler":false},{"name":"Twist","isMediaSpoiler":true}],
+			"isAdult":true,
+			"siteUrl":"https://anilist.co/manga/1"
+		}}}`))
+	}))
+}
+
+func TestAniListProvider(t *testing.T) {
+	server := newAniListFixture(t)
+	defer server.Close()
+
+	provider, err := NewAniListProvider(config.ProviderOptions{URL: server.URL})
+	if err != nil {
+		t.Fatal("Creating AniList provider failed:", err)
+	}
+
+	candidates, err := provider.Search(context.Background(), "magical girls")
+	if err != nil {
+		t.Fatal("Searching AniList failed:", err)
+	}
+
+	best, similarity := BestCandidate("magical girls", candidates)
+	if best == nil || best.ID != "1" || similarity != 1 {
+		t.Fatal("best candidate didn't match the expected result")
+	}
+
+	gallery, tags, reference, err := provider.Fetch(context.Background(), best.ID)
+	if err != nil {
+		t.Fatal("Fetching from AniList failed:", err)
+	}
+
+	if *gallery.TitleNative != "魔法少女" ||
+		*gallery.TitleTranslated != "Magical Girls" ||
+		*gallery.Series != "Mahou Shoujo" ||
+		*gallery.Category != "one shot" ||
+		*gallery.Released != "2019-04" ||
+		!gallery.Nsfw {
+		t.Error("fetched gallery didn't match the expected result")
+	}
+
+	if len(tags) != 2 || tags[0].Namespace != "genre" || tags[0].Name != "comedy" || tags[1].Name != "swimsuit" {
+		t.Error("fetched tags didn't match the expected result: ", tags)
+	}
+
+	if *reference.AnilistID != 1 || *reference.Urls != "https://anilist.co/manga/1" {
+		t.Error("fetched reference didn't match the expected result")
+	}
+
+	if _, _, _, err = provider.Fetch(context.Background(), "3"); err != ErrNotFound {
+		t.Error("fetching an unknown ID should return ErrNotFound, got:", err)
+	}
+}
+
+func TestAniListThrottle(t *testing.T) {
+	provider := &AniListProvider{}
+
+	start := time.Now()
+	if err := provider.throttle(context.Background()); err != nil {
+		t.Fatal("Throttling the first request failed:", err)
+	}
+	if err := provider.throttle(context.Background()); err != nil {
+		t.Fatal("Throttling the second request failed:", err)
+	}
+	if elapsed := time.Since(start); elapsed < aniListInterval {
+		t.Errorf("Expected the second request to wait %s, waited %s", aniListInterval, elapsed)
+	}
+
+	// Queued callers return as soon as the context is cancelled.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start = time.Now()
+	for range 3 {
+		if err := provider.throttle(ctx); err == nil {
+			t.Error("Expected an error when the context is cancelled")
+		}
+	}
+	if elapsed := time.Since(start); elapsed >= aniListInterval {
+		t.Errorf("Expected cancelled callers to return right away, waited %s", elapsed)
+	}
+}
+
+func TestJSONProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/search":
+			if r.URL.Query().Get("q") != "very lewd title" {
+				t.Error("unexpected search query:", r.URL.RawQuery)
+			}
+			_, _ = w.Write([]byte(`[{"id":"abc","title":"Very Lewd Title","alternative_titles":["とてもエッチなタイトル"]}]`))
+		case "/entries/abc":
+			_, _ = w.Write([]byte(`{"id":"abc","title_native":"とてもエッチなタイトル","category":"doujinshi","nsfw":true,
+				"tags":{"female":["swimsuit"]},"urls":["https://example.org/abc"]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	if _, err := NewJSONProvider(config.ProviderOptions{SearchURL: server.URL + "/search"}); err == nil {
+		t.Error("creating JSON provider without placeholders should fail")
+	}
+
+	provider, err := NewJSONProvider(config.ProviderOptions{
+		SearchURL: server.URL + "/search?q={title}",
+		FetchURL:  server.URL + "/entries/{id}",
+		Token:     "s3cr3t",
+	})
+	if err != nil {
+		t.Fatal("Creating JSON provider failed:", err)
+	}
+
+	candidates, err := provider.Search(context.Background(), "very lewd title")
+	if err != nil {
+		t.Fatal("Searching JSON provider failed:", err)
+	}
+
+	best, _ := BestCandidate("very lewd title", candidates)
+	if best == nil || best.ID != "abc" {
+		t.Fatal("best candidate didn't match the expected result")
+	}
+
+	gallery, tags, reference, err := provider.Fetch(context.Background(), best.ID)
+	if err != nil {
+		t.Fatal("Fetching from JSON provider failed:", err)
+	}
+
+	if gallery.Title != "" || *gallery.TitleNative != "とてもエッチなタイトル" || *gallery.Category != "doujinshi" || !gallery.Nsfw {
+		t.Error("fetched gallery didn't match the expected result")
+	}
+
+	if len(tags) != 1 || tags[0].Namespace != "female" || tags[0].Name != "swimsuit" {
+		t.Error("fetched tags didn't match the expected result: ", tags)
+	}
+
+	if *reference.Urls != "https://example.org/abc" {
+		t.Error("fetched reference didn't match the expected result")
+	}
+
+	if _, _, _, err = provider.Fetch(context.Background(), "missing"); err != ErrNotFound {
+		t.Error("fetching an unknown ID should return ErrNotFound, got:", err)
+	}
+}
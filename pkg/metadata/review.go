@@ -0,0 +1,47 @@
+package metadata
+
+import (
+	"math"
+
+	"github.com/Mangatsu/server/internal/config"
+	"github.com/Mangatsu/server/pkg/db"
+	"github.com/Mangatsu/server/pkg/log"
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	"go.uber.org/zap"
+)
+
+// applyMatch applies the matched metadata to the gallery if the similarity reaches the auto-accept threshold.
+// Matches with lower similarity are added to the review queue. Previously rejected matches are skipped.
+// Returns true if the metadata was applied.
+func applyMatch(
+	galleryUUID string,
+	source string,
+	sourceID string,
+	similarity float64,
+	gallery model.Gallery,
+	tags []model.Tag,
+	reference model.Reference,
+) (bool, error) {
+	if db.MetaCandidateRejected(galleryUUID, source, sourceID) {
+		log.Z.Debug("skipping rejected match",
+			zap.String("uuid", galleryUUID),
+			zap.String("source", source),
+			zap.String("sourceID", sourceID))
+		return false, nil
+	}
+
+	if similarity < 1 {
+		permil := int32(math.Round(similarity * 1000))
+		reference.MetaMatch = &permil
+	}
+
+	if similarity >= config.Options.GalleryOptions.FuzzyAutoAccept {
+		return true, db.UpdateGallery(gallery, tags, reference, true, db.Author{Source: source})
+	}
+
+	return false, db.ProposeMetaCandidate(galleryUUID, source, sourceID, similarity, db.MetaProposal{
+		Gallery:   gallery,
+		Tags:      tags,
+		Reference: reference,
+	})
+}
@@ -1,11 +1,13 @@
 package metadata
 
 import (
+	"cmp"
+	"context"
 	"errors"
 	"io/fs"
-	"math"
 	"os"
 	"path/filepath"
+	"slices"
 
 	"github.com/Mangatsu/server/internal/config"
 	"github.com/Mangatsu/server/pkg/cache"
@@ -29,6 +31,7 @@ const (
 )
 
 type NoMatchPaths struct {
+	galleryUUID string
 	libraryPath string
 	fullPath    string
 }
@@ -98,17 +101,60 @@ func matchExternalMeta(metaTypes map[MetaType]bool, fullArchivePath string, libr
 }
 
 // ParseMetadata scans all libraries for metadata files (json, txt).
-func ParseMetadata(metaTypes map[MetaType]bool) {
+func ParseMetadata(ctx context.Context, metaTypes map[MetaType]bool) {
+	parseMetadata(ctx, metaTypes, nil)
+}
+
+// ParseGalleryMetadata scans metadata files (json, txt) only for the given galleries.
+func ParseGalleryMetadata(ctx context.Context, metaTypes map[MetaType]bool, galleryUUIDs []string) {
+	selected := make(map[string]bool, len(galleryUUIDs))
+	for _, galleryUUID := range galleryUUIDs {
+		selected[galleryUUID] = true
+	}
+
+	parseMetadata(ctx, metaTypes, selected)
+}
+
+// countGalleries returns the number of the selected galleries in the libraries. If selected is nil, all are counted.
+func countGalleries(libraries []db.CombinedLibrary, selected map[string]bool) int {
+	count := 0
+	for _, galleryLibrary := range libraries {
+		for _, gallery := range galleryLibrary.Galleries {
+			if selected == nil || selected[gallery.UUID] {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+// parseMetadata scans metadata files of the selected galleries. If selected is nil, all galleries are scanned.
+// Stops after the current gallery when the context is cancelled.
+func parseMetadata(ctx context.Context, metaTypes map[MetaType]bool, selected map[string]bool) {
 	libraries, err := db.GetLibraries()
 	if err != nil {
 		log.Z.Error("libraries could not be retrieved to parse meta files: ", zap.String("err", err.Error()))
 		return
 	}
 
+	cache.ProcessingStatusCache.SetMetadataRunning(true)
+	defer cache.ProcessingStatusCache.SetMetadataRunning(false)
+	cache.ProcessingStatusCache.AddMetadataTotal("files", countGalleries(libraries, selected))
+
 	var archivesWithNoMatch []NoMatchPaths
 
 	for _, galleryLibrary := range libraries {
 		for _, gallery := range galleryLibrary.Galleries {
+			if selected != nil && !selected[gallery.UUID] {
+				continue
+			}
+			if ctx.Err() != nil {
+				log.Z.Info("metadata parsing cancelled")
+				return
+			}
+			cache.ProcessingStatusCache.AddMetadataProcessed()
+
 			fullPath := config.BuildLibraryPath(galleryLibrary.Path, gallery.ArchivePath)
 
 			var metaData []byte
@@ -129,7 +175,11 @@ func ParseMetadata(metaTypes map[MetaType]bool) {
 
 			if metaData == nil {
 				if metaTypes[FuzzyMatch] {
-					archivesWithNoMatch = append(archivesWithNoMatch, NoMatchPaths{libraryPath: galleryLibrary.Path, fullPath: fullPath})
+					archivesWithNoMatch = append(archivesWithNoMatch, NoMatchPaths{
+						galleryUUID: gallery.UUID,
+						libraryPath: galleryLibrary.Path,
+						fullPath:    fullPath,
+					})
 				}
 				continue
 			}
@@ -181,7 +231,7 @@ func ParseMetadata(metaTypes map[MetaType]bool) {
 			newGallery.UUID = gallery.UUID
 			newGallery.ArchivePath = gallery.ArchivePath
 
-			err = db.UpdateGallery(newGallery, tags, reference, true)
+			err = db.UpdateGallery(newGallery, tags, reference, true, db.Author{Source: string(metaType)})
 			if err != nil {
 				log.Z.Debug("could not tag gallery",
 					zap.String("path", gallery.ArchivePath),
@@ -200,46 +250,95 @@ func ParseMetadata(metaTypes map[MetaType]bool) {
 				zap.String("path", gallery.ArchivePath),
 				zap.String("metaPath", metaPath),
 			)
+			cache.ProcessingStatusCache.AddMetadataParsed(gallery.UUID, string(metaType))
 		}
 	}
 
 	// Fuzzy parsing for all archives that didn't have an exact match.
 	for _, noMatch := range archivesWithNoMatch {
-		onlyDir := filepath.Dir(noMatch.fullPath)
-		files, err := os.ReadDir(onlyDir)
-		if err != nil {
-			log.Z.Debug("could not gallery read dir while fuzzy matching",
-				zap.String("path", onlyDir),
-				zap.String("err", err.Error()))
+		if ctx.Err() != nil {
+			log.Z.Info("fuzzy matching metadata cancelled")
+			return
 		}
+		fuzzyMatch(noMatch)
+	}
+}
 
-		for _, f := range files {
-			r, exhGallery := fuzzyMatchExternalMeta(noMatch.fullPath, noMatch.libraryPath, f)
+// fuzzyMatch matches the archive with the metadata files in the same directory.
+// The best match is applied if it's exact or similar enough. Otherwise, all matches above the similarity threshold
+// are added to the review queue.
+func fuzzyMatch(noMatch NoMatchPaths) {
+	onlyDir := filepath.Dir(noMatch.fullPath)
+	files, err := os.ReadDir(onlyDir)
+	if err != nil {
+		log.Z.Debug("could not gallery read dir while fuzzy matching",
+			zap.String("path", onlyDir),
+			zap.String("err", err.Error()))
+		return
+	}
 
-			if r.MatchedArchivePath != "" && r.MetaTitleMatch || r.Similarity > config.Options.GalleryOptions.FuzzySearchSimilarity {
-				gallery, tags, reference := convertExh(exhGallery, r.MatchedArchivePath, r.RelativeMetaPath, false)
+	type fuzzyCandidate struct {
+		result     FuzzyResult
+		exhGallery XMetadata
+	}
 
-				if !r.MetaTitleMatch {
-					permil := int32(math.Round(r.Similarity * 1000))
-					reference.MetaMatch = &permil
-				}
+	var candidates []fuzzyCandidate
+	for _, f := range files {
+		r, exhGallery := fuzzyMatchExternalMeta(noMatch.fullPath, noMatch.libraryPath, f)
+		if r.MatchedArchivePath == "" || !r.MetaTitleMatch && r.Similarity <= config.Options.Reloadable().FuzzySearchSimilarity {
+			continue
+		}
 
-				err = db.UpdateGallery(gallery, tags, reference, true)
-				if err != nil {
-					log.Z.Debug("could not tag gallery",
-						zap.String("path", gallery.ArchivePath),
-						zap.String("err", err.Error()))
-					continue
-				}
+		if r.MetaTitleMatch {
+			r.Similarity = 1
+		}
+		candidates = append(candidates, fuzzyCandidate{result: r, exhGallery: exhGallery})
+	}
 
-				if r.MetaTitleMatch {
-					log.Z.Info("exact match based on meta titles", zap.String("path", r.MatchedArchivePath))
-				} else {
-					log.Z.Info("fuzzy match",
-						zap.Float64("similarity", r.Similarity),
-						zap.String("path", r.MatchedArchivePath))
-				}
-			}
+	if len(candidates) == 0 {
+		return
+	}
+
+	// The most similar candidate first. Only it can be applied directly.
+	slices.SortStableFunc(candidates, func(a, b fuzzyCandidate) int {
+		return cmp.Compare(b.result.Similarity, a.result.Similarity)
+	})
+	if candidates[0].result.Similarity >= config.Options.GalleryOptions.FuzzyAutoAccept {
+		candidates = candidates[:1]
+	}
+
+	for _, candidate := range candidates {
+		r := candidate.result
+		gallery, tags, reference := convertExh(candidate.exhGallery, r.MatchedArchivePath, r.RelativeMetaPath, false)
+		gallery.UUID = noMatch.galleryUUID
+
+		applied, err := applyMatch(noMatch.galleryUUID, string(FuzzyMatch), r.RelativeMetaPath, r.Similarity, gallery, tags, reference)
+		if err != nil {
+			log.Z.Debug("could not tag gallery",
+				zap.String("path", gallery.ArchivePath),
+				zap.String("err", err.Error()))
+
+			cache.ProcessingStatusCache.AddMetadataError(noMatch.galleryUUID, err.Error(), map[string]string{
+				"metaType": string(FuzzyMatch),
+				"metaPath": r.RelativeMetaPath,
+			})
+			continue
+		}
+
+		switch {
+		case !applied:
+			log.Z.Info("fuzzy match added to the review queue",
+				zap.Float64("similarity", r.Similarity),
+				zap.String("path", r.MatchedArchivePath),
+				zap.String("metaPath", r.RelativeMetaPath))
+		case r.MetaTitleMatch:
+			log.Z.Info("exact match based on meta titles", zap.String("path", r.MatchedArchivePath))
+			cache.ProcessingStatusCache.AddMetadataParsed(noMatch.galleryUUID, string(FuzzyMatch))
+		default:
+			log.Z.Info("fuzzy match",
+				zap.Float64("similarity", r.Similarity),
+				zap.String("path", r.MatchedArchivePath))
+			cache.ProcessingStatusCache.AddMetadataParsed(noMatch.galleryUUID, string(FuzzyMatch))
 		}
 	}
 }
@@ -1,6 +1,7 @@
 package metadata
 
 import (
+	"context"
 	"github.com/Mangatsu/server/pkg/constants"
 	"path"
 	"path/filepath"
@@ -31,7 +32,8 @@ type TitleMeta struct {
 // ParseTitles parses all filenames and titles of the saved galleries in db.
 // tryNative tries to preserve the native language (usually Japanese) text.
 // overwrite writes over the previous values.
-func ParseTitles(tryNative bool, overwrite bool) {
+// Stops after the current gallery when the context is cancelled.
+func ParseTitles(ctx context.Context, tryNative bool, overwrite bool) {
 	libraries, err := db.GetLibraries()
 	if err != nil {
 		log.Z.Error("libraries could not be retrieved to parse titles", zap.String("err", err.Error()))
@@ -40,6 +42,11 @@ func ParseTitles(tryNative bool, overwrite bool) {
 
 	for _, library := range libraries {
 		for _, gallery := range library.Galleries {
+			if ctx.Err() != nil {
+				log.Z.Info("title parsing cancelled")
+				return
+			}
+
 			if db.TitleHashMatch(gallery.UUID) {
 				continue
 			}
@@ -148,7 +155,7 @@ func ParseTitles(tryNative bool, overwrite bool) {
 				gallery.Category = &manga
 			}
 
-			if err = db.UpdateGallery(gallery, currentTags, currentReference, true); err != nil {
+			if err = db.UpdateGallery(gallery, currentTags, currentReference, true, db.Author{Source: "title"}); err != nil {
 				log.Z.Error("failed to update gallery based on its title",
 					zap.String("gallery", gallery.UUID),
 					zap.String("err", err.Error()))
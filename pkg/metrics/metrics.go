@@ -0,0 +1,290 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets of the histograms in seconds, suitable for HTTP requests.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// collector writes its metrics in the Prometheus text format.
+type collector interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry holds the metrics exposed by the handler.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// DefaultRegistry holds the metrics of the server.
+var DefaultRegistry = &Registry{}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.collectors = append(r.collectors, c)
+	slices.SortFunc(r.collectors, func(a, b collector) int {
+		return strings.Compare(a.name(), b.name())
+	})
+}
+
+// Write writes all metrics in the Prometheus text format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	collectors := slices.Clone(r.collectors)
+	r.mu.Unlock()
+
+	buffered := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(buffered)
+	}
+
+	return buffered.Flush()
+}
+
+// Handler serves the metrics of the default registry.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = DefaultRegistry.Write(w)
+	})
+}
+
+// CounterVec is a set of counters partitioned by labels.
+type CounterVec struct {
+	metricName string
+	help       string
+	labels     []string
+	mu         sync.Mutex
+	values     map[string]*counterSeries
+}
+
+type counterSeries struct {
+	labelValues []string
+	value       float64
+}
+
+// NewCounterVec creates a counter and registers it to the default registry.
+func NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	c := &CounterVec{metricName: name, help: help, labels: labels, values: make(map[string]*counterSeries)}
+	DefaultRegistry.register(c)
+	return c
+}
+
+// Add adds the value to the counter with the label values. Negative values are ignored.
+func (c *CounterVec) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := seriesKey(labelValues)
+	series, ok := c.values[key]
+	if !ok {
+		series = &counterSeries{labelValues: slices.Clone(labelValues)}
+		c.values[key] = series
+	}
+	series.value += value
+}
+
+// Inc increments the counter with the label values by one.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Value returns the current value of the counter with the label values.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if series, ok := c.values[seriesKey(labelValues)]; ok {
+		return series.value
+	}
+
+	return 0
+}
+
+func (c *CounterVec) name() string {
+	return c.metricName
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	writeHeader(w, c.metricName, c.help, "counter")
+	for _, key := range sortedKeys(c.values) {
+		series := c.values[key]
+		writeSample(w, c.metricName, c.labels, series.labelValues, "", "", series.value)
+	}
+}
+
+// HistogramVec is a set of histograms partitioned by labels.
+type HistogramVec struct {
+	metricName string
+	help       string
+	labels     []string
+	buckets    []float64
+	mu         sync.Mutex
+	values     map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64 // Cumulative counts of the buckets
+	count       uint64
+	sum         float64
+}
+
+// NewHistogramVec creates a histogram and registers it to the default registry. Buckets are the upper bounds.
+func NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		metricName: name,
+		help:       help,
+		labels:     labels,
+		buckets:    slices.Clone(buckets),
+		values:     make(map[string]*histogramSeries),
+	}
+	slices.Sort(h.buckets)
+	DefaultRegistry.register(h)
+	return h
+}
+
+// Observe adds the value to the histogram with the label values.
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := seriesKey(labelValues)
+	series, ok := h.values[key]
+	if !ok {
+		series = &histogramSeries{labelValues: slices.Clone(labelValues), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = series
+	}
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			series.counts[i]++
+		}
+	}
+	series.count++
+	series.sum += value
+}
+
+func (h *HistogramVec) name() string {
+	return h.metricName
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeHeader(w, h.metricName, h.help, "histogram")
+	for _, key := range sortedKeys(h.values) {
+		series := h.values[key]
+		for i, bound := range h.buckets {
+			writeSample(w, h.metricName+"_bucket", h.labels, series.labelValues, "le", formatFloat(bound), float64(series.counts[i]))
+		}
+		writeSample(w, h.metricName+"_bucket", h.labels, series.labelValues, "le", "+Inf", float64(series.count))
+		writeSample(w, h.metricName+"_sum", h.labels, series.labelValues, "", "", series.sum)
+		writeSample(w, h.metricName+"_count", h.labels, series.labelValues, "", "", float64(series.count))
+	}
+}
+
+// GaugeFunc is a gauge whose value is read when the metrics are collected.
+type GaugeFunc struct {
+	metricName string
+	help       string
+	collect    func() (float64, bool)
+}
+
+// NewGaugeFunc creates a gauge and registers it to the default registry. The gauge is left out if collect
+// returns false, for example when the value could not be read.
+func NewGaugeFunc(name string, help string, collect func() (float64, bool)) *GaugeFunc {
+	g := &GaugeFunc{metricName: name, help: help, collect: collect}
+	DefaultRegistry.register(g)
+	return g
+}
+
+func (g *GaugeFunc) name() string {
+	return g.metricName
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	value, ok := g.collect()
+	if !ok {
+		return
+	}
+
+	writeHeader(w, g.metricName, g.help, "gauge")
+	writeSample(w, g.metricName, nil, nil, "", "", value)
+}
+
+func writeHeader(w *bufio.Writer, name string, help string, metricType string) {
+	w.WriteString("# HELP " + name + " " + strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help) + "\n")
+	w.WriteString("# TYPE " + name + " " + metricType + "\n")
+}
+
+func writeSample(w *bufio.Writer, name string, labels []string, labelValues []string, extraLabel string, extraValue string, value float64) {
+	w.WriteString(name)
+
+	pairs := make([]string, 0, len(labels)+1)
+	for i, label := range labels {
+		labelValue := ""
+		if i < len(labelValues) {
+			labelValue = labelValues[i]
+		}
+		pairs = append(pairs, label+`="`+escapeLabelValue(labelValue)+`"`)
+	}
+	if extraLabel != "" {
+		pairs = append(pairs, extraLabel+`="`+extraValue+`"`)
+	}
+	if len(pairs) > 0 {
+		w.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+
+	w.WriteString(" " + formatFloat(value) + "\n")
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+// seriesKey joins the label values to a map key. The separator cannot appear in valid UTF-8.
+func seriesKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+func sortedKeys[T any](values map[string]T) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
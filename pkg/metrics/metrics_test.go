@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	counter := NewCounterVec("test_requests_total", "Test requests.", "route")
+	counter.Inc(`/a"b`)
+	counter.Add(2, "/c")
+	counter.Add(-1, "/c")
+
+	histogram := NewHistogramVec("test_duration_seconds", "Test latency.", []float64{1, 0.1}, "route")
+	histogram.Observe(0.05, "/c")
+	histogram.Observe(0.5, "/c")
+
+	NewGaugeFunc("test_gauge", "Test gauge.", func() (float64, bool) { return 3, true })
+	NewGaugeFunc("test_missing", "Test missing gauge.", func() (float64, bool) { return 0, false })
+
+	var output strings.Builder
+	if err := DefaultRegistry.Write(&output); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"# TYPE test_requests_total counter\n",
+		`test_requests_total{route="/a\"b"} 1` + "\n",
+		`test_requests_total{route="/c"} 2` + "\n",
+		"# TYPE test_duration_seconds histogram\n",
+		`test_duration_seconds_bucket{route="/c",le="0.1"} 1` + "\n",
+		`test_duration_seconds_bucket{route="/c",le="1"} 2` + "\n",
+		`test_duration_seconds_bucket{route="/c",le="+Inf"} 2` + "\n",
+		`test_duration_seconds_sum{route="/c"} 0.55` + "\n",
+		`test_duration_seconds_count{route="/c"} 2` + "\n",
+		"test_gauge 3\n",
+	}
+	for _, line := range expected {
+		if !strings.Contains(output.String(), line) {
+			t.Errorf("Expected %q in the output:\n%s", line, output.String())
+		}
+	}
+
+	if strings.Contains(output.String(), "test_missing") {
+		t.Error("Expected the gauge without a value to be left out")
+	}
+}
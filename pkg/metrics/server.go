@@ -0,0 +1,22 @@
+package metrics
+
+// Metrics of the server. Totals of the database and the cache are registered as gauges by the API.
+var (
+	HTTPRequests = NewCounterVec("mangatsu_http_requests_total",
+		"HTTP requests by method, route and status code.", "method", "route", "status")
+	HTTPDuration = NewHistogramVec("mangatsu_http_request_duration_seconds",
+		"Latency of the HTTP requests by method and route.", DefaultBuckets, "method", "route")
+
+	CacheRequests = NewCounterVec("mangatsu_cache_requests_total",
+		"Reads of the gallery cache by result, hit or miss. A miss extracts the archive.", "result")
+
+	TaskRuns = NewCounterVec("mangatsu_task_runs_total",
+		"Finished runs of the scan, thumbnail and metadata tasks.", "task")
+	TaskDuration = NewHistogramVec("mangatsu_task_duration_seconds",
+		"Duration of the runs of the scan, thumbnail and metadata tasks.",
+		[]float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600, 7200}, "task")
+	TaskItems = NewCounterVec("mangatsu_task_items_total",
+		"Galleries, thumbnails and metadata processed by the tasks, by kind.", "task", "kind")
+	TaskErrors = NewCounterVec("mangatsu_task_errors_total",
+		"Errors of the scan, thumbnail and metadata tasks.", "task")
+)
@@ -0,0 +1,378 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Mangatsu/server/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// LoginTTL is how long the user has to log in at the provider.
+const LoginTTL = 10 * time.Minute
+
+// keyRefreshInterval limits how often the signing keys are refetched when an unknown key is seen.
+const keyRefreshInterval = time.Minute
+
+var ErrInvalidState = errors.New("login state not found or expired")
+
+// Identity is the user returned by the provider.
+type Identity struct {
+	Issuer   string
+	Subject  string
+	Username string
+	Email    string
+	Name     string
+	Groups   []string
+}
+
+// Provider is an OpenID Connect provider using the authorization code flow with PKCE.
+type Provider struct {
+	options      config.OIDCOptions
+	clientSecret string
+	httpClient   *http.Client
+	discovery    discoveryDocument
+
+	keysMu        sync.Mutex
+	keys          map[string]*rsa.PublicKey
+	keysFetchedAt time.Time
+
+	loginsMu sync.Mutex
+	logins   map[string]pendingLogin
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type pendingLogin struct {
+	codeVerifier string
+	nonce        string
+	expiresAt    time.Time
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// NewProvider fetches the discovery document of the issuer.
+func NewProvider(ctx context.Context, options config.OIDCOptions, clientSecret string) (*Provider, error) {
+	provider := &Provider{
+		options:      options,
+		clientSecret: clientSecret,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+		keys:         map[string]*rsa.PublicKey{},
+		logins:       map[string]pendingLogin{},
+	}
+
+	discoveryURL := options.Issuer + "/.well-known/openid-configuration"
+	if err := provider.getJSON(ctx, discoveryURL, &provider.discovery); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+
+	if provider.discovery.Issuer != options.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %s does not match %s", provider.discovery.Issuer, options.Issuer)
+	}
+	if provider.discovery.AuthorizationEndpoint == "" || provider.discovery.TokenEndpoint == "" ||
+		provider.discovery.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: endpoints missing from the discovery document")
+	}
+
+	return provider, nil
+}
+
+// StartLogin returns the URL of the provider where the user is sent to log in, and the state of the login.
+// The state has to be stored in the browser starting the login and given back to FinishLogin.
+func (p *Provider) StartLogin() (string, string, error) {
+	state, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	codeVerifier, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	p.loginsMu.Lock()
+	for key, login := range p.logins {
+		if now.After(login.expiresAt) {
+			delete(p.logins, key)
+		}
+	}
+	p.logins[state] = pendingLogin{codeVerifier: codeVerifier, nonce: nonce, expiresAt: now.Add(LoginTTL)}
+	p.loginsMu.Unlock()
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.options.ClientID},
+		"redirect_uri":          {p.options.RedirectURL},
+		"scope":                 {strings.Join(p.options.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(p.discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return p.discovery.AuthorizationEndpoint + separator + query.Encode(), state, nil
+}
+
+// FinishLogin exchanges the authorization code for an ID token and returns the verified identity. The state of the
+// callback must match the browserState stored in the browser that started the login, so that a callback of
+// someone else's login cannot be replayed in another browser.
+func (p *Provider) FinishLogin(ctx context.Context, state string, browserState string, code string) (Identity, error) {
+	p.loginsMu.Lock()
+	login, found := p.logins[state]
+	delete(p.logins, state)
+	p.loginsMu.Unlock()
+
+	sameBrowser := browserState != "" && subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) == 1
+	if !found || !sameBrowser || time.Now().After(login.expiresAt) {
+		return Identity{}, ErrInvalidState
+	}
+
+	rawIDToken, err := p.exchange(ctx, code, login.codeVerifier)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	return p.verifyIDToken(ctx, rawIDToken, login.nonce)
+}
+
+// exchange redeems the authorization code at the token endpoint and returns the raw ID token.
+func (p *Provider) exchange(ctx context.Context, code string, codeVerifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.options.RedirectURL},
+		"client_id":     {p.options.ClientID},
+		"code_verifier": {codeVerifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.options.ClientID), url.QueryEscape(p.clientSecret))
+	}
+
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	var tokenResponse struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&tokenResponse); err != nil {
+		return "", fmt.Errorf("oidc token response: %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK || tokenResponse.Error != "" {
+		return "", fmt.Errorf("oidc token response: %d %s %s", res.StatusCode, tokenResponse.Error,
+			tokenResponse.ErrorDescription)
+	}
+	if tokenResponse.IDToken == "" {
+		return "", errors.New("oidc token response: id_token missing")
+	}
+
+	return tokenResponse.IDToken, nil
+}
+
+// verifyIDToken checks the signature, issuer, audience, expiry and nonce of the ID token.
+func (p *Provider) verifyIDToken(ctx context.Context, rawIDToken string, nonce string) (Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(
+		rawIDToken,
+		claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return p.signingKey(ctx, kid)
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Name}),
+		jwt.WithIssuer(p.discovery.Issuer),
+		jwt.WithAudience(p.options.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return Identity{}, fmt.Errorf("oidc id token: %w", err)
+	}
+
+	if claimedNonce, _ := claims["nonce"].(string); claimedNonce != nonce {
+		return Identity{}, errors.New("oidc id token: nonce does not match")
+	}
+
+	identity := Identity{
+		Issuer: p.discovery.Issuer,
+		Groups: stringsClaim(claims[p.options.GroupsClaim]),
+	}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	identity.Username, _ = claims["preferred_username"].(string)
+	if identity.Subject == "" {
+		return Identity{}, errors.New("oidc id token: sub missing")
+	}
+
+	if identity.Username == "" {
+		identity.Username, _, _ = strings.Cut(identity.Email, "@")
+	}
+
+	return identity, nil
+}
+
+// signingKey returns the key of the provider by its ID. Keys are refetched when an unknown key is seen,
+// as providers rotate them.
+func (p *Provider) signingKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.keysMu.Lock()
+	defer p.keysMu.Unlock()
+
+	if key := p.findKey(kid); key != nil {
+		return key, nil
+	}
+
+	if time.Since(p.keysFetchedAt) < keyRefreshInterval {
+		return nil, fmt.Errorf("signing key %s not found", kid)
+	}
+
+	var keySet struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.discovery.JWKSURI, &keySet); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, webKey := range keySet.Keys {
+		if webKey.Kty != "RSA" || (webKey.Use != "" && webKey.Use != "sig") {
+			continue
+		}
+
+		key, err := parseRSAKey(webKey)
+		if err != nil {
+			continue
+		}
+		keys[webKey.Kid] = key
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	if key := p.findKey(kid); key != nil {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("signing key %s not found", kid)
+}
+
+// findKey returns the key by its ID, or the only key if the token has no key ID.
+func (p *Provider) findKey(kid string) *rsa.PublicKey {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key
+		}
+	}
+
+	return p.keys[kid]
+}
+
+func (p *Provider) getJSON(ctx context.Context, target string, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", target, res.StatusCode)
+	}
+
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+func parseRSAKey(webKey jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(webKey.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(webKey.E)
+	if err != nil {
+		return nil, err
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() < 3 {
+		return nil, errors.New("invalid RSA exponent")
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+// stringsClaim returns a claim that is either a string or a list of strings.
+func stringsClaim(claim interface{}) []string {
+	switch value := claim.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+func randomString() (string, error) {
+	x := make([]byte, 32)
+	if _, err := rand.Read(x); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(x), nil
+}
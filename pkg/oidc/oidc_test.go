@@ -0,0 +1,289 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Mangatsu/server/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// identityProviderFixture is a stand-in for a self-hosted OpenID Connect provider.
+type identityProviderFixture struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu      sync.Mutex
+	key     *rsa.PrivateKey
+	kid     string
+	codes   map[string]url.Values // Authorization requests by the issued code
+	mangle  func(claims jwt.MapClaims)
+	issuer  string
+	clients map[string]string
+}
+
+func newIdentityProviderFixture(t *testing.T) *identityProviderFixture {
+	idp := &identityProviderFixture{
+		t:       t,
+		codes:   map[string]url.Values{},
+		clients: map[string]string{"mangatsu": "secret"},
+	}
+	idp.rotateKey("key-1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.issuer,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"use": "sig",
+				"kid": idp.kid,
+				"n":   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", idp.handleToken)
+
+	idp.server = httptest.NewServer(mux)
+	idp.issuer = idp.server.URL
+	return idp
+}
+
+func (idp *identityProviderFixture) rotateKey(kid string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		idp.t.Fatal("Generating RSA key failed:", err)
+	}
+
+	idp.mu.Lock()
+	idp.key = key
+	idp.kid = kid
+	idp.mu.Unlock()
+}
+
+// authorize acts as the user logging in at the provider and returns the code and state sent to the redirect URL.
+func (idp *identityProviderFixture) authorize(authURL string) (string, string) {
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		idp.t.Fatal("Invalid authorization URL:", err)
+	}
+
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		idp.t.Fatal("Authorization request without PKCE:", authURL)
+	}
+	if query.Get("client_id") != "mangatsu" || query.Get("response_type") != "code" {
+		idp.t.Fatal("Unexpected authorization request:", authURL)
+	}
+
+	code := "code-" + query.Get("state")[:8]
+	idp.mu.Lock()
+	idp.codes[code] = query
+	idp.mu.Unlock()
+
+	return code, query.Get("state")
+}
+
+func (idp *identityProviderFixture) handleToken(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok || idp.clients[clientID] != clientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+		return
+	}
+
+	idp.mu.Lock()
+	authRequest, found := idp.codes[r.PostFormValue("code")]
+	delete(idp.codes, r.PostFormValue("code"))
+	idp.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !found || base64.RawURLEncoding.EncodeToString(verifier[:]) != authRequest.Get("code_challenge") ||
+		r.PostFormValue("redirect_uri") != authRequest.Get("redirect_uri") {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":                idp.issuer,
+		"sub":                "user-1",
+		"aud":                clientID,
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"iat":                time.Now().Unix(),
+		"nonce":              authRequest.Get("nonce"),
+		"preferred_username": "alice",
+		"email":              "alice@example.org",
+		"groups":             []string{"family", "mangatsu-admins"},
+	}
+	if idp.mangle != nil {
+		idp.mangle(claims)
+	}
+
+	idp.mu.Lock()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = idp.kid
+	idToken, err := token.SignedString(idp.key)
+	idp.mu.Unlock()
+	if err != nil {
+		idp.t.Error("Signing ID token failed:", err)
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "unused", "token_type": "Bearer", "id_token": idToken})
+}
+
+func (idp *identityProviderFixture) options() config.OIDCOptions {
+	return config.OIDCOptions{
+		Issuer:      idp.issuer,
+		ClientID:    "mangatsu",
+		RedirectURL: "https://mangatsu.example.org/api/v1/oidc/callback",
+		Scopes:      []string{"openid", "profile", "groups"},
+		GroupsClaim: "groups",
+	}
+}
+
+func login(t *testing.T, idp *identityProviderFixture, provider *Provider) (Identity, error) {
+	authURL, browserState, err := provider.StartLogin()
+	if err != nil {
+		t.Fatal("Starting login failed:", err)
+	}
+
+	code, state := idp.authorize(authURL)
+	return provider.FinishLogin(context.Background(), state, browserState, code)
+}
+
+func TestLogin(t *testing.T) {
+	idp := newIdentityProviderFixture(t)
+	defer idp.server.Close()
+
+	provider, err := NewProvider(context.Background(), idp.options(), "secret")
+	if err != nil {
+		t.Fatal("Discovery failed:", err)
+	}
+
+	identity, err := login(t, idp, provider)
+	if err != nil {
+		t.Fatal("Login failed:", err)
+	}
+
+	if identity.Issuer != idp.issuer || identity.Subject != "user-1" || identity.Username != "alice" {
+		t.Errorf("Unexpected identity: %+v", identity)
+	}
+	if len(identity.Groups) != 2 || identity.Groups[1] != "mangatsu-admins" {
+		t.Errorf("Unexpected groups: %v", identity.Groups)
+	}
+
+	// The provider rotates its signing key.
+	provider.keysFetchedAt = time.Time{}
+	idp.rotateKey("key-2")
+	if _, err = login(t, idp, provider); err != nil {
+		t.Error("Login after key rotation failed:", err)
+	}
+
+	// A state can only be used once.
+	authURL, browserState, _ := provider.StartLogin()
+	code, state := idp.authorize(authURL)
+	if _, err = provider.FinishLogin(context.Background(), state, browserState, code); err != nil {
+		t.Error("Login failed:", err)
+	}
+	if _, err = provider.FinishLogin(context.Background(), state, browserState, code); err != ErrInvalidState {
+		t.Error("Expected reused state to be rejected, got:", err)
+	}
+}
+
+// TestLoginRejectsReplayedCallback checks that the callback of a login started by someone else, such as an attacker
+// logged in at the provider with their own account, is rejected in a browser without the state of that login.
+func TestLoginRejectsReplayedCallback(t *testing.T) {
+	idp := newIdentityProviderFixture(t)
+	defer idp.server.Close()
+
+	provider, err := NewProvider(context.Background(), idp.options(), "secret")
+	if err != nil {
+		t.Fatal("Discovery failed:", err)
+	}
+
+	// The victim has no state cookie.
+	authURL, _, _ := provider.StartLogin()
+	code, state := idp.authorize(authURL)
+	if _, err = provider.FinishLogin(context.Background(), state, "", code); err != ErrInvalidState {
+		t.Error("Expected callback without the browser state to be rejected, got:", err)
+	}
+
+	// The victim has started a login of their own.
+	attackerURL, _, _ := provider.StartLogin()
+	_, victimState, _ := provider.StartLogin()
+	code, state = idp.authorize(attackerURL)
+	if _, err = provider.FinishLogin(context.Background(), state, victimState, code); err != ErrInvalidState {
+		t.Error("Expected callback with the state of another login to be rejected, got:", err)
+	}
+}
+
+func TestLoginRejectsInvalidTokens(t *testing.T) {
+	idp := newIdentityProviderFixture(t)
+	defer idp.server.Close()
+
+	provider, err := NewProvider(context.Background(), idp.options(), "secret")
+	if err != nil {
+		t.Fatal("Discovery failed:", err)
+	}
+
+	cases := map[string]func(claims jwt.MapClaims){
+		"nonce":    func(claims jwt.MapClaims) { claims["nonce"] = "other" },
+		"audience": func(claims jwt.MapClaims) { claims["aud"] = "other-client" },
+		"issuer":   func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example.org" },
+		"expired":  func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"subject":  func(claims jwt.MapClaims) { delete(claims, "sub") },
+	}
+	for name, mangle := range cases {
+		idp.mangle = mangle
+		if _, err = login(t, idp, provider); err == nil {
+			t.Errorf("Expected ID token with invalid %s to be rejected", name)
+		}
+	}
+
+	idp.mangle = nil
+	wrongSecret, err := NewProvider(context.Background(), idp.options(), "wrong")
+	if err != nil {
+		t.Fatal("Discovery failed:", err)
+	}
+	if _, err = login(t, idp, wrongSecret); err == nil {
+		t.Error("Expected login with a wrong client secret to fail")
+	}
+
+	if _, err = provider.FinishLogin(context.Background(), "unknown", "unknown", "code"); err != ErrInvalidState {
+		t.Error("Expected unknown state to be rejected, got:", err)
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	idp := newIdentityProviderFixture(t)
+	defer idp.server.Close()
+
+	options := idp.options()
+	idp.issuer = "https://other.example.org"
+	if _, err := NewProvider(context.Background(), options, "secret"); err == nil {
+		t.Error("Expected discovery with a mismatching issuer to fail")
+	}
+}
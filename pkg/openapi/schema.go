@@ -0,0 +1,186 @@
+package openapi
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of the OpenAPI 3.0 schema object used to describe and validate the requests.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// SchemaOf generates the schema of a value from its type. Properties are named like encoding/json names them.
+//
+// Fields can be constrained with the schema tag, such as `schema:"required,min=1,max=64"` or `schema:"enum=a|b"`.
+// Min and max limit the length of strings, the items of lists and the value of numbers. Enums of lists apply to
+// their items.
+func SchemaOf(v any) *Schema {
+	return schemaOfType(reflect.TypeOf(v))
+}
+
+func schemaOfType(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		schema := schemaOfType(t.Elem())
+		schema.Nullable = true
+		return schema
+	}
+
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return integerSchema(t, "int32")
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return integerSchema(t, "int64")
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaOfType(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaOfType(t.Elem())}
+	case reflect.Struct:
+		schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		addFields(schema, t)
+		return schema
+	default:
+		return &Schema{}
+	}
+}
+
+// integerSchema limits the value to the range of the type, so that it can be decoded.
+func integerSchema(t reflect.Type, format string) *Schema {
+	schema := &Schema{Type: "integer", Format: format}
+
+	switch t.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		bits := t.Bits()
+		schema.Minimum = float(-math.Pow(2, float64(bits-1)))
+		schema.Maximum = float(math.Pow(2, float64(bits-1)) - 1)
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		schema.Minimum = float(0)
+		schema.Maximum = float(math.Pow(2, float64(t.Bits())) - 1)
+	case reflect.Uint, reflect.Uint64:
+		schema.Minimum = float(0)
+	}
+
+	return schema
+}
+
+// addFields adds the exported fields of a struct as properties. Fields of embedded structs are promoted, like
+// encoding/json does.
+func addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, omitted := jsonName(field)
+		if omitted {
+			continue
+		}
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			addFields(schema, field.Type)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := schemaOfType(field.Type)
+		if applyTag(property, field.Tag.Get("schema")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+}
+
+// jsonName returns the name in the json tag, and whether the field is left out of JSON.
+func jsonName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+
+	name, _, _ := strings.Cut(tag, ",")
+	return name, false
+}
+
+// applyTag applies the constraints of a schema tag. Returns true if the field is required.
+func applyTag(schema *Schema, tag string) bool {
+	required := false
+	for _, option := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "required":
+			required = true
+		case "enum":
+			target := schema
+			if schema.Type == "array" {
+				target = schema.Items
+			}
+			target.Enum = strings.Split(value, "|")
+		case "min", "max":
+			limit, err := strconv.Atoi(value)
+			if err != nil {
+				panic("invalid schema tag: " + tag)
+			}
+			applyLimit(schema, key == "min", limit)
+		}
+	}
+
+	return required
+}
+
+func applyLimit(schema *Schema, isMin bool, limit int) {
+	switch schema.Type {
+	case "string":
+		if isMin {
+			schema.MinLength = &limit
+		} else {
+			schema.MaxLength = &limit
+		}
+	case "array":
+		if isMin {
+			schema.MinItems = &limit
+		} else {
+			schema.MaxItems = &limit
+		}
+	default:
+		if isMin {
+			schema.Minimum = float(float64(limit))
+		} else {
+			schema.Maximum = float(float64(limit))
+		}
+	}
+}
+
+func float(value float64) *float64 {
+	return &value
+}
@@ -0,0 +1,96 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+)
+
+type embedded struct {
+	Note string
+}
+
+type form struct {
+	embedded
+	Name    string   `schema:"required,min=1,max=5"`
+	Kind    *string  `json:"kind" schema:"enum=a|b"`
+	Scopes  []string `schema:"min=1,enum=read|write"`
+	Count   int32
+	Tags    map[string][]string
+	Ignored string `json:"-"`
+}
+
+func TestSchemaOf(t *testing.T) {
+	schema := SchemaOf(form{})
+
+	if !reflect.DeepEqual(schema.Required, []string{"Name"}) {
+		t.Errorf("Expected Name to be required, got %v", schema.Required)
+	}
+	for _, name := range []string{"Note", "Name", "kind", "Scopes", "Count", "Tags"} {
+		if schema.Properties[name] == nil {
+			t.Errorf("Expected property %s", name)
+		}
+	}
+	if schema.Properties["Ignored"] != nil {
+		t.Error("Expected fields left out of JSON to be left out of the schema")
+	}
+	if kind := schema.Properties["kind"]; !kind.Nullable || len(kind.Enum) != 2 {
+		t.Errorf("Expected a nullable enum, got %+v", kind)
+	}
+	if scopes := schema.Properties["Scopes"]; *scopes.MinItems != 1 || len(scopes.Items.Enum) != 2 {
+		t.Errorf("Expected the limit on the list and the enum on the items, got %+v", scopes)
+	}
+	if count := schema.Properties["Count"]; count.Format != "int32" || *count.Maximum != 2147483647 {
+		t.Errorf("Expected an int32 range, got %+v", count)
+	}
+}
+
+func TestValidateJSON(t *testing.T) {
+	schema := SchemaOf(form{})
+
+	tests := []struct {
+		body     string
+		problems []string
+	}{
+		{`{"Name": "abc", "kind": null, "Scopes": ["read"], "Count": 1, "Tags": {"artist": ["x"]}}`, nil},
+		{`{"Unknown": true, "Name": "abc"}`, nil},
+		{`{}`, []string{"body.Name: is required"}},
+		{`{"Name": "abcdef", "kind": "c"}`, []string{
+			"body.Name: must be at most 5 characters",
+			"body.kind: must be one of [a b]",
+		}},
+		{`{"Name": "a", "Scopes": ["admin"], "Count": 2147483648}`, []string{
+			"body.Count: must be at most 2147483647",
+			"body.Scopes[0]: must be one of [read write]",
+		}},
+		{`{"Name": 1, "Count": 1.5, "Tags": {"artist": "x"}}`, []string{
+			"body.Count: must be an integer",
+			"body.Name: must be a string",
+			"body.Tags.artist: must be a list",
+		}},
+		{`{"name": "abc", "KIND": "a", "scopes": ["read"]}`, nil},
+		{`{"name": "abcdef", "Kind": "c"}`, []string{
+			"body.Kind: must be one of [a b]",
+			"body.name: must be at most 5 characters",
+		}},
+		{`[]`, []string{"body: must be an object"}},
+	}
+
+	for _, test := range tests {
+		problems, err := schema.ValidateJSON([]byte(test.body))
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.body, err)
+			continue
+		}
+		var messages []string
+		for _, problem := range problems {
+			messages = append(messages, problem.String())
+		}
+		if !reflect.DeepEqual(messages, test.problems) {
+			t.Errorf("%s: expected %q, got %q", test.body, test.problems, messages)
+		}
+	}
+
+	if _, err := schema.ValidateJSON([]byte(`{"Name": "a"`)); err == nil {
+		t.Error("Expected an error for invalid JSON")
+	}
+}
@@ -0,0 +1,179 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Problem is an invalid value found by the validation. Field is the path of the value, such as body.Tags[0].
+type Problem struct {
+	Field   string `json:",omitempty"`
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Field == "" {
+		return p.Message
+	}
+	return p.Field + ": " + p.Message
+}
+
+// ValidateJSON parses a JSON document and validates it against the schema. Returns the problems found, or an error
+// if the document is not valid JSON.
+func (s *Schema) ValidateJSON(data []byte) ([]Problem, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after the JSON value")
+	}
+
+	return s.Validate(value), nil
+}
+
+// Validate validates a value decoded from JSON with numbers as json.Number.
+func (s *Schema) Validate(value any) []Problem {
+	var problems []Problem
+	s.validate(value, "body", &problems)
+	return problems
+}
+
+func (s *Schema) validate(value any, path string, problems *[]Problem) {
+	report := func(format string, args ...any) {
+		*problems = append(*problems, Problem{Field: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if value == nil {
+		if !s.Nullable && s.Type != "" {
+			report("must not be null")
+		}
+		return
+	}
+
+	switch s.Type {
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			report("must be a boolean")
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			report("must be a string")
+			return
+		}
+		length := utf8.RuneCountInString(text)
+		if s.MinLength != nil && length < *s.MinLength {
+			report("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			report("must be at most %d characters", *s.MaxLength)
+		}
+		if s.Enum != nil && !slices.Contains(s.Enum, text) {
+			report("must be one of %v", s.Enum)
+		}
+	case "integer", "number":
+		s.validateNumber(value, report)
+	case "array":
+		list, ok := value.([]any)
+		if !ok {
+			report("must be a list")
+			return
+		}
+		if s.MinItems != nil && len(list) < *s.MinItems {
+			report("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(list) > *s.MaxItems {
+			report("must have at most %d items", *s.MaxItems)
+		}
+		for i, item := range list {
+			s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			report("must be an object")
+			return
+		}
+		keys := keysOf(object)
+		for _, name := range s.Required {
+			if !slices.ContainsFunc(keys, func(key string) bool { return strings.EqualFold(key, name) }) {
+				*problems = append(*problems, Problem{Field: path + "." + name, Message: "is required"})
+			}
+		}
+
+		for _, key := range keys {
+			if property := s.property(key); property != nil {
+				property.validate(object[key], path+"."+key, problems)
+			} else if s.AdditionalProperties != nil {
+				s.AdditionalProperties.validate(object[key], path+"."+key, problems)
+			}
+		}
+	}
+}
+
+// property returns the schema of the property matching the key like encoding/json matches the fields:
+// exactly or otherwise case-insensitively. Nil if there's none.
+func (s *Schema) property(key string) *Schema {
+	if property, found := s.Properties[key]; found {
+		return property
+	}
+	for name, property := range s.Properties {
+		if strings.EqualFold(name, key) {
+			return property
+		}
+	}
+	return nil
+}
+
+// keysOf returns the keys of the object sorted, so that the problems are always listed in the same order.
+func keysOf(object map[string]any) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *Schema) validateNumber(value any, report func(format string, args ...any)) {
+	number, ok := value.(json.Number)
+	if !ok {
+		if s.Type == "integer" {
+			report("must be an integer")
+		} else {
+			report("must be a number")
+		}
+		return
+	}
+
+	// Parsed as a big float, so that large integers are compared exactly.
+	parsed, _, err := big.ParseFloat(number.String(), 10, 128, big.ToNearestEven)
+	if err != nil {
+		report("must be a number")
+		return
+	}
+	if s.Type == "integer" && !parsed.IsInt() {
+		report("must be an integer")
+		return
+	}
+	if s.Minimum != nil && parsed.Cmp(big.NewFloat(*s.Minimum)) < 0 {
+		report("must be at least %s", formatLimit(*s.Minimum))
+	}
+	if s.Maximum != nil && parsed.Cmp(big.NewFloat(*s.Maximum)) > 0 {
+		report("must be at most %s", formatLimit(*s.Maximum))
+	}
+}
+
+func formatLimit(limit float64) string {
+	return big.NewFloat(limit).Text('f', -1)
+}
@@ -0,0 +1,70 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test vectors of RFC 6238 for SHA-1.
+func TestHOTPVectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	vectors := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+
+	for unix, expected := range vectors {
+		if code := hotp(key, step(time.Unix(unix, 0)), 8); code != expected {
+			t.Errorf("Expected %s at %d, got %s", expected, unix, code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111111, 0)
+
+	code, err := Code(secret, now)
+	if err != nil || code != "050471" {
+		t.Fatalf("Expected code 050471, got %s (%v)", code, err)
+	}
+
+	matched, ok := Validate(secret, code, now, 0)
+	if !ok || matched != step(now) {
+		t.Error("Expected the current code to be valid")
+	}
+	if _, ok = Validate(secret, code, now, matched); ok {
+		t.Error("Expected a used code to be rejected")
+	}
+
+	if _, ok = Validate(secret, code, now.Add(Period*time.Second), 0); !ok {
+		t.Error("Expected the previous code to be valid")
+	}
+	if _, ok = Validate(secret, code, now.Add(3*Period*time.Second), 0); ok {
+		t.Error("Expected an old code to be rejected")
+	}
+	if _, ok = Validate(secret, "000000", now, 0); ok && code != "000000" {
+		t.Error("Expected a wrong code to be rejected")
+	}
+	if _, ok = Validate(secret, code[:5], now, 0); ok {
+		t.Error("Expected a short code to be rejected")
+	}
+}
+
+func TestURI(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatal("Generating secret failed:", err)
+	}
+
+	uri := URI("Mangatsu", "alice", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/Mangatsu:alice?") || !strings.Contains(uri, "secret="+secret) {
+		t.Error("Unexpected URI:", uri)
+	}
+}
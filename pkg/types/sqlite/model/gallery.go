@@ -33,4 +33,8 @@ type Gallery struct {
 	UpdatedAt       time.Time
 	Deleted         bool
 	PageThumbnails  *int32
+	DeletedAt       *time.Time
+	Volume          *float64
+	Chapter         *float64
+	SeriesOrder     *int32
 }
@@ -8,7 +8,12 @@
 package model
 
 type Library struct {
-	ID     int32 `sql:"primary_key"`
-	Path   string
-	Layout string
+	ID       int32 `sql:"primary_key"`
+	Path     string
+	Layout   string
+	Name     *string
+	Nsfw     bool
+	Language *string
+	Ltr      *bool
+	Deleted  bool
 }
@@ -12,12 +12,19 @@ import (
 )
 
 type User struct {
-	UUID      string
-	Username  string
-	Password  []byte
-	Salt      []byte
-	Role      int32
-	BcryptPw  *string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	UUID         string
+	Username     string
+	Password     []byte
+	Salt         []byte
+	Role         int32
+	BcryptPw     *string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	HideNsfw     bool
+	TotpSecret   *string
+	TotpEnabled  bool
+	TotpLastStep int64
+	FailedLogins int32
+	LockedUntil  *time.Time
+	Pending      bool
 }
@@ -38,6 +38,10 @@ type galleryTable struct {
 	UpdatedAt       sqlite.ColumnTimestamp
 	Deleted         sqlite.ColumnBool
 	PageThumbnails  sqlite.ColumnInteger
+	DeletedAt       sqlite.ColumnTimestamp
+	Volume          sqlite.ColumnFloat
+	Chapter         sqlite.ColumnFloat
+	SeriesOrder     sqlite.ColumnInteger
 
 	AllColumns     sqlite.ColumnList
 	MutableColumns sqlite.ColumnList
@@ -89,8 +93,12 @@ func newGalleryTableImpl(schemaName, tableName, alias string) galleryTable {
 		UpdatedAtColumn       = sqlite.TimestampColumn("updated_at")
 		DeletedColumn         = sqlite.BoolColumn("deleted")
 		PageThumbnailsColumn  = sqlite.IntegerColumn("page_thumbnails")
-		allColumns            = sqlite.ColumnList{UUIDColumn, LibraryIDColumn, ArchivePathColumn, TitleColumn, TitleNativeColumn, TitleTranslatedColumn, CategoryColumn, SeriesColumn, ReleasedColumn, LanguageColumn, TranslatedColumn, NsfwColumn, HiddenColumn, ImageCountColumn, ArchiveSizeColumn, ArchiveHashColumn, ThumbnailColumn, CreatedAtColumn, UpdatedAtColumn, DeletedColumn, PageThumbnailsColumn}
-		mutableColumns        = sqlite.ColumnList{LibraryIDColumn, ArchivePathColumn, TitleColumn, TitleNativeColumn, TitleTranslatedColumn, CategoryColumn, SeriesColumn, ReleasedColumn, LanguageColumn, TranslatedColumn, NsfwColumn, HiddenColumn, ImageCountColumn, ArchiveSizeColumn, ArchiveHashColumn, ThumbnailColumn, CreatedAtColumn, UpdatedAtColumn, DeletedColumn, PageThumbnailsColumn}
+		DeletedAtColumn       = sqlite.TimestampColumn("deleted_at")
+		VolumeColumn          = sqlite.FloatColumn("volume")
+		ChapterColumn         = sqlite.FloatColumn("chapter")
+		SeriesOrderColumn     = sqlite.IntegerColumn("series_order")
+		allColumns            = sqlite.ColumnList{UUIDColumn, LibraryIDColumn, ArchivePathColumn, TitleColumn, TitleNativeColumn, TitleTranslatedColumn, CategoryColumn, SeriesColumn, ReleasedColumn, LanguageColumn, TranslatedColumn, NsfwColumn, HiddenColumn, ImageCountColumn, ArchiveSizeColumn, ArchiveHashColumn, ThumbnailColumn, CreatedAtColumn, UpdatedAtColumn, DeletedColumn, PageThumbnailsColumn, DeletedAtColumn, VolumeColumn, ChapterColumn, SeriesOrderColumn}
+		mutableColumns        = sqlite.ColumnList{LibraryIDColumn, ArchivePathColumn, TitleColumn, TitleNativeColumn, TitleTranslatedColumn, CategoryColumn, SeriesColumn, ReleasedColumn, LanguageColumn, TranslatedColumn, NsfwColumn, HiddenColumn, ImageCountColumn, ArchiveSizeColumn, ArchiveHashColumn, ThumbnailColumn, CreatedAtColumn, UpdatedAtColumn, DeletedColumn, PageThumbnailsColumn, DeletedAtColumn, VolumeColumn, ChapterColumn, SeriesOrderColumn}
 	)
 
 	return galleryTable{
@@ -118,6 +126,10 @@ func newGalleryTableImpl(schemaName, tableName, alias string) galleryTable {
 		UpdatedAt:       UpdatedAtColumn,
 		Deleted:         DeletedColumn,
 		PageThumbnails:  PageThumbnailsColumn,
+		DeletedAt:       DeletedAtColumn,
+		Volume:          VolumeColumn,
+		Chapter:         ChapterColumn,
+		SeriesOrder:     SeriesOrderColumn,
 
 		AllColumns:     allColumns,
 		MutableColumns: mutableColumns,
@@ -17,9 +17,14 @@ type libraryTable struct {
 	sqlite.Table
 
 	//Columns
-	ID     sqlite.ColumnInteger
-	Path   sqlite.ColumnString
-	Layout sqlite.ColumnString
+	ID       sqlite.ColumnInteger
+	Path     sqlite.ColumnString
+	Layout   sqlite.ColumnString
+	Name     sqlite.ColumnString
+	Nsfw     sqlite.ColumnBool
+	Language sqlite.ColumnString
+	Ltr      sqlite.ColumnBool
+	Deleted  sqlite.ColumnBool
 
 	AllColumns     sqlite.ColumnList
 	MutableColumns sqlite.ColumnList
@@ -53,17 +58,27 @@ func newLibraryTableImpl(schemaName, tableName, alias string) libraryTable {
 		IDColumn       = sqlite.IntegerColumn("id")
 		PathColumn     = sqlite.StringColumn("path")
 		LayoutColumn   = sqlite.StringColumn("layout")
-		allColumns     = sqlite.ColumnList{IDColumn, PathColumn, LayoutColumn}
-		mutableColumns = sqlite.ColumnList{PathColumn, LayoutColumn}
+		NameColumn     = sqlite.StringColumn("name")
+		NsfwColumn     = sqlite.BoolColumn("nsfw")
+		LanguageColumn = sqlite.StringColumn("language")
+		LtrColumn      = sqlite.BoolColumn("ltr")
+		DeletedColumn  = sqlite.BoolColumn("deleted")
+		allColumns     = sqlite.ColumnList{IDColumn, PathColumn, LayoutColumn, NameColumn, NsfwColumn, LanguageColumn, LtrColumn, DeletedColumn}
+		mutableColumns = sqlite.ColumnList{PathColumn, LayoutColumn, NameColumn, NsfwColumn, LanguageColumn, LtrColumn, DeletedColumn}
 	)
 
 	return libraryTable{
 		Table: sqlite.NewTable(schemaName, tableName, alias, allColumns...),
 
 		//Columns
-		ID:     IDColumn,
-		Path:   PathColumn,
-		Layout: LayoutColumn,
+		ID:       IDColumn,
+		Path:     PathColumn,
+		Layout:   LayoutColumn,
+		Name:     NameColumn,
+		Nsfw:     NsfwColumn,
+		Language: LanguageColumn,
+		Ltr:      LtrColumn,
+		Deleted:  DeletedColumn,
 
 		AllColumns:     allColumns,
 		MutableColumns: mutableColumns,
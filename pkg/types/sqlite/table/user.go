@@ -17,14 +17,21 @@ type userTable struct {
 	sqlite.Table
 
 	//Columns
-	UUID      sqlite.ColumnString
-	Username  sqlite.ColumnString
-	Password  sqlite.ColumnString
-	Salt      sqlite.ColumnString
-	Role      sqlite.ColumnInteger
-	BcryptPw  sqlite.ColumnString
-	CreatedAt sqlite.ColumnTimestamp
-	UpdatedAt sqlite.ColumnTimestamp
+	UUID         sqlite.ColumnString
+	Username     sqlite.ColumnString
+	Password     sqlite.ColumnString
+	Salt         sqlite.ColumnString
+	Role         sqlite.ColumnInteger
+	BcryptPw     sqlite.ColumnString
+	CreatedAt    sqlite.ColumnTimestamp
+	UpdatedAt    sqlite.ColumnTimestamp
+	HideNsfw     sqlite.ColumnBool
+	TotpSecret   sqlite.ColumnString
+	TotpEnabled  sqlite.ColumnBool
+	TotpLastStep sqlite.ColumnInteger
+	FailedLogins sqlite.ColumnInteger
+	LockedUntil  sqlite.ColumnTimestamp
+	Pending      sqlite.ColumnBool
 
 	AllColumns     sqlite.ColumnList
 	MutableColumns sqlite.ColumnList
@@ -55,30 +62,44 @@ func newUserTable(schemaName, tableName, alias string) *UserTable {
 
 func newUserTableImpl(schemaName, tableName, alias string) userTable {
 	var (
-		UUIDColumn      = sqlite.StringColumn("uuid")
-		UsernameColumn  = sqlite.StringColumn("username")
-		PasswordColumn  = sqlite.StringColumn("password")
-		SaltColumn      = sqlite.StringColumn("salt")
-		RoleColumn      = sqlite.IntegerColumn("role")
-		BcryptPwColumn  = sqlite.StringColumn("bcrypt_pw")
-		CreatedAtColumn = sqlite.TimestampColumn("created_at")
-		UpdatedAtColumn = sqlite.TimestampColumn("updated_at")
-		allColumns      = sqlite.ColumnList{UUIDColumn, UsernameColumn, PasswordColumn, SaltColumn, RoleColumn, BcryptPwColumn, CreatedAtColumn, UpdatedAtColumn}
-		mutableColumns  = sqlite.ColumnList{UUIDColumn, UsernameColumn, PasswordColumn, SaltColumn, RoleColumn, BcryptPwColumn, CreatedAtColumn, UpdatedAtColumn}
+		UUIDColumn         = sqlite.StringColumn("uuid")
+		UsernameColumn     = sqlite.StringColumn("username")
+		PasswordColumn     = sqlite.StringColumn("password")
+		SaltColumn         = sqlite.StringColumn("salt")
+		RoleColumn         = sqlite.IntegerColumn("role")
+		BcryptPwColumn     = sqlite.StringColumn("bcrypt_pw")
+		CreatedAtColumn    = sqlite.TimestampColumn("created_at")
+		UpdatedAtColumn    = sqlite.TimestampColumn("updated_at")
+		HideNsfwColumn     = sqlite.BoolColumn("hide_nsfw")
+		TotpSecretColumn   = sqlite.StringColumn("totp_secret")
+		TotpEnabledColumn  = sqlite.BoolColumn("totp_enabled")
+		TotpLastStepColumn = sqlite.IntegerColumn("totp_last_step")
+		FailedLoginsColumn = sqlite.IntegerColumn("failed_logins")
+		LockedUntilColumn  = sqlite.TimestampColumn("locked_until")
+		PendingColumn      = sqlite.BoolColumn("pending")
+		allColumns         = sqlite.ColumnList{UUIDColumn, UsernameColumn, PasswordColumn, SaltColumn, RoleColumn, BcryptPwColumn, CreatedAtColumn, UpdatedAtColumn, HideNsfwColumn, TotpSecretColumn, TotpEnabledColumn, TotpLastStepColumn, FailedLoginsColumn, LockedUntilColumn, PendingColumn}
+		mutableColumns     = sqlite.ColumnList{UUIDColumn, UsernameColumn, PasswordColumn, SaltColumn, RoleColumn, BcryptPwColumn, CreatedAtColumn, UpdatedAtColumn, HideNsfwColumn, TotpSecretColumn, TotpEnabledColumn, TotpLastStepColumn, FailedLoginsColumn, LockedUntilColumn, PendingColumn}
 	)
 
 	return userTable{
 		Table: sqlite.NewTable(schemaName, tableName, alias, allColumns...),
 
 		//Columns
-		UUID:      UUIDColumn,
-		Username:  UsernameColumn,
-		Password:  PasswordColumn,
-		Salt:      SaltColumn,
-		Role:      RoleColumn,
-		BcryptPw:  BcryptPwColumn,
-		CreatedAt: CreatedAtColumn,
-		UpdatedAt: UpdatedAtColumn,
+		UUID:         UUIDColumn,
+		Username:     UsernameColumn,
+		Password:     PasswordColumn,
+		Salt:         SaltColumn,
+		Role:         RoleColumn,
+		BcryptPw:     BcryptPwColumn,
+		CreatedAt:    CreatedAtColumn,
+		UpdatedAt:    UpdatedAtColumn,
+		HideNsfw:     HideNsfwColumn,
+		TotpSecret:   TotpSecretColumn,
+		TotpEnabled:  TotpEnabledColumn,
+		TotpLastStep: TotpLastStepColumn,
+		FailedLogins: FailedLoginsColumn,
+		LockedUntil:  LockedUntilColumn,
+		Pending:      PendingColumn,
 
 		AllColumns:     allColumns,
 		MutableColumns: mutableColumns,
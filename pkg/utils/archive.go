@@ -14,6 +14,8 @@ import (
 
 // UniversalExtract extracts media files from zip, cbz, rar, cbr, tar (all its variants) archives.
 // Plain directories without compression are also supported. For PDF files, use ExtractPDF.
+// Files are extracted to a temporary dir that is renamed to dst when done, so that an interrupted extraction
+// is not mistaken for a cached gallery.
 func UniversalExtract(dst string, archivePath string) ([]string, int) {
 	fsys, err := archiver.FileSystem(nil, archivePath)
 	if err != nil {
@@ -23,14 +25,20 @@ func UniversalExtract(dst string, archivePath string) ([]string, int) {
 		return nil, 0
 	}
 
-	if err = os.Mkdir(dst, os.ModePerm); err != nil {
-		if !errors.Is(err, fs.ErrNotExist) {
-			log.Z.Error("failed to create a dir for gallery",
-				zap.String("path", dst),
-				zap.String("err", err.Error()))
-			return nil, 0
+	// Leftovers of an earlier interrupted extraction are removed first.
+	tmpDst := dst + ".tmp"
+	if err = os.RemoveAll(tmpDst); err != nil {
+		log.Z.Error("failed to remove a partially extracted gallery",
+			zap.String("path", tmpDst),
+			zap.String("err", err.Error()))
+		return nil, 0
+	}
 
-		}
+	if err = os.Mkdir(tmpDst, os.ModePerm); err != nil {
+		log.Z.Error("failed to create a dir for gallery",
+			zap.String("path", tmpDst),
+			zap.String("err", err.Error()))
+		return nil, 0
 	}
 
 	var files []string
@@ -40,7 +48,7 @@ func UniversalExtract(dst string, archivePath string) ([]string, int) {
 		if err != nil {
 			return err
 		}
-		dstPath := filepath.Join(dst, s)
+		dstPath := filepath.Join(tmpDst, s)
 
 		if s == "." || s == ".." {
 			return nil
@@ -92,6 +100,15 @@ func UniversalExtract(dst string, archivePath string) ([]string, int) {
 	})
 	if err != nil {
 		log.Z.Debug("failed to walk dir when copying archive", zap.String("err", err.Error()))
+		_ = os.RemoveAll(tmpDst)
+		return nil, 0
+	}
+
+	if err = os.Rename(tmpDst, dst); err != nil {
+		log.Z.Error("failed to move the extracted gallery to the cache",
+			zap.String("path", dst),
+			zap.String("err", err.Error()))
+		_ = os.RemoveAll(tmpDst)
 		return nil, 0
 	}
 
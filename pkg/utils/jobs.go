@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Background jobs, such as the scan, thumbnail and metadata tasks, and the periodic tasks.
+// They are cancelled and waited for on shutdown.
+var (
+	jobsCtx, cancelJobs = context.WithCancel(context.Background())
+	jobs                sync.WaitGroup
+)
+
+// RunJob runs the function in a separate goroutine with a context that is cancelled on shutdown.
+// The function should return soon after the context is cancelled, leaving no half-finished work behind.
+func RunJob(f func(ctx context.Context)) {
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		f(jobsCtx)
+	}()
+}
+
+// PeriodicTask loops the given function in separate thread between the given interval until shutdown.
+func PeriodicTask(d time.Duration, f func()) {
+	RunJob(func(ctx context.Context) {
+		for {
+			f()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(d):
+			}
+		}
+	})
+}
+
+// StopJobs cancels the background jobs and waits for them to return. Returns the error of the context if it is
+// done before all jobs have returned.
+func StopJobs(ctx context.Context) error {
+	cancelJobs()
+
+	done := make(chan struct{})
+	go func() {
+		jobs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
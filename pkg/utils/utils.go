@@ -11,7 +11,6 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
-	"time"
 )
 
 // ReadJSON returns the given JSON file as bytes.
@@ -48,14 +47,33 @@ func ClampU(value, min, max uint64) uint64 {
 	return value
 }
 
-// PeriodicTask loops the given function in separate thread between the given interval.
-func PeriodicTask(d time.Duration, f func()) {
-	go func() {
-		for {
-			f()
-			time.Sleep(d)
-		}
-	}()
+// WriteFileAtomic writes the data to a temporary file next to the named file and renames it over the named file.
+// Readers never see a partially written file, even if the server is stopped in the middle of writing.
+func WriteFileAtomic(name string, data []byte, perm os.FileMode) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmpFile.Name()
+
+	if _, err = tmpFile.Write(data); err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpName, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmpName, name)
+	}
+
+	if err != nil {
+		_ = os.Remove(tmpName)
+	}
+
+	return err
 }
 
 // PathExists checks if the given path exists.
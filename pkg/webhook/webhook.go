@@ -0,0 +1,296 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/db"
+	"github.com/Mangatsu/server/pkg/events"
+	"github.com/Mangatsu/server/pkg/log"
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+	"go.uber.org/zap"
+)
+
+// Headers of the deliveries.
+const (
+	EventHeader     = "X-Mangatsu-Event"
+	DeliveryHeader  = "X-Mangatsu-Delivery"
+	SignatureHeader = "X-Mangatsu-Signature"
+)
+
+// MaxAttempts is how many times a delivery is attempted before it fails.
+const MaxAttempts = 8
+
+// firstRetryDelay is the wait after the first failed attempt. It doubles after every attempt.
+const firstRetryDelay = 30 * time.Second
+
+// pollInterval is how often the queue is checked for retries when no new events arrive.
+const pollInterval = 10 * time.Second
+
+// maxErrorLength limits the response bodies and errors stored in the delivery log.
+const maxErrorLength = 512
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// webhooks are the registered webhooks, loaded from the database on start and by Refresh.
+var webhooks atomic.Pointer[[]model.Webhook]
+
+// wake signals the delivery loop that new deliveries were queued.
+var wake = make(chan struct{}, 1)
+
+// Run queues the events to the webhooks and delivers them until the context is cancelled. Deliveries left in the
+// queue are continued on the next start.
+func Run(ctx context.Context) {
+	Refresh()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		deliverQueued(ctx)
+	}()
+
+	queueEvents(ctx)
+	wg.Wait()
+}
+
+// Refresh loads the webhooks after they have been changed.
+func Refresh() {
+	loaded, err := db.GetWebhooks()
+	if err != nil {
+		log.Z.Error("failed to load webhooks", zap.String("err", err.Error()))
+		return
+	}
+
+	webhooks.Store(&loaded)
+}
+
+// Signature returns the HMAC-SHA256 of the payload as sent in the signature header.
+func Signature(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Subscribes returns true if the webhook wants the type of events. No events means all events.
+func Subscribes(webhook model.Webhook, eventType events.Type) bool {
+	return webhook.Events == "" || slices.Contains(strings.Split(webhook.Events, ","), string(eventType))
+}
+
+// queueEvents stores the published events as deliveries. If the subscription falls behind, the missed events are
+// recovered from the history of the bus as far as it reaches.
+func queueEvents(ctx context.Context) {
+	var lastID uint64
+	for {
+		subscription, missed := events.DefaultBus.Subscribe(lastID)
+		for _, event := range missed {
+			queueEvent(event)
+			lastID = event.ID
+		}
+
+		if !consume(ctx, subscription, &lastID) {
+			return
+		}
+		log.Z.Warn("webhook events fell behind, resubscribing")
+	}
+}
+
+// consume queues the events of the subscription until it is closed. Returns false if the context is cancelled.
+func consume(ctx context.Context, subscription *events.Subscription, lastID *uint64) bool {
+	for {
+		select {
+		case <-ctx.Done():
+			events.DefaultBus.Unsubscribe(subscription)
+			return false
+		case event, ok := <-subscription.C:
+			if !ok {
+				return true
+			}
+			queueEvent(event)
+			*lastID = event.ID
+		}
+	}
+}
+
+func queueEvent(event events.Event) {
+	loaded := webhooks.Load()
+	if loaded == nil {
+		return
+	}
+
+	var webhookIDs []int32
+	for _, webhook := range *loaded {
+		if webhook.Enabled && Subscribes(webhook, event.Type) {
+			webhookIDs = append(webhookIDs, webhook.ID)
+		}
+	}
+	if len(webhookIDs) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Z.Error("failed to encode webhook payload", zap.String("err", err.Error()))
+		return
+	}
+
+	if err = db.NewWebhookDeliveries(webhookIDs, string(event.Type), string(payload)); err != nil {
+		log.Z.Error("failed to queue webhook deliveries", zap.String("err", err.Error()))
+		return
+	}
+
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliverQueued attempts the due deliveries whenever new ones are queued, and regularly for the retries.
+func deliverQueued(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		deliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func deliverDue(ctx context.Context) {
+	deliveries, err := db.GetPendingWebhookDeliveries()
+	if err != nil {
+		log.Z.Error("failed to get webhook deliveries", zap.String("err", err.Error()))
+		return
+	}
+
+	loaded := webhooks.Load()
+	if loaded == nil {
+		return
+	}
+
+	now := time.Now()
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			return
+		}
+		if delivery.NextAttemptAt.After(now) {
+			continue
+		}
+
+		// Deliveries of disabled webhooks wait until they are enabled again.
+		index := slices.IndexFunc(*loaded, func(webhook model.Webhook) bool { return webhook.ID == delivery.WebhookID })
+		if index < 0 || !(*loaded)[index].Enabled {
+			continue
+		}
+
+		attempt(ctx, (*loaded)[index], delivery)
+	}
+}
+
+// attempt sends the delivery once and records the result. Attempts interrupted by a shutdown are not counted.
+func attempt(ctx context.Context, webhook model.Webhook, delivery model.WebhookDelivery) {
+	responseStatus, err := send(ctx, webhook, delivery)
+	if ctx.Err() != nil {
+		return
+	}
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = responseStatus
+	delivery.Error = nil
+
+	switch {
+	case err == nil:
+		delivery.Status = string(db.DeliveryDelivered)
+	case delivery.Attempts >= MaxAttempts:
+		delivery.Status = string(db.DeliveryFailed)
+	default:
+		delivery.NextAttemptAt = now.Add(retryDelay(delivery.Attempts))
+	}
+
+	if err != nil {
+		message := truncate(err.Error())
+		delivery.Error = &message
+		log.Z.Debug("webhook delivery failed",
+			zap.Int32("webhook", webhook.ID),
+			zap.Int32("delivery", delivery.ID),
+			zap.Int32("attempts", delivery.Attempts),
+			zap.String("err", message))
+	}
+
+	if err = db.UpdateWebhookDelivery(delivery); err != nil {
+		log.Z.Error("failed to record webhook delivery", zap.String("err", err.Error()))
+	}
+}
+
+// send posts the payload with its signature. Only 2xx responses are successful.
+func send(ctx context.Context, webhook model.Webhook, delivery model.WebhookDelivery) (*int32, error) {
+	payload := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Mangatsu")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(int(delivery.ID)))
+	req.Header.Set(SignatureHeader, Signature(webhook.Secret, payload))
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	status := int32(res.StatusCode)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorLength))
+		return &status, &statusError{status: res.Status, body: strings.TrimSpace(string(body))}
+	}
+
+	return &status, nil
+}
+
+type statusError struct {
+	status string
+	body   string
+}
+
+func (e *statusError) Error() string {
+	if e.body == "" {
+		return "unexpected status " + e.status
+	}
+	return "unexpected status " + e.status + ": " + e.body
+}
+
+// retryDelay returns the wait before the next attempt: 30s, 1m, 2m and so on.
+func retryDelay(attempts int32) time.Duration {
+	return firstRetryDelay << (attempts - 1)
+}
+
+func truncate(message string) string {
+	if len(message) <= maxErrorLength {
+		return message
+	}
+	return strings.ToValidUTF8(message[:maxErrorLength], "")
+}
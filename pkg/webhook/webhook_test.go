@@ -0,0 +1,79 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Mangatsu/server/pkg/events"
+	"github.com/Mangatsu/server/pkg/types/sqlite/model"
+)
+
+func TestSignature(t *testing.T) {
+	// HMAC-SHA256 test vector from RFC 4231, test case 2.
+	signature := Signature("Jefe", []byte("what do ya want for nothing?"))
+	expected := "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	if signature != expected {
+		t.Errorf("Expected %s, got %s", expected, signature)
+	}
+}
+
+func TestSubscribes(t *testing.T) {
+	all := model.Webhook{}
+	filtered := model.Webhook{Events: "gallery_added,error"}
+
+	if !Subscribes(all, events.TaskFinished) {
+		t.Error("Expected a webhook without events to receive all events")
+	}
+	if !Subscribes(filtered, events.Error) || Subscribes(filtered, events.TaskFinished) {
+		t.Error("Expected a webhook to receive only its events")
+	}
+}
+
+func TestSend(t *testing.T) {
+	var received *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		if r.URL.Path == "/fail" {
+			http.Error(w, "try again later", http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	webhook := model.Webhook{ID: 1, URL: server.URL + "/hook", Secret: "secret"}
+	delivery := model.WebhookDelivery{ID: 7, WebhookID: 1, Event: "gallery_added", Payload: `{"Type":"gallery_added"}`}
+
+	status, err := send(context.Background(), webhook, delivery)
+	if err != nil || status == nil || *status != http.StatusOK {
+		t.Fatalf("Expected a successful delivery, got %v and %v", status, err)
+	}
+	if string(body) != delivery.Payload {
+		t.Errorf("Expected the payload %s, got %s", delivery.Payload, body)
+	}
+	if received.Header.Get(SignatureHeader) != Signature("secret", body) {
+		t.Errorf("Expected the signature of the payload, got %s", received.Header.Get(SignatureHeader))
+	}
+	if received.Header.Get(EventHeader) != "gallery_added" || received.Header.Get(DeliveryHeader) != "7" {
+		t.Errorf("Expected the event and delivery headers, got %v", received.Header)
+	}
+
+	webhook.URL = server.URL + "/fail"
+	status, err = send(context.Background(), webhook, delivery)
+	if err == nil || status == nil || *status != http.StatusServiceUnavailable {
+		t.Fatalf("Expected a failed delivery, got %v and %v", status, err)
+	}
+	if err.Error() != "unexpected status 503 Service Unavailable: try again later" {
+		t.Errorf("Expected the response in the error, got %s", err)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	if retryDelay(1) != 30*time.Second || retryDelay(3) != 2*time.Minute {
+		t.Errorf("Expected the delay to double, got %s and %s", retryDelay(1), retryDelay(3))
+	}
+}